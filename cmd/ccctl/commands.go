@@ -0,0 +1,436 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/lzb13612/Example-Chaincode/internal/mockledger"
+	usercc "github.com/lzb13612/Example-Chaincode/user/chaincode"
+)
+
+// cli 命令行上下文
+type cli struct {
+	out       io.Writer                     // 标准输出
+	errOut    io.Writer                     // 错误输出
+	ledgers   map[string]*mockledger.Ledger // 链码名称 -> 账本
+	transient map[string][]byte             // 每次调用携带的临时数据
+}
+
+// command 子命令
+type command struct {
+	usage string                            // 用法说明
+	run   func(c *cli, args []string) error // 执行函数
+}
+
+// commands 链码名称 -> 子命令名称 -> 子命令
+var commands = map[string]map[string]command{
+	"user": {
+		"add": {"[--id <id>] --name <name> --sex <sex> [--org <id>] [--public-key <pem>]  添加用户(链码分配id时不指定 --id)", func(c *cli, args []string) error {
+			fs, user := userFlags("add")
+			publicKey := fs.String("public-key", "", "用户公钥(PEM)")
+			if err := parseFlags(fs, args); err != nil {
+				return err
+			}
+			user.PublicKey = *publicKey
+			return c.invoke("user", "addUser", userArg(*user))
+		}},
+		"get": {"--id <id>  查询用户", func(c *cli, args []string) error {
+			fs, user := userFlags("get")
+			if err := parseFlags(fs, args, "id"); err != nil {
+				return err
+			}
+			return c.invoke("user", "queryOnceUser", userArg(usercc.UserInfo{Id: user.Id}))
+		}},
+		"list": {"[--status <status>]  查询所有用户", func(c *cli, args []string) error {
+			fs := flag.NewFlagSet("list", flag.ContinueOnError)
+			status := fs.String("status", "", "只查询该状态的用户")
+			if err := parseFlags(fs, args); err != nil {
+				return err
+			}
+			if *status == "" {
+				return c.invoke("user", "queryAllUser")
+			}
+			return c.invoke("user", "queryAllUser", *status)
+		}},
+		"range": {"[--start <id>] [--end <id>] [--size <n>] [--bookmark <bookmark>]  按id区间分页查询用户", func(c *cli, args []string) error {
+			fs := flag.NewFlagSet("range", flag.ContinueOnError)
+			start := fs.String("start", "", "起始id(包含)")
+			end := fs.String("end", "", "终止id(包含)")
+			size := fs.Int("size", 20, "每页用户数")
+			bookmark := fs.String("bookmark", "", "上一页返回的书签")
+			if err := parseFlags(fs, args); err != nil {
+				return err
+			}
+			return c.invoke("user", "queryUsersByIdRange", *start, *end, fmt.Sprint(*size), *bookmark)
+		}},
+		"alter": {"--id <id> --name <name> --sex <sex> [--org <id>]  修改用户", func(c *cli, args []string) error {
+			fs, user := userFlags("alter")
+			if err := parseFlags(fs, args, "id"); err != nil {
+				return err
+			}
+			return c.invoke("user", "alterUser", userArg(*user))
+		}},
+		"del": {"--id <id>  删除用户", func(c *cli, args []string) error {
+			fs, user := userFlags("del")
+			if err := parseFlags(fs, args, "id"); err != nil {
+				return err
+			}
+			return c.invoke("user", "delUser", userArg(usercc.UserInfo{Id: user.Id}))
+		}},
+		"find": {"--name <name>  按用户名查询", func(c *cli, args []string) error {
+			fs, user := userFlags("find")
+			if err := parseFlags(fs, args, "name"); err != nil {
+				return err
+			}
+			return c.invoke("user", "queryUserByName", userArg(usercc.UserInfo{Name: user.Name}))
+		}},
+		"history": {"--id <id>  查询用户的历史修改", func(c *cli, args []string) error {
+			fs, user := userFlags("history")
+			if err := parseFlags(fs, args, "id"); err != nil {
+				return err
+			}
+			return c.invoke("user", "queryUserHistory", userArg(usercc.UserInfo{Id: user.Id}))
+		}},
+		"register": {"--name <name> --sex <sex> [--org <id>]  以当前身份注册", func(c *cli, args []string) error {
+			fs, user := userFlags("register")
+			if err := parseFlags(fs, args, "name"); err != nil {
+				return err
+			}
+			return c.invoke("user", "registerSelf", userArg(usercc.UserInfo{Name: user.Name, Sex: user.Sex, OrgId: user.OrgId}))
+		}},
+		"group": {"--id <id> --name <name> [--description <text>]  创建用户组", func(c *cli, args []string) error {
+			fs := flag.NewFlagSet("group", flag.ContinueOnError)
+			var group usercc.Group
+			fs.StringVar(&group.Id, "id", "", "组id")
+			fs.StringVar(&group.Name, "name", "", "组名")
+			fs.StringVar(&group.Description, "description", "", "描述")
+			if err := parseFlags(fs, args, "id", "name"); err != nil {
+				return err
+			}
+			groupBytes, _ := json.Marshal(group)
+			return c.invoke("user", "createGroup", string(groupBytes))
+		}},
+		"groups": {"[--user <id>] [--size <n>] [--bookmark <bookmark>]  分页查询用户组(指定 --user 时查询该用户所在的组)", func(c *cli, args []string) error {
+			fs := flag.NewFlagSet("groups", flag.ContinueOnError)
+			user := fs.String("user", "", "用户id")
+			size := fs.Int("size", 20, "每页用户组数")
+			bookmark := fs.String("bookmark", "", "上一页返回的书签")
+			if err := parseFlags(fs, args); err != nil {
+				return err
+			}
+			if *user == "" {
+				return c.invoke("user", "listGroups", fmt.Sprint(*size), *bookmark)
+			}
+			return c.invoke("user", "queryUserGroups", *user, fmt.Sprint(*size), *bookmark)
+		}},
+		"members": {"--group <id> [--size <n>] [--bookmark <bookmark>]  分页查询组成员", func(c *cli, args []string) error {
+			fs := flag.NewFlagSet("members", flag.ContinueOnError)
+			group := fs.String("group", "", "组id")
+			size := fs.Int("size", 20, "每页成员数")
+			bookmark := fs.String("bookmark", "", "上一页返回的书签")
+			if err := parseFlags(fs, args, "group"); err != nil {
+				return err
+			}
+			return c.invoke("user", "queryGroupMembers", *group, fmt.Sprint(*size), *bookmark)
+		}},
+		"join":  {"--group <id> --user <id>  添加组成员", memberCommand("join", "addMember")},
+		"leave": {"--group <id> --user <id>  移除组成员", memberCommand("leave", "removeMember")},
+		"org": {"--id <id> --name <name> --msp <msp> [--contact <contact>]  登记组织", func(c *cli, args []string) error {
+			fs := flag.NewFlagSet("org", flag.ContinueOnError)
+			var org usercc.Organization
+			fs.StringVar(&org.Id, "id", "", "组织id")
+			fs.StringVar(&org.Name, "name", "", "组织名称")
+			fs.StringVar(&org.MspId, "msp", "", "组织的 MSP ID")
+			fs.StringVar(&org.Contact, "contact", "", "联系方式")
+			if err := parseFlags(fs, args, "id", "name", "msp"); err != nil {
+				return err
+			}
+			orgBytes, _ := json.Marshal(org)
+			return c.invoke("user", "createOrg", string(orgBytes))
+		}},
+		"orgs": {"[--org <id>] [--size <n>] [--bookmark <bookmark>]  分页查询组织(指定 --org 时查询该组织的用户)", func(c *cli, args []string) error {
+			fs := flag.NewFlagSet("orgs", flag.ContinueOnError)
+			org := fs.String("org", "", "组织id")
+			size := fs.Int("size", 20, "每页数量")
+			bookmark := fs.String("bookmark", "", "上一页返回的书签")
+			if err := parseFlags(fs, args); err != nil {
+				return err
+			}
+			if *org == "" {
+				return c.invoke("user", "listOrgs", fmt.Sprint(*size), *bookmark)
+			}
+			return c.invoke("user", "queryOrgUsers", *org, fmt.Sprint(*size), *bookmark)
+		}},
+		"move": {"--id <id> --org <id> --reason <reason>  将用户调到另一个组织", func(c *cli, args []string) error {
+			fs, user := userFlags("move")
+			reason := fs.String("reason", "", "调动原因")
+			if err := parseFlags(fs, args, "id", "org", "reason"); err != nil {
+				return err
+			}
+			return c.invoke("user", "moveUser", user.Id, user.OrgId, *reason)
+		}},
+		"assign": {"--org <id> --reason <reason> [--limit <n>] [--bookmark <bookmark>]  为没有组织的已有用户分配组织", func(c *cli, args []string) error {
+			fs := flag.NewFlagSet("assign", flag.ContinueOnError)
+			org := fs.String("org", "", "组织id")
+			reason := fs.String("reason", "", "分配原因")
+			limit := fs.Int("limit", 100, "本次最多扫描的用户数")
+			bookmark := fs.String("bookmark", "", "上次返回的书签")
+			if err := parseFlags(fs, args, "org", "reason"); err != nil {
+				return err
+			}
+			return c.invoke("user", "assignOrg", *org, *reason, fmt.Sprint(*limit), *bookmark)
+		}},
+		"relate":   {"--type <type> --from <id> --to <id>  添加用户关系", relationCommand("relate", "addRelation")},
+		"unrelate": {"--type <type> --from <id> --to <id>  删除用户关系", relationCommand("unrelate", "removeRelation")},
+		"relations": {"--id <id> [--type <type>] [--direction out|in|both] [--depth <n>] [--limit <n>]  查询用户关系(--depth 大于 1 时遍历)", func(c *cli, args []string) error {
+			fs := flag.NewFlagSet("relations", flag.ContinueOnError)
+			id := fs.String("id", "", "用户id")
+			relType := fs.String("type", "", "关系类型,为空表示全部类型")
+			direction := fs.String("direction", usercc.DirectionOut, "查询方向")
+			depth := fs.Int("depth", 1, "最大深度")
+			limit := fs.Int("limit", 100, "最多返回的数量")
+			if err := parseFlags(fs, args, "id"); err != nil {
+				return err
+			}
+			if *depth <= 1 {
+				return c.invoke("user", "queryRelations", *id, *relType, *direction, fmt.Sprint(*limit))
+			}
+			return c.invoke("user", "traverseRelations", *id, *relType, *direction, fmt.Sprint(*depth), fmt.Sprint(*limit))
+		}},
+		"activate": {"--id <id> --reason <reason>  激活用户", statusCommand("activate", "activateUser")},
+		"suspend":  {"--id <id> --reason <reason>  暂停用户", statusCommand("suspend", "suspendUser")},
+		"close":    {"--id <id> --reason <reason>  关闭用户", statusCommand("close", "closeUser")},
+		"reopen":   {"--id <id> --reason <reason>  重新开启用户", statusCommand("reopen", "reopenUser")},
+		"whoami":   {"  查询当前身份绑定的用户", simpleCommand("user", "whoAmI")},
+		"grant":    {"--msp <msp> --principal <subject|id> --role <role>  授予角色", roleCommand("grant", "grantRole")},
+		"revoke":   {"--msp <msp> --principal <subject|id> --role <role>  撤销角色", roleCommand("revoke", "revokeRole")},
+		"roles":    {"  查询本组织登记的角色", simpleCommand("user", "listRoles")},
+		"config": {"<json>  修改链码配置(部署组织的管理员)", func(c *cli, args []string) error {
+			if len(args) != 1 {
+				return errors.New("config takes one JSON argument")
+			}
+			return c.invoke("user", "setConfig", args[0])
+		}},
+		"invoke": {"<function> [args...]  以原始参数调用链码函数", invokeCommand("user")},
+		"keys":   {"[--start <key>] [--end <key>]  列出账本中的键值(复合键解码显示)", rangeCommand("user")},
+	},
+	"example": {
+		"init":   {"  重新初始化示例数据", simpleCommand("example", "init")},
+		"put":    {"  写入示例键 name[lzb5]", simpleCommand("example", "putState")},
+		"get":    {"  读取示例键 name[lzb]", simpleCommand("example", "getState")},
+		"del":    {"  删除示例键 name[lzb]", simpleCommand("example", "delState")},
+		"range":  {"[--start <key>] [--end <key>]  按起止键读取账本(复合键解码显示)", rangeCommand("example")},
+		"invoke": {"<function> [args...]  以原始参数调用链码函数", invokeCommand("example")},
+	},
+}
+
+// run 执行一条命令:<链码> <命令> [参数]
+func (c *cli) run(args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	if args[0] == "help" {
+		fmt.Fprint(c.out, commandHelp())
+		return nil
+	}
+	chaincode, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown chaincode %s, expected one of: %s", args[0], strings.Join(sortedKeys(commands), ", "))
+	}
+	if len(args) < 2 {
+		return fmt.Errorf("missing command, %s commands: %s", args[0], strings.Join(sortedCommands(chaincode), ", "))
+	}
+	cmd, ok := chaincode[args[1]]
+	if !ok {
+		return fmt.Errorf("unknown command %s %s, expected one of: %s", args[0], args[1], strings.Join(sortedCommands(chaincode), ", "))
+	}
+	return cmd.run(c, args[2:])
+}
+
+// invoke 调用链码函数并打印响应
+func (c *cli) invoke(chaincode, function string, args ...string) error {
+	res := c.ledgers[chaincode].Invoke(function, args, c.transient)
+	if res.Status != shim.OK {
+		return errors.New(res.Message)
+	}
+	if len(res.Payload) == 0 {
+		fmt.Fprintln(c.out, "OK")
+		return nil
+	}
+	fmt.Fprintln(c.out, prettyJSON(res.Payload))
+	return nil
+}
+
+// simpleCommand 不带参数的链码调用
+func simpleCommand(chaincode, function string) func(c *cli, args []string) error {
+	return func(c *cli, args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("%s takes no arguments", function)
+		}
+		return c.invoke(chaincode, function)
+	}
+}
+
+// invokeCommand 以原始参数调用任意链码函数
+func invokeCommand(chaincode string) func(c *cli, args []string) error {
+	return func(c *cli, args []string) error {
+		if len(args) == 0 {
+			return errors.New("missing function name")
+		}
+		return c.invoke(chaincode, args[0], args[1:]...)
+	}
+}
+
+// rangeCommand 按起止键读取账本并打印解码后的键
+func rangeCommand(chaincode string) func(c *cli, args []string) error {
+	return func(c *cli, args []string) error {
+		fs := flag.NewFlagSet("range", flag.ContinueOnError)
+		start := fs.String("start", "", "起始键(包含)")
+		end := fs.String("end", "", "终止键(不包含)")
+		if err := parseFlags(fs, args); err != nil {
+			return err
+		}
+		kvs := c.ledgers[chaincode].Range(*start, *end)
+		for _, kv := range kvs {
+			fmt.Fprintf(c.out, "%s = %s\n", mockledger.DecodeKey(kv.Key), mockledger.FormatValue(kv.Value))
+		}
+		fmt.Fprintf(c.out, "(%d keys)\n", len(kvs))
+		return nil
+	}
+}
+
+// userFlags 创建带有用户字段的参数集
+func userFlags(name string) (*flag.FlagSet, *usercc.UserInfo) {
+	user := new(usercc.UserInfo)
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&user.Id, "id", "", "用户id")
+	fs.StringVar(&user.Name, "name", "", "用户名")
+	fs.StringVar(&user.Sex, "sex", "", "用户性别")
+	fs.StringVar(&user.OrgId, "org", "", "所属组织id")
+	return fs, user
+}
+
+// statusCommand 变更用户状态的子命令
+func statusCommand(name, function string) func(c *cli, args []string) error {
+	return func(c *cli, args []string) error {
+		fs, user := userFlags(name)
+		fs.StringVar(&user.StatusReason, "reason", "", "变更原因")
+		if err := parseFlags(fs, args, "id", "reason"); err != nil {
+			return err
+		}
+		return c.invoke("user", function, userArg(usercc.UserInfo{Id: user.Id, StatusReason: user.StatusReason}))
+	}
+}
+
+// roleCommand 授予或撤销角色的子命令
+func roleCommand(name, function string) func(c *cli, args []string) error {
+	return func(c *cli, args []string) error {
+		fs := flag.NewFlagSet(name, flag.ContinueOnError)
+		mspId := fs.String("msp", "", "身份所属的 MSP ID")
+		principal := fs.String("principal", "", "证书 subject 或用户id")
+		role := fs.String("role", "", "角色")
+		if err := parseFlags(fs, args, "msp", "principal", "role"); err != nil {
+			return err
+		}
+		return c.invoke("user", function, *mspId, *principal, *role)
+	}
+}
+
+// memberCommand 添加或移除组成员的子命令
+func memberCommand(name, function string) func(c *cli, args []string) error {
+	return func(c *cli, args []string) error {
+		fs := flag.NewFlagSet(name, flag.ContinueOnError)
+		group := fs.String("group", "", "组id")
+		user := fs.String("user", "", "用户id")
+		if err := parseFlags(fs, args, "group", "user"); err != nil {
+			return err
+		}
+		return c.invoke("user", function, *group, *user)
+	}
+}
+
+// relationCommand 添加或删除用户关系的子命令
+func relationCommand(name, function string) func(c *cli, args []string) error {
+	return func(c *cli, args []string) error {
+		fs := flag.NewFlagSet(name, flag.ContinueOnError)
+		relType := fs.String("type", "", "关系类型")
+		from := fs.String("from", "", "起点用户id")
+		to := fs.String("to", "", "终点用户id")
+		if err := parseFlags(fs, args, "type", "from", "to"); err != nil {
+			return err
+		}
+		return c.invoke("user", function, *relType, *from, *to)
+	}
+}
+
+// parseFlags 解析子命令参数并校验必填参数
+func parseFlags(fs *flag.FlagSet, args []string, required ...string) error {
+	fs.SetOutput(ioutil.Discard)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	for _, name := range required {
+		if fs.Lookup(name).Value.String() == "" {
+			return fmt.Errorf("--%s is required", name)
+		}
+	}
+	return nil
+}
+
+// userArg 序列化旧版函数使用的 UserInfo JSON 参数
+func userArg(user usercc.UserInfo) string {
+	userBytes, _ := json.Marshal(user)
+	return string(userBytes)
+}
+
+// prettyJSON 缩进显示 JSON,非 JSON 内容原样返回
+func prettyJSON(payload []byte) string {
+	var out bytes.Buffer
+	if err := json.Indent(&out, payload, "", "  "); err != nil {
+		return string(payload)
+	}
+	return out.String()
+}
+
+// commandHelp 生成所有子命令的帮助信息
+func commandHelp() string {
+	var help strings.Builder
+	help.WriteString("commands:\n")
+	for _, chaincode := range sortedKeys(commands) {
+		for _, name := range sortedCommands(commands[chaincode]) {
+			fmt.Fprintf(&help, "  %s %s %s\n", chaincode, name, commands[chaincode][name].usage)
+		}
+	}
+	return help.String()
+}
+
+// sortedKeys 返回排序后的链码名称
+func sortedKeys(m map[string]map[string]command) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedCommands 返回排序后的子命令名称
+func sortedCommands(m map[string]command) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
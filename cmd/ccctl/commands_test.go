@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestCLI 创建使用内存账本的命令行上下文,User 账本不要求用户属于组织
+func newTestCLI(t *testing.T) (*cli, *bytes.Buffer) {
+	c, err := newCLI("", "Org1MSP", "lzb", false, false, "", "", `{"requireOrg":false}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := new(bytes.Buffer)
+	c.out, c.errOut = out, out
+	return c, out
+}
+
+func TestCLI_UserCommands(t *testing.T) {
+	c, out := newTestCLI(t)
+	if err := c.run([]string{"user", "add", "--id", "3", "--name", "x", "--sex", "男"}); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := c.run([]string{"user", "get", "--id", "3"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "{\n  \"id\": \"3\",\n  \"name\": \"x\",\n  \"sex\": \"男\"\n}\n"
+	if out.String() != expected {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	if err := c.run([]string{"user", "add", "--id", "3", "--name", "x"}); err == nil || err.Error() != "user exist" {
+		t.Fatalf("expected user exist, got %v", err)
+	}
+	if err := c.run([]string{"user", "add", "--name", "x"}); err == nil {
+		t.Fatal("expected missing id error")
+	}
+	out.Reset()
+	if err := c.run([]string{"user", "range", "--start", "2", "--size", "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"id": "2"`) || !strings.Contains(out.String(), `"bookmark": "user:Org1MSP:n01:3"`) {
+		t.Fatalf("unexpected range output:\n%s", out)
+	}
+}
+
+func TestCLI_ExampleRange(t *testing.T) {
+	c, out := newTestCLI(t)
+	if err := c.run([]string{"example", "range", "--start", "\x00name\x00", "--end", "\x00name\x01"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"name[lzb] = \"value\"", "name[lzb1] = \"value1\"", "(3 keys)"} {
+		if !strings.Contains(out.String(), key) {
+			t.Fatalf("expected %q in output:\n%s", key, out)
+		}
+	}
+}
+
+func TestCLI_Script(t *testing.T) {
+	c, out := newTestCLI(t)
+	script := strings.Join([]string{
+		"# 添加用户后按用户名查询",
+		"user add --id 3 --name 'lzb 3' --sex 男",
+		"user find --name \"lzb 3\"",
+		"user get --id 404",
+		"user list",
+	}, "\n")
+	err := c.runScript(strings.NewReader(script), false, true)
+	if err == nil || !strings.HasPrefix(err.Error(), "line 4:") {
+		t.Fatalf("expected failure on line 4, got %v", err)
+	}
+	if !strings.Contains(out.String(), `"name": "lzb 3"`) {
+		t.Fatalf("expected quoted name in output:\n%s", out)
+	}
+	if strings.Contains(out.String(), "> user list") {
+		t.Fatal("script should stop on first error")
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	tests := map[string][]string{
+		`user add --id 3`:           {"user", "add", "--id", "3"},
+		`a "b c" 'd "e"'`:           {"a", "b c", `d "e"`},
+		`invoke addUser {\"id\":1}`: {"invoke", "addUser", `{"id":1}`},
+		`a ""`:                      {"a", ""},
+	}
+	for line, expected := range tests {
+		actual, err := splitArgs(line)
+		if err != nil || !reflect.DeepEqual(actual, expected) {
+			t.Errorf("splitArgs(%q) = %q, %v", line, actual, err)
+		}
+	}
+	if _, err := splitArgs(`a "b`); err == nil {
+		t.Error("expected unterminated quote error")
+	}
+}
@@ -0,0 +1,128 @@
+// ccctl 在本地持久化的模拟账本上调用 User 与 Example 链码,无需 Fabric 网络即可复现问题。
+//
+// 用法:
+//
+//	ccctl [全局参数] <链码> <命令> [命令参数]
+//	ccctl user add --id 3 --name x --sex 男
+//	ccctl example range --start name1 --end name3
+//	ccctl -f script.txt          # 逐行执行脚本
+//	ccctl                        # 进入交互模式
+package main
+
+import (
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	examplecc "github.com/lzb13612/Example-Chaincode/example/chaincode"
+	"github.com/lzb13612/Example-Chaincode/internal/creator"
+	"github.com/lzb13612/Example-Chaincode/internal/legacy"
+	"github.com/lzb13612/Example-Chaincode/internal/mockledger"
+	usercc "github.com/lzb13612/Example-Chaincode/user/chaincode"
+)
+
+// title		main -> 入口
+// description	解析全局参数,打开账本后执行单条命令、脚本或交互模式
+// auth			lzb
+func main() {
+	dataDir := flag.String("data", ".ccctl", "账本持久化目录,为空时仅保存在内存中")
+	mspId := flag.String("msp", "Org1MSP", "调用者 MSP ID")
+	commonName := flag.String("cn", "ccctl", "调用者证书 CN")
+	admin := flag.Bool("admin", false, "调用者是否携带 role=admin 属性,证书中的角色只对部署组织的身份生效")
+	auditor := flag.Bool("auditor", false, "调用者是否携带 role=auditor 属性(可查询其他租户),证书中的角色只对部署组织的身份生效")
+	tenant := flag.String("tenant", "", "显式指定租户,作为 tenant 临时数据传入,为空时使用调用者的 MSP ID")
+	encryptionKey := flag.String("key", "", "用户加密密钥(base64),作为 userEncryptionKey 临时数据传入")
+	script := flag.String("f", "", "脚本文件,每行一条命令,- 表示标准输入")
+	keepGoing := flag.Bool("k", false, "脚本模式下命令失败后继续执行")
+	config := flag.String("config", "", "创建 User 账本时的链码配置 JSON,例如 {\"requireOrg\":false},已有账本忽略")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: ccctl [flags] <chaincode> <command> [args]\n\nflags:\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(flag.CommandLine.Output(), "\n%s", commandHelp())
+	}
+	flag.Parse()
+
+	c, err := newCLI(*dataDir, *mspId, *commonName, *admin, *auditor, *encryptionKey, *tenant, *config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	c.out, c.errOut = os.Stdout, os.Stderr
+
+	switch {
+	case *script != "":
+		input := os.Stdin
+		if *script != "-" {
+			if input, err = os.Open(*script); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			defer input.Close()
+		}
+		err = c.runScript(input, false, !*keepGoing)
+	case flag.NArg() == 0:
+		err = c.runScript(os.Stdin, isTerminal(os.Stdin), false)
+	default:
+		err = c.run(flag.Args())
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+// newCLI 创建调用者身份并打开 User 与 Example 账本,config 非空时作为新建 User 账本的链码配置
+func newCLI(dataDir, mspId, commonName string, admin, auditor bool, encryptionKey, tenant, config string) (*cli, error) {
+	var attrs map[string]string
+	switch {
+	case admin:
+		attrs = map[string]string{"role": "admin"}
+	case auditor:
+		attrs = map[string]string{"role": "auditor"}
+	}
+	identity, err := creator.New(mspId, commonName, attrs)
+	if err != nil {
+		return nil, fmt.Errorf("create identity error:%s", err)
+	}
+	c := &cli{ledgers: make(map[string]*mockledger.Ledger), transient: make(map[string][]byte)}
+	if encryptionKey != "" {
+		key, err := base64.StdEncoding.DecodeString(encryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("decode key error:%s", err)
+		}
+		c.transient["userEncryptionKey"] = key
+	}
+	if tenant != "" {
+		c.transient["tenant"] = []byte(tenant)
+	}
+	chaincodes := map[string]func() (*legacy.Chaincode, error){
+		"user":    usercc.NewChaincode,
+		"example": examplecc.NewChaincode,
+	}
+	for name, newChaincode := range chaincodes {
+		cc, err := newChaincode()
+		if err != nil {
+			return nil, err
+		}
+		path := ""
+		if dataDir != "" {
+			path = filepath.Join(dataDir, name+".json")
+		}
+		var initArgs []string
+		if name == "user" && config != "" {
+			initArgs = []string{config}
+		}
+		if c.ledgers[name], err = mockledger.New(name, cc, identity, path, initArgs...); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// isTerminal 判断输入是否为交互终端,交互时显示提示符
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
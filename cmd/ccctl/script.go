@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// runScript
+// @title		runScript -> 执行脚本
+// @description	逐行读取并执行命令,空行与 # 开头的注释行被忽略;交互模式下显示提示符并在出错后继续。
+// @auth		lzb
+// @param		input		io.Reader	"命令来源"
+// @param		interactive	布尔		"是否显示提示符"
+// @param		stopOnError	布尔		"命令失败时是否停止执行"
+// @return		err			错误			"第一条失败命令的错误(带行号)"
+func (c *cli) runScript(input io.Reader, interactive, stopOnError bool) error {
+	scanner := bufio.NewScanner(input)
+	var failed error
+	for line := 1; ; line++ {
+		if interactive {
+			fmt.Fprint(c.out, "ccctl> ")
+		}
+		if !scanner.Scan() {
+			break
+		}
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if text == "exit" || text == "quit" {
+			break
+		}
+		if !interactive {
+			fmt.Fprintf(c.out, "> %s\n", text)
+		}
+		args, err := splitArgs(text)
+		if err == nil {
+			err = c.run(args)
+		}
+		if err == nil {
+			continue
+		}
+		fmt.Fprintf(c.errOut, "Error: %s\n", err)
+		if failed == nil {
+			failed = fmt.Errorf("line %d: %s", line, err)
+		}
+		if stopOnError {
+			return failed
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if interactive {
+		return nil
+	}
+	return failed
+}
+
+// splitArgs 按空白拆分命令行,支持单引号、双引号与反斜杠转义
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg, escaped := false, false
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote or escape")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/lzb13612/Example-Chaincode/internal/mockledger"
+	usercc "github.com/lzb13612/Example-Chaincode/user/chaincode"
+)
+
+// encryptionKeyHeader 携带用户加密密钥(base64)的请求头,作为 userEncryptionKey 临时数据传给链码
+const encryptionKeyHeader = "X-User-Encryption-Key"
+
+// tenantHeader 显式指定租户的请求头,作为 tenant 临时数据传给链码,未提供时使用调用者的 MSP ID
+const tenantHeader = "X-Tenant"
+
+// handler HTTP 接口
+type handler struct {
+	ledgers map[string]*mockledger.Ledger // 链码名称 -> 账本
+}
+
+// invokeRequest 通用调用的请求体
+type invokeRequest struct {
+	Args []string `json:"args"`
+}
+
+// errorResponse 错误响应体
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewHandler
+// @title		NewHandler -> 创建 HTTP 接口
+// @description	用户资源接口:GET/POST /users,GET/PATCH/DELETE /users/{id},GET /users?name= 按用户名查询;
+// @description	通用调用接口:POST /invoke/{chaincode}/{function},请求体为 {"args":[...]}。
+// @auth		lzb
+// @param		userLedger		*mockledger.Ledger	"运行 User 链码的账本"
+// @param		exampleLedger	*mockledger.Ledger	"运行 Example 链码的账本"
+// @return		handler			http.Handler		"HTTP 接口"
+func NewHandler(userLedger, exampleLedger *mockledger.Ledger) http.Handler {
+	h := &handler{ledgers: map[string]*mockledger.Ledger{"user": userLedger, "example": exampleLedger}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users", h.users)
+	mux.HandleFunc("/users/", h.user)
+	mux.HandleFunc("/invoke/", h.invoke)
+	return mux
+}
+
+// users 处理 /users
+func (h *handler) users(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if name := r.URL.Query().Get("name"); name != "" {
+			h.call(w, r, http.StatusOK, http.StatusBadRequest, "user", "queryUserByName", userArg(usercc.UserInfo{Name: name}))
+			return
+		}
+		h.call(w, r, http.StatusOK, http.StatusBadRequest, "user", "queryAllUser")
+	case http.MethodPost:
+		var user usercc.UserInfo
+		if !decodeBody(w, r, &user) {
+			return
+		}
+		// 链码配置了 idGenerator 时不提供id,由链码分配
+		payload, status, message := h.execute(r, http.StatusConflict, "user", "addUser", userArg(user))
+		if status != http.StatusOK {
+			writeError(w, status, message)
+			return
+		}
+		var added usercc.UserId
+		if err := json.Unmarshal(payload, &added); err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("unmarshal add user payload error:%s", err))
+			return
+		}
+		h.call(w, r, http.StatusCreated, http.StatusNotFound, "user", "queryOnceUser", userArg(usercc.UserInfo{Id: added.Id}))
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+// user 处理 /users/{id}
+func (h *handler) user(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/users/")
+	if id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	idArg := userArg(usercc.UserInfo{Id: id})
+	switch r.Method {
+	case http.MethodGet:
+		h.call(w, r, http.StatusOK, http.StatusNotFound, "user", "queryOnceUser", idArg)
+	case http.MethodPatch:
+		// 只修改请求体中出现的字段,其余字段沿用账本中的值
+		payload, status, message := h.execute(r, http.StatusNotFound, "user", "queryOnceUser", idArg)
+		if status != http.StatusOK {
+			writeError(w, status, message)
+			return
+		}
+		var user usercc.UserInfo
+		if err := json.Unmarshal(payload, &user); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !decodeBody(w, r, &user) {
+			return
+		}
+		user.Id = id
+		if _, status, message := h.execute(r, http.StatusNotFound, "user", "alterUser", userArg(user)); status != http.StatusOK {
+			writeError(w, status, message)
+			return
+		}
+		h.call(w, r, http.StatusOK, http.StatusNotFound, "user", "queryOnceUser", idArg)
+	case http.MethodDelete:
+		h.call(w, r, http.StatusNoContent, http.StatusNotFound, "user", "delUser", idArg)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPatch, http.MethodDelete)
+	}
+}
+
+// invoke 处理 /invoke/{chaincode}/{function}
+func (h *handler) invoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/invoke/"), "/")
+	if len(parts) != 2 || parts[1] == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if _, ok := h.ledgers[parts[0]]; !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("chaincode %s not found", parts[0]))
+		return
+	}
+	var req invokeRequest
+	if r.ContentLength != 0 && !decodeBody(w, r, &req) {
+		return
+	}
+	h.call(w, r, http.StatusOK, http.StatusBadRequest, parts[0], parts[1], req.Args...)
+}
+
+// call 调用链码并写出响应,成功时使用 okStatus 作为状态码,requestStatus 见 statusForResponse
+func (h *handler) call(w http.ResponseWriter, r *http.Request, okStatus, requestStatus int, chaincode, function string, args ...string) {
+	payload, status, message := h.execute(r, requestStatus, chaincode, function, args...)
+	if status != http.StatusOK {
+		writeError(w, status, message)
+		return
+	}
+	if okStatus == http.StatusNoContent || len(payload) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(okStatus)
+	_, _ = w.Write(payload)
+}
+
+// execute 调用链码,返回载荷、HTTP 状态码与错误信息,requestStatus 见 statusForResponse
+func (h *handler) execute(r *http.Request, requestStatus int, chaincode, function string, args ...string) ([]byte, int, string) {
+	transient, err := transientFromRequest(r)
+	if err != nil {
+		return nil, http.StatusBadRequest, err.Error()
+	}
+	res := h.ledgers[chaincode].Invoke(function, args, transient)
+	if res.Status != shim.OK {
+		return nil, statusForResponse(res, requestStatus), res.Message
+	}
+	return res.Payload, http.StatusOK, ""
+}
+
+// transientFromRequest 从请求头读取加密密钥与租户
+func transientFromRequest(r *http.Request) (map[string][]byte, error) {
+	transient := make(map[string][]byte)
+	if value := r.Header.Get(encryptionKeyHeader); value != "" {
+		key, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("decode %s error:%s", encryptionKeyHeader, err)
+		}
+		transient["userEncryptionKey"] = key
+	}
+	if tenant := r.Header.Get(tenantHeader); tenant != "" {
+		transient["tenant"] = []byte(tenant)
+	}
+	if len(transient) == 0 {
+		return nil, nil
+	}
+	return transient, nil
+}
+
+// statusForResponse
+// @title		statusForResponse -> 选择错误响应的 HTTP 状态码
+// @description	按链码响应状态选择:ERRORTHRESHOLD 为旧版函数的请求错误(参数不足、用户已存在或不存在),网关为资源接口构造的参数总是完整的,
+// @description	因此由调用处按函数给出状态码,例如 addUser 为 409、queryOnceUser 为 404,通用调用接口为 400;ERROR 等其他状态为 500。
+// @auth		lzb
+// @param		res				peer.Response	"链码响应"
+// @param		requestStatus	整型				"ERRORTHRESHOLD 对应的状态码"
+// @return		status			整型				"HTTP 状态码"
+func statusForResponse(res peer.Response, requestStatus int) int {
+	if res.Status == shim.ERRORTHRESHOLD {
+		return requestStatus
+	}
+	return http.StatusInternalServerError
+}
+
+// userArg 序列化旧版函数使用的 UserInfo JSON 参数
+func userArg(user usercc.UserInfo) string {
+	userBytes, _ := json.Marshal(user)
+	return string(userBytes)
+}
+
+// decodeBody 解析 JSON 请求体,失败时写出 400 响应
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	body, err := ioutil.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(body, v)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body:%s", err))
+		return false
+	}
+	return true
+}
+
+// methodNotAllowed 写出 405 响应
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+}
+
+// writeError 写出 JSON 格式的错误响应
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(errorResponse{Error: message})
+}
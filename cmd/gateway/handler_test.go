@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	examplecc "github.com/lzb13612/Example-Chaincode/example/chaincode"
+	"github.com/lzb13612/Example-Chaincode/internal/creator"
+	usercc "github.com/lzb13612/Example-Chaincode/user/chaincode"
+)
+
+// newTestHandler 创建使用内存账本的 HTTP 接口,User 账本不要求用户属于组织
+func newTestHandler(t *testing.T) http.Handler {
+	identity := creator.MustNew("Org1MSP", "lzb", nil)
+	userLedger, err := newLedger("user", usercc.NewChaincode, identity, "", `{"requireOrg":false}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exampleLedger, err := newLedger("example", examplecc.NewChaincode, identity, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewHandler(userLedger, exampleLedger)
+}
+
+// do 发送请求并返回响应
+func do(h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestGateway_UserLifecycle(t *testing.T) {
+	h := newTestHandler(t)
+
+	rec := do(h, http.MethodPost, "/users", `{"id":"3","name":"lzb3","sex":"男"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /users: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(h, http.MethodPost, "/users", `{"id":"3","name":"lzb3","sex":"男"}`); rec.Code != http.StatusConflict {
+		t.Fatalf("duplicate POST /users: %d %s", rec.Code, rec.Body)
+	}
+
+	rec = do(h, http.MethodPatch, "/users/3", `{"name":"lzb33"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PATCH /users/3: %d %s", rec.Code, rec.Body)
+	}
+	var user usercc.UserInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "lzb33" || user.Sex != "男" {
+		t.Fatalf("PATCH should keep unspecified fields, got %+v", user)
+	}
+
+	rec = do(h, http.MethodGet, "/users", "")
+	var users []usercc.UserInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &users); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 3 {
+		t.Fatalf("expected 3 users, got %d", len(users))
+	}
+
+	if rec := do(h, http.MethodDelete, "/users/3", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE /users/3: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(h, http.MethodGet, "/users/3", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("GET deleted user: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestGateway_BadRequests(t *testing.T) {
+	h := newTestHandler(t)
+	if rec := do(h, http.MethodPost, "/users", `{`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("malformed body: %d", rec.Code)
+	}
+	// 状态码按链码响应状态选择,不解析错误信息:以 ERROR 返回的错误为 500
+	if rec := do(h, http.MethodPost, "/users", `{"name":"x"}`); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("missing id: %d", rec.Code)
+	}
+	// 通用调用接口中以 ERRORTHRESHOLD 返回的请求错误为 400
+	if rec := do(h, http.MethodPost, "/invoke/user/queryOnceUser", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invoke without args: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(h, http.MethodPut, "/users/1", `{}`); rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("unsupported method: %d", rec.Code)
+	}
+}
+
+func TestGateway_Invoke(t *testing.T) {
+	h := newTestHandler(t)
+	rec := do(h, http.MethodPost, "/invoke/example/getState", "")
+	if rec.Code != http.StatusOK || rec.Body.String() != `"value"` {
+		t.Fatalf("invoke example getState: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(h, http.MethodPost, "/invoke/user/queryOnceUser", `{"args":["{\"id\":\"1\"}"]}`); rec.Code != http.StatusOK {
+		t.Fatalf("invoke user queryOnceUser: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(h, http.MethodPost, "/invoke/missing/x", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown chaincode: %d", rec.Code)
+	}
+}
+
+func TestGateway_TenantHeader(t *testing.T) {
+	h := newTestHandler(t)
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set(tenantHeader, "Org2MSP")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "permission denied") {
+		t.Fatalf("GET /users of another tenant: %d %s", rec.Code, rec.Body)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set(tenantHeader, "Org1MSP")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"lzb1"`) {
+		t.Fatalf("GET /users of own tenant: %d %s", rec.Code, rec.Body)
+	}
+}
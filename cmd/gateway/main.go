@@ -0,0 +1,70 @@
+// gateway 在进程内运行 User 与 Example 链码,并通过 HTTP/JSON 接口对外提供访问,供前端在没有 Fabric 网络时开发调试。
+//
+// 用法:
+//
+//	go run ./cmd/gateway -addr :8080 -data ./data
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"path/filepath"
+
+	examplecc "github.com/lzb13612/Example-Chaincode/example/chaincode"
+	"github.com/lzb13612/Example-Chaincode/internal/creator"
+	"github.com/lzb13612/Example-Chaincode/internal/legacy"
+	"github.com/lzb13612/Example-Chaincode/internal/mockledger"
+	usercc "github.com/lzb13612/Example-Chaincode/user/chaincode"
+)
+
+// title		main -> 启动网关
+// description	创建 User 与 Example 账本并监听 HTTP 请求
+// auth			lzb
+func main() {
+	addr := flag.String("addr", ":8080", "HTTP 监听地址")
+	dataDir := flag.String("data", "", "账本持久化目录,为空时仅保存在内存中")
+	mspId := flag.String("msp", "Org1MSP", "调用者 MSP ID")
+	commonName := flag.String("cn", "gateway", "调用者证书 CN")
+	admin := flag.Bool("admin", false, "调用者是否携带 role=admin 属性,证书中的角色只对部署组织的身份生效")
+	config := flag.String("config", "", "创建 User 账本时的链码配置 JSON,例如 {\"requireOrg\":false},已有账本忽略")
+	flag.Parse()
+
+	var attrs map[string]string
+	if *admin {
+		attrs = map[string]string{"role": "admin"}
+	}
+	identity, err := creator.New(*mspId, *commonName, attrs)
+	if err != nil {
+		log.Fatalf("create identity error:%s", err)
+	}
+
+	var initArgs []string
+	if *config != "" {
+		initArgs = []string{*config}
+	}
+	userLedger, err := newLedger("user", usercc.NewChaincode, identity, *dataDir, initArgs...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	exampleLedger, err := newLedger("example", examplecc.NewChaincode, identity, *dataDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("gateway listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, NewHandler(userLedger, exampleLedger)))
+}
+
+// newLedger 创建运行指定链码的账本,dataDir 非空时持久化到 <dataDir>/<name>.json,initArgs 为新建账本时的初始化参数
+func newLedger(name string, newChaincode func() (*legacy.Chaincode, error), identity []byte, dataDir string, initArgs ...string) (*mockledger.Ledger, error) {
+	cc, err := newChaincode()
+	if err != nil {
+		return nil, err
+	}
+	path := ""
+	if dataDir != "" {
+		path = filepath.Join(dataDir, name+".json")
+	}
+	return mockledger.New(name, cc, identity, path, initArgs...)
+}
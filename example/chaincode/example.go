@@ -0,0 +1,362 @@
+// @Title		参数读取API与账本状态交互API
+// @Author		lzb
+// @Description	学习使用链码API
+package chaincode
+
+// 导入 shim 链码API库,及合约API库
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-contract-api-go/metadata"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/lzb13612/Example-Chaincode/internal/iterate"
+	"github.com/lzb13612/Example-Chaincode/internal/legacy"
+)
+
+// maxResults 示例查询最多输出的结果数,超过时返回 *iterate.LimitError
+const maxResults = 100
+
+// ExampleContract 链码API示例合约
+type ExampleContract struct {
+	contractapi.Contract
+}
+
+// NewExampleContract
+// @title		NewExampleContract -> 创建示例合约
+// @description	创建合约;旧版函数名由 NewChaincode 在合约之前分派,其余未知函数返回找不到函数的错误。
+// @auth		lzb
+// @return		contract	*ExampleContract	"示例合约"
+func NewExampleContract() *ExampleContract {
+	contract := new(ExampleContract)
+	contract.Info = metadata.InfoMetadata{
+		Title:       "Example",
+		Description: "链码API示例",
+		Version:     "2.0.0",
+	}
+	contract.UnknownTransaction = contract.unknownTransaction
+	return contract
+}
+
+// NewChaincode
+// @title		NewChaincode -> 创建示例链码
+// @description	创建示例合约链码,旧版函数名在合约之前按原样分派,返回 Fabric 1.x 链码的状态码与信息。
+// @auth		lzb
+// @return		cc		*legacy.Chaincode	"示例链码"
+// @return		err		错误					"合约元数据错误"
+func NewChaincode() (*legacy.Chaincode, error) {
+	contract := NewExampleContract()
+	cc, err := contractapi.NewChaincode(contract)
+	if err != nil {
+		return nil, err
+	}
+	return legacy.New(cc, contract.legacyFunctions()), nil
+}
+
+// InitLedger
+// @title		InitLedger -> 初始化
+// @description	写入三个示例数据状态。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @return		err		错误			"初始化失败的原因"
+func (e *ExampleContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
+	stub := ctx.GetStub()
+	// 创建复合主键
+	userKey, err := stub.CreateCompositeKey("name", []string{"lzb"})
+	// 判断错误
+	if err != nil {
+		return fmt.Errorf("create name key error:%s", err)
+	}
+	// 序列化
+	value, _ := json.Marshal("value")
+	// 上传数据状态
+	err = stub.PutState(userKey, value)
+	if err != nil {
+		return fmt.Errorf("put name key and info error:%s", err)
+	}
+
+	// 创建复合主键
+	userKey, err = stub.CreateCompositeKey("name", []string{"lzb1"})
+	// 判断错误
+	if err != nil {
+		return fmt.Errorf("create name key error:%s", err)
+	}
+	// 序列化
+	value, _ = json.Marshal("value1")
+	// 上传数据状态
+	err = stub.PutState(userKey, value)
+	if err != nil {
+		return fmt.Errorf("put name key and info error:%s", err)
+	}
+
+	// 创建复合主键
+	userKey, err = stub.CreateCompositeKey("name", []string{"lzb2"})
+	// 判断错误
+	if err != nil {
+		return fmt.Errorf("create name key error:%s", err)
+	}
+	// 序列化
+	value, _ = json.Marshal("value2")
+	// 上传数据状态
+	err = stub.PutState(userKey, value)
+	if err != nil {
+		return fmt.Errorf("put name key and info error:%s", err)
+	}
+
+	return nil
+}
+
+/*=====================================================================	*
+ *							参数读取系列                                 	*
+ *=====================================================================	*/
+
+// legacyFunctions
+// @title		legacyFunctions -> 旧版函数表
+// @description	Fabric 1.x 版本的函数名到合约函数的映射,成功时的响应信息与旧版链码一致。
+// @auth		lzb
+// @return		functions	map	"函数名 -> 旧版函数"
+func (e *ExampleContract) legacyFunctions() map[string]legacy.Function {
+	// noPayload 将没有返回值的合约函数包装为旧版函数
+	noPayload := func(function func(contractapi.TransactionContextInterface) error) func(contractapi.TransactionContextInterface, []string) ([]byte, error) {
+		return func(ctx contractapi.TransactionContextInterface, _ []string) ([]byte, error) {
+			return nil, function(ctx)
+		}
+	}
+	return map[string]legacy.Function{
+		"init":                          {Message: "init success", Invoke: noPayload(e.InitLedger)},
+		"createCompositeKey":            {Message: "createCompositeKey success", Invoke: noPayload(e.CreateCompositeKey)},
+		"putState":                      {Message: "put state success", Invoke: noPayload(e.PutState)},
+		"delState":                      {Message: "delete state success", Invoke: noPayload(e.DelState)},
+		"getStateByPartialCompositeKey": {Message: "get state by partial composite key success", Invoke: noPayload(e.GetStateByPartialCompositeKey)},
+		"getHistoryForKey":              {Message: "get history state by key success", Invoke: noPayload(e.GetHistoryForKey)},
+		"getStateByRange":               {Message: "get state by range success", Invoke: noPayload(e.GetStateByRange)},
+		"getState": {Message: "get state success", Invoke: func(ctx contractapi.TransactionContextInterface, _ []string) ([]byte, error) {
+			name, err := e.GetState(ctx)
+			if err != nil {
+				return nil, err
+			}
+			// 旧版返回 JSON 序列化后的数据状态
+			return json.Marshal(name)
+		}},
+	}
+}
+
+// unknownTransaction
+// @title		unknownTransaction -> 未知函数
+// @description	合约与旧版函数表中都找不到请求的函数时返回错误,信息与 Fabric 1.x 链码一致。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @return		err		错误			"找不到函数"
+func (e *ExampleContract) unknownTransaction(ctx contractapi.TransactionContextInterface) error {
+	funcName, _ := ctx.GetStub().GetFunctionAndParameters()
+	return fmt.Errorf("not find function:%s", funcName)
+}
+
+/*=====================================================================	*
+ *							创建功能系列                                 	*
+ *=====================================================================	*/
+
+// @title		CreateCompositeKey -> 创建主键
+// @description	创建一个复合键。
+// @auth		lzb
+// @param		objectType 	字符串	"键名"
+//				attributes	字符组	"值"
+//				ctx		交易上下文	"包含所有链码API的库"
+// @return		err		错误			"失败的原因"
+func (e *ExampleContract) CreateCompositeKey(ctx contractapi.TransactionContextInterface) error {
+	stub := ctx.GetStub()
+	// 主键名称
+	indexName := "sex~name"
+	// 创建复合主键
+	indexKey, err := stub.CreateCompositeKey(indexName, []string{"boy", "lzb3"})
+	// 判断错误
+	if err != nil {
+		fmt.Println(err)
+	}
+	// 输出主键信息
+	fmt.Println("indexKey:", indexKey)
+	// 也可创建多个主键
+	indexKey, err = stub.CreateCompositeKey(indexName, []string{"girl", "lzb4"})
+	fmt.Println("indexKey:", indexKey)
+	return nil
+}
+
+// @title		PutState -> 存入数据状态
+// @description	根据指定的key，将对应的value保存在分类账本中。
+// @auth		lzb
+// @param		key 	字符串	"键名"
+//				value	字符组	"值"
+//				ctx		交易上下文	"包含所有链码API的库"
+// @return		err		错误			"失败的原因"
+func (e *ExampleContract) PutState(ctx contractapi.TransactionContextInterface) error {
+	stub := ctx.GetStub()
+	// 创建复合主键
+	indexKey, err := stub.CreateCompositeKey("name", []string{"lzb5"})
+	// 判断错误
+	if err != nil {
+		return fmt.Errorf("create name key error:%s", err)
+	}
+	// 序列化
+	value, _ := json.Marshal("value")
+	// 输出主键信息
+	fmt.Println("indexKey:", indexKey)
+	// 接收错误
+	if err := stub.PutState(indexKey, value); err != nil {
+		return fmt.Errorf("put state error:%s", err)
+	}
+	// 测试刚上传数据是否完成
+	bytes, err := stub.GetState(indexKey)
+	if err != nil {
+		return fmt.Errorf("get name key state error:%s", err)
+	}
+	// 反序列化
+	var names string
+	_ = json.Unmarshal(bytes, &names)
+	fmt.Println("测试获取到的name:", names)
+	// 返回成功信息
+	return nil
+}
+
+/*=====================================================================	*
+ *							删除功能系列                                 	*
+ *=====================================================================	*/
+// @title		DelState -> 删除账本里某个数据状态
+// @description 根据指定的key将对应的数据状态删除
+// @author		lzb
+// @param		key 	字符串	"键名"
+//				ctx		交易上下文	"包含所有链码API的库"
+// @return		err		错误			"失败的原因"
+func (e *ExampleContract) DelState(ctx contractapi.TransactionContextInterface) error {
+	stub := ctx.GetStub()
+	userKey, err := stub.CreateCompositeKey("name", []string{"lzb"})
+	// 接收错误
+	err = stub.DelState(userKey)
+	// 判断错误
+	if err != nil {
+		return fmt.Errorf("delete state error:%s", err)
+	}
+	return nil
+}
+
+/*=====================================================================	*
+ *							查询功能系列                                 	*
+ *=====================================================================	*/
+// @title		GetState -> 获取账本里某个数据状态
+// @description	根据指定的key查询相应的数据状态
+// @auth		lzb
+// @param		key		字符串	"键名"
+//				ctx		交易上下文	"包含所有链码API的库"
+// @return		name	字符串		"数据状态"
+func (e *ExampleContract) GetState(ctx contractapi.TransactionContextInterface) (string, error) {
+	stub := ctx.GetStub()
+	// 创建复合键
+	userKey, err := stub.CreateCompositeKey("name", []string{"lzb"})
+	// 判断错误
+	if err != nil {
+		return "", fmt.Errorf("create name key error:%s", err)
+	}
+	// 接收字符组和错误
+	userBytes, err := stub.GetState(userKey)
+	// 判断错误
+	if err != nil {
+		return "", fmt.Errorf("get state error:%s", err)
+	}
+	// 定义接收内容的变量
+	var name string
+	// 反序列化
+	if err := json.Unmarshal(userBytes, &name); err != nil {
+		return "", fmt.Errorf("unmarshal name error:%s", err)
+	}
+	fmt.Println("name:", name)
+	return name, nil
+}
+
+// @title		GetStateByRange -> 起止键区间查询数据状态
+// @description 查询指定范围内的键值，startKey为起始key，endKey为终止key
+// @author		lzb
+// @param		startKey	字符串	"开始的键名"
+//				endKey		字符串	"结束的键名"
+//				ctx			交易上下文	"包含所有链码API的库"
+// @return		err			错误			"失败的原因"
+func (e *ExampleContract) GetStateByRange(ctx contractapi.TransactionContextInterface) error {
+	stub := ctx.GetStub()
+	// 创建三个测试用的数据
+	_ = stub.PutState("name1", []byte("lzb1"))
+	_ = stub.PutState("name2", []byte("lzb2"))
+	_ = stub.PutState("name3", []byte("lzb3"))
+	// 获取name1到name3之间的值
+	resultIterator, err := stub.GetStateByRange("name1", "name3")
+	// 判断错误
+	if err != nil {
+		return fmt.Errorf("get state by range error:%s", err)
+	}
+	fmt.Println("-----start resultIterator-----")
+	// 遍历迭代器,遍历结束或出错时自动关闭迭代器
+	err = iterate.States(resultIterator, maxResults, func(item *queryresult.KV) error {
+		fmt.Println(string(item.Value))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("get state by range error:%s", err)
+	}
+	fmt.Println("-----end resultIterator-----")
+	return nil
+}
+
+// @title		GetStateByPartialCompositeKey -> 复合键查询
+// @description 根据局部的复合键（前缀）返回所有匹配的键值，即与账本中的键进行前缀匹配，
+//				返回结果是一个迭代器结构，可以按照字典序迭代每个键值对，最后需要调用 Close() 方法关闭
+//				注意:该方法的使用需要节点配置中打开历史数据库特性
+// @author		lzb
+// @param		objectType	字符串	"键名"
+//				keys		字符串组	"键名对应的值"
+//				ctx			交易上下文	"包含所有链码API的库"
+// @return		err			错误			"失败的原因"
+func (e *ExampleContract) GetStateByPartialCompositeKey(ctx contractapi.TransactionContextInterface) error {
+	stub := ctx.GetStub()
+	// 通过复合键获取某键或者所有的数据状态
+	resultsIterator, err := stub.GetStateByPartialCompositeKey("name", []string{})
+	if err != nil {
+		return fmt.Errorf("get name state by partial composite key error:%s", err)
+	}
+	// 遍历迭代器,遍历结束或出错时自动关闭迭代器
+	err = iterate.States(resultsIterator, maxResults, func(val *queryresult.KV) error {
+		fmt.Println(val.Key)
+		fmt.Println(string(val.Value))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("get name state by partial composite key error:%s", err)
+	}
+	return nil
+}
+
+// @title		GetHistoryForKey -> 获取某键的历史数据状态记录
+// @description 根据指定的 key 查询所有的历史记录信息。
+//				注意:该方法的使用需要节点配置中打开历史数据库特性
+// @author		lzb
+// @param		key	字符串	"键名"
+//				ctx		交易上下文	"包含所有链码API的库"
+// @return		err		错误			"失败的原因"
+func (e *ExampleContract) GetHistoryForKey(ctx contractapi.TransactionContextInterface) error {
+	stub := ctx.GetStub()
+	// 获取历史数据状态
+	historyIterator, err := stub.GetHistoryForKey("name")
+	if err != nil {
+		return fmt.Errorf("get history for key error:%s", err)
+	}
+
+	fmt.Println("-----start historyIterator-----")
+	// 遍历迭代器,遍历结束或出错时自动关闭迭代器
+	err = iterate.History(historyIterator, maxResults, func(item *queryresult.KeyModification) error {
+		fmt.Println(string(item.TxId))
+		fmt.Println(string(item.Value))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("history iterator error:%s", err)
+	}
+	fmt.Println("-----end historyIterator-----")
+	return nil
+}
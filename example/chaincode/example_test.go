@@ -0,0 +1,133 @@
+package chaincode
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/lzb13612/Example-Chaincode/internal/creator"
+	"github.com/lzb13612/Example-Chaincode/internal/golden"
+	"github.com/lzb13612/Example-Chaincode/internal/mockledger"
+)
+
+var (
+	id1      = "1"
+	id2      = "2"
+	newName1 = "lzb_new1"
+	newSex1  = ""
+	newAge1  = "50"
+	newName2 = "lzb_new2"
+	newSex2  = "女"
+	newAge2  = "5"
+)
+
+type User1 struct {
+	Name string `json:"name"`
+	Sex  string `json:"sex"`
+	Age  string `json:"age"`
+}
+
+var testCreator = creator.MustNew("Org1MSP", "lzb", nil)
+
+func GetNewStub() *shimtest.MockStub {
+	var scc, _ = NewChaincode()
+	var stub = shimtest.NewMockStub("ex01", scc)
+	stub.Creator = testCreator
+	stub.MockInit("init", [][]byte{[]byte("init")})
+	return stub
+}
+
+// call 一次链码调用及其期望结果
+type call struct {
+	function string // 函数名
+	status   int32  // 期望的状态码
+	payload  string // 期望的载荷,仅在调用成功时比较
+	message  string // 期望错误信息包含的内容,仅在调用失败时比较
+}
+
+// runCalls 在新的账本上依次执行调用,并将每次调用的响应与最终状态和 golden 文件比较
+func runCalls(t *testing.T, calls ...call) {
+	t.Helper()
+	stub := GetNewStub()
+	var responses strings.Builder
+	for i, c := range calls {
+		res := stub.MockInvoke(fmt.Sprintf("tx%d", i+1), [][]byte{[]byte(c.function)})
+		fmt.Fprintf(&responses, "# %s -> %d %q", c.function, res.Status, res.Message)
+		if len(res.Payload) > 0 {
+			fmt.Fprintf(&responses, " %s", res.Payload)
+		}
+		responses.WriteString("\n")
+		if res.Status != c.status {
+			t.Fatalf("call %d %s: expected status %d, got %d (%s)", i+1, c.function, c.status, res.Status, res.Message)
+		}
+		if c.status == shim.OK && string(res.Payload) != c.payload {
+			t.Fatalf("call %d %s: expected payload %s, got %s", i+1, c.function, c.payload, res.Payload)
+		}
+		if c.status != shim.OK && !strings.Contains(res.Message, c.message) {
+			t.Fatalf("call %d %s: expected message containing %q, got %q", i+1, c.function, c.message, res.Message)
+		}
+	}
+	golden.Assert(t, t.Name(), responses.String()+golden.RenderState(stub.State))
+}
+
+func TestExample_init(t *testing.T) {
+	runCalls(t, call{function: "init", status: shim.OK})
+}
+
+func TestExample_createCompositeKey(t *testing.T) {
+	// 只创建复合键,不修改账本
+	runCalls(t, call{function: "createCompositeKey", status: shim.OK})
+}
+
+func TestExample_putState(t *testing.T) {
+	runCalls(t, call{function: "putState", status: shim.OK})
+}
+
+func TestExample_delState(t *testing.T) {
+	runCalls(t,
+		call{function: "getState", status: shim.OK, payload: `"value"`},
+		call{function: "delState", status: shim.OK},
+		call{function: "getState", status: shim.ERROR, message: "unmarshal name error"},
+	)
+}
+
+func TestExample_getState(t *testing.T) {
+	runCalls(t, call{function: "getState", status: shim.OK, payload: `"value"`})
+}
+
+func TestExample_getStateByPartialCompositeKey(t *testing.T) {
+	runCalls(t, call{function: "getStateByPartialCompositeKey", status: shim.OK})
+}
+
+func TestExample_unknownFunction(t *testing.T) {
+	runCalls(t, call{function: "dropAll", status: shim.ERROR, message: "not find function:dropAll"})
+}
+
+// MockStub 不支持历史查询,这里使用从 testdata 导入历史记录的模拟账本
+func TestExample_getHistoryForKey(t *testing.T) {
+	cc, err := NewChaincode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledger, err := mockledger.New("ex01", cc, testCreator, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ledger.LoadFile("testdata/history.json"); err != nil {
+		t.Fatal(err)
+	}
+	if history := ledger.History("name"); len(history) != 3 || !history[0].IsDelete {
+		t.Fatalf("unexpected history %+v", history)
+	}
+	res := ledger.Invoke("getHistoryForKey", nil, nil)
+	if res.Status != shim.OK {
+		t.Fatal(res.Message)
+	}
+}
+
+func TestExample_getStateByRange(t *testing.T) {
+	// 写入 name1 ~ name3 三个测试数据
+	runCalls(t, call{function: "getStateByRange", status: shim.OK})
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/lzb13612/Example-Chaincode/example/chaincode"
+	"github.com/lzb13612/Example-Chaincode/internal/server"
+)
+
+// title		main -> 主方法
+// description	操作功能,设置 CHAINCODE_ID 与 CHAINCODE_SERVER_ADDRESS 时作为外部链码服务运行
+// auth			lzb
+func main() {
+	exampleChaincode, err := chaincode.NewChaincode()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := server.Start(exampleChaincode); err != nil {
+		fmt.Println(err)
+	}
+}
@@ -0,0 +1,67 @@
+// Package creator 生成测试与本地工具使用的 Fabric 调用者身份。
+package creator
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/msp"
+)
+
+// attributeOID Fabric CA 在证书中存放属性的扩展 OID
+var attributeOID = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}
+
+// New
+// @title		New -> 生成调用者身份
+// @description	生成一个自签名的 X.509 证书,并序列化为 SerializedIdentity,可直接作为 MockStub 的 Creator。
+// @auth		lzb
+// @param		mspId		字符串	"MSP ID"
+// @param		commonName	字符串	"证书 CN"
+// @param		attrs		map		"证书属性,与 Fabric CA 的 attrs 扩展格式一致,可为 nil"
+// @return		creator		字符组	"序列化后的身份"
+func New(mspId, commonName string, attrs map[string]string) ([]byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate key error:%s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{mspId}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	if attrs != nil {
+		value, err := json.Marshal(map[string]map[string]string{"attrs": attrs})
+		if err != nil {
+			return nil, fmt.Errorf("marshal attrs error:%s", err)
+		}
+		template.ExtraExtensions = []pkix.Extension{{Id: attributeOID, Value: value}}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("create certificate error:%s", err)
+	}
+	return proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   mspId,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	})
+}
+
+// MustNew 与 New 相同,出错时 panic,用于测试的包级变量
+func MustNew(mspId, commonName string, attrs map[string]string) []byte {
+	creator, err := New(mspId, commonName, attrs)
+	if err != nil {
+		panic(err)
+	}
+	return creator
+}
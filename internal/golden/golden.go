@@ -0,0 +1,67 @@
+// Package golden 将账本状态渲染为可读文本,并与 testdata 下的 golden 文件比较。
+//
+// 使用 go test ./... -update 重新生成 golden 文件。
+package golden
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/lzb13612/Example-Chaincode/internal/mockledger"
+)
+
+// update 为 true 时用实际结果覆盖 golden 文件
+var update = flag.Bool("update", false, "update golden files")
+
+// RenderState
+// @title		RenderState -> 渲染状态
+// @description	按键排序逐行输出 "键 = 值":复合键解码为 objectType[attr1, attr2],JSON 值压缩显示,不可打印的值以十六进制显示。
+// @auth		lzb
+// @param		state	map		"键 -> 值"
+// @return		text	字符串	"渲染结果"
+func RenderState(state map[string][]byte) string {
+	keys := make([]string, 0, len(state))
+	for key := range state {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var out strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&out, "%s = %s\n", mockledger.DecodeKey(key), mockledger.FormatValue(state[key]))
+	}
+	return out.String()
+}
+
+// Assert
+// @title		Assert -> 比较 golden 文件
+// @description	将 actual 与 testdata/<name>.golden 比较,不一致时报告差异;带 -update 运行时写入 actual。
+// @auth		lzb
+// @param		t		*testing.T	"测试"
+// @param		name	字符串		"golden 文件名(不含扩展名),可包含子目录"
+// @param		actual	字符串		"实际结果"
+func Assert(t *testing.T, name, actual string) {
+	t.Helper()
+	path := filepath.Join("testdata", filepath.FromSlash(name)+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(actual), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file %s error:%s (run go test -update to create it)", path, err)
+	}
+	if string(expected) != actual {
+		t.Errorf("state does not match %s\n--- expected\n%s--- actual\n%s", path, expected, actual)
+	}
+}
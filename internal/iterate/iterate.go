@@ -0,0 +1,124 @@
+// Package iterate 遍历链码的状态、历史与富查询迭代器。
+//
+// 所有方法在返回前都会关闭迭代器,并将 Next 与 Close 的错误返回给调用方;
+// visit 返回 ErrStop 时提前结束遍历,limit 大于 0 时结果超过 limit 条返回 *LimitError。
+package iterate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+)
+
+// Unlimited 不限制结果数,用于迁移、级联删除等必须处理全部数据的遍历,以及由 visit 返回 ErrStop 自行控制数量的遍历
+const Unlimited = 0
+
+// ErrStop visit 返回该错误时提前结束遍历,遍历方法本身不返回错误
+var ErrStop = errors.New("stop iteration")
+
+// LimitError 迭代器中的结果超过上限
+type LimitError struct {
+	Limit int // 结果上限
+}
+
+// Error 错误信息
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("query returned more than %d results", e.Limit)
+}
+
+// Iterator 迭代器 -> shim.StateQueryIteratorInterface 与 shim.HistoryQueryIteratorInterface 的共同形式
+type Iterator[T any] interface {
+	HasNext() bool
+	Next() (T, error)
+	Close() error
+}
+
+// Each
+// @title		Each -> 遍历迭代器
+// @description	依次将迭代器中的元素交给 visit 处理,返回前总是关闭迭代器。
+// @auth		lzb
+// @param		it		Iterator	"迭代器"
+// @param		limit	整型			"最多处理的元素数,0 表示不限制"
+// @param		visit	函数			"处理方法,返回 ErrStop 时提前结束"
+// @return		err		错误			"Next、visit 或 Close 返回的错误,超过上限时为 *LimitError"
+func Each[T any](it Iterator[T], limit int, visit func(item T) error) (err error) {
+	defer func() {
+		if closeErr := it.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("close iterator error:%s", closeErr)
+		}
+	}()
+	for count := 0; it.HasNext(); count++ {
+		if limit > 0 && count >= limit {
+			return &LimitError{Limit: limit}
+		}
+		item, err := it.Next()
+		if err != nil {
+			return fmt.Errorf("iterator next error:%s", err)
+		}
+		if err := visit(item); err != nil {
+			if errors.Is(err, ErrStop) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// States 遍历状态或富查询迭代器
+func States(it shim.StateQueryIteratorInterface, limit int, visit func(kv *queryresult.KV) error) error {
+	return Each[*queryresult.KV](it, limit, visit)
+}
+
+// History 遍历历史迭代器,记录按从新到旧排列
+func History(it shim.HistoryQueryIteratorInterface, limit int, visit func(modification *queryresult.KeyModification) error) error {
+	return Each[*queryresult.KeyModification](it, limit, visit)
+}
+
+// Decode
+// @title		Decode -> 遍历并反序列化状态
+// @description	遍历状态或富查询迭代器,将每个值按 JSON 反序列化为 V 后交给 visit 处理。
+// @auth		lzb
+// @param		it		迭代器	"状态或富查询迭代器"
+// @param		limit	整型		"最多处理的元素数,0 表示不限制"
+// @param		visit	函数		"处理方法,参数为原始键与反序列化后的值"
+// @return		err		错误		"遍历或反序列化失败的原因"
+func Decode[V any](it shim.StateQueryIteratorInterface, limit int, visit func(key string, value *V) error) error {
+	return States(it, limit, func(kv *queryresult.KV) error {
+		value := new(V)
+		if err := json.Unmarshal(kv.Value, value); err != nil {
+			return fmt.Errorf("unmarshal value of key %q error:%s", kv.Key, err)
+		}
+		return visit(kv.Key, value)
+	})
+}
+
+// Collect 将状态或富查询迭代器中的值反序列化为列表,没有结果时返回空列表
+func Collect[V any](it shim.StateQueryIteratorInterface, limit int) ([]*V, error) {
+	values := make([]*V, 0)
+	err := Decode(it, limit, func(key string, value *V) error {
+		values = append(values, value)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// DecodeHistory 遍历历史迭代器并反序列化每条记录的值,删除记录的值为 nil
+func DecodeHistory[V any](it shim.HistoryQueryIteratorInterface, limit int, visit func(modification *queryresult.KeyModification, value *V) error) error {
+	return History(it, limit, func(modification *queryresult.KeyModification) error {
+		if modification.IsDelete {
+			return visit(modification, nil)
+		}
+		value := new(V)
+		if err := json.Unmarshal(modification.Value, value); err != nil {
+			return fmt.Errorf("unmarshal value of tx %s error:%s", modification.TxId, err)
+		}
+		return visit(modification, value)
+	})
+}
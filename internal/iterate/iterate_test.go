@@ -0,0 +1,161 @@
+package iterate
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+)
+
+// fakeIterator 测试用迭代器,failAt 之后的 Next 返回错误
+type fakeIterator struct {
+	kvs      []*queryresult.KV
+	next     int
+	failAt   int
+	closeErr error
+	closed   int
+}
+
+func newFakeIterator(values ...string) *fakeIterator {
+	it := &fakeIterator{failAt: -1}
+	for i, value := range values {
+		it.kvs = append(it.kvs, &queryresult.KV{Key: string(rune('a' + i)), Value: []byte(value)})
+	}
+	return it
+}
+
+func (it *fakeIterator) HasNext() bool { return it.next < len(it.kvs) }
+
+func (it *fakeIterator) Next() (*queryresult.KV, error) {
+	if it.next == it.failAt {
+		return nil, errors.New("peer unavailable")
+	}
+	kv := it.kvs[it.next]
+	it.next++
+	return kv, nil
+}
+
+func (it *fakeIterator) Close() error {
+	it.closed++
+	return it.closeErr
+}
+
+// keys 遍历并返回访问到的键
+func keys(t *testing.T, it *fakeIterator, limit int, stopAt string) ([]string, error) {
+	visited := make([]string, 0)
+	err := States(it, limit, func(kv *queryresult.KV) error {
+		if kv.Key == stopAt {
+			return ErrStop
+		}
+		visited = append(visited, kv.Key)
+		return nil
+	})
+	if it.closed != 1 {
+		t.Fatalf("expected iterator to be closed once, closed %d times", it.closed)
+	}
+	return visited, err
+}
+
+func TestStates(t *testing.T) {
+	cases := []struct {
+		name     string
+		limit    int
+		stopAt   string
+		failAt   int
+		closeErr error
+		expected []string
+		message  string
+	}{
+		{name: "all", failAt: -1, expected: []string{"a", "b", "c"}},
+		{name: "stop", stopAt: "b", failAt: -1, expected: []string{"a"}},
+		{name: "withinLimit", limit: 3, failAt: -1, expected: []string{"a", "b", "c"}},
+		{name: "overLimit", limit: 2, failAt: -1, expected: []string{"a", "b"}, message: "more than 2 results"},
+		{name: "nextError", failAt: 1, expected: []string{"a"}, message: "iterator next error:peer unavailable"},
+		{name: "closeError", failAt: -1, closeErr: errors.New("busy"), expected: []string{"a", "b", "c"}, message: "close iterator error:busy"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			it := newFakeIterator("1", "2", "3")
+			it.failAt, it.closeErr = c.failAt, c.closeErr
+			visited, err := keys(t, it, c.limit, c.stopAt)
+			if !reflect.DeepEqual(visited, c.expected) {
+				t.Fatalf("expected %v, got %v", c.expected, visited)
+			}
+			if c.message == "" && err != nil || c.message != "" && (err == nil || !strings.Contains(err.Error(), c.message)) {
+				t.Fatalf("expected error %q, got %v", c.message, err)
+			}
+		})
+	}
+}
+
+func TestStates_VisitError(t *testing.T) {
+	it := newFakeIterator("1", "2")
+	err := States(it, 0, func(kv *queryresult.KV) error { return errors.New("bad value") })
+	if err == nil || err.Error() != "bad value" || it.closed != 1 {
+		t.Fatalf("expected visit error and closed iterator, got %v (closed %d)", err, it.closed)
+	}
+	var limitErr *LimitError
+	if err := States(newFakeIterator("1", "2"), 1, func(kv *queryresult.KV) error { return nil }); !errors.As(err, &limitErr) || limitErr.Limit != 1 {
+		t.Fatalf("expected *LimitError, got %v", err)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	type user struct {
+		Id string `json:"id"`
+	}
+	users, err := Collect[user](newFakeIterator(`{"id":"1"}`, `{"id":"2"}`), 0)
+	if err != nil || len(users) != 2 || users[0].Id != "1" || users[1].Id != "2" {
+		t.Fatalf("unexpected users %v, %v", users, err)
+	}
+	users, err = Collect[user](newFakeIterator(), 0)
+	if err != nil || users == nil || len(users) != 0 {
+		t.Fatalf("expected empty non-nil list, got %v, %v", users, err)
+	}
+	it := newFakeIterator(`{"id":"1"}`, `not json`)
+	if _, err := Collect[user](it, 0); err == nil || !strings.Contains(err.Error(), `key "b"`) || it.closed != 1 {
+		t.Fatalf("expected unmarshal error for key b, got %v", err)
+	}
+}
+
+// fakeHistory 测试用历史迭代器
+type fakeHistory struct {
+	modifications []*queryresult.KeyModification
+	closed        bool
+}
+
+func (it *fakeHistory) HasNext() bool { return len(it.modifications) > 0 }
+
+func (it *fakeHistory) Next() (*queryresult.KeyModification, error) {
+	modification := it.modifications[0]
+	it.modifications = it.modifications[1:]
+	return modification, nil
+}
+
+func (it *fakeHistory) Close() error {
+	it.closed = true
+	return nil
+}
+
+func TestDecodeHistory(t *testing.T) {
+	it := &fakeHistory{modifications: []*queryresult.KeyModification{
+		{TxId: "tx3", IsDelete: true},
+		{TxId: "tx2", Value: []byte(`"lzb2"`)},
+		{TxId: "tx1", Value: []byte(`"lzb1"`)},
+	}}
+	visited := make([]string, 0)
+	err := DecodeHistory(it, 0, func(modification *queryresult.KeyModification, name *string) error {
+		if name == nil {
+			visited = append(visited, modification.TxId+":deleted")
+		} else {
+			visited = append(visited, modification.TxId+":"+*name)
+		}
+		return nil
+	})
+	expected := []string{"tx3:deleted", "tx2:lzb2", "tx1:lzb1"}
+	if err != nil || !reflect.DeepEqual(visited, expected) || !it.closed {
+		t.Fatalf("expected %v, got %v (%v)", expected, visited, err)
+	}
+}
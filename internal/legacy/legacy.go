@@ -0,0 +1,104 @@
+// Package legacy 在合约链码之前分派 Fabric 1.x 的函数名,按旧版链码的状态码与信息返回响应。
+//
+// contractapi 会把小写开头的函数名改为大写后查找合约函数,旧版函数名(如 addUser)因此会直接进入
+// 新版合约函数,得到不同的参数格式与错误;Chaincode 先按函数名原样匹配旧版函数表,其余请求再交给合约链码。
+package legacy
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// Function 旧版函数
+type Function struct {
+	Message string                                                                           // 成功时的响应信息,与旧版链码一致
+	Invoke  func(ctx contractapi.TransactionContextInterface, args []string) ([]byte, error) // 接收原始字符串参数,返回响应载荷
+}
+
+// ThresholdError 请求错误 -> 旧版链码以 shim.ERRORTHRESHOLD(400)返回的错误,如参数不足、用户已存在或不存在
+type ThresholdError struct {
+	Message string // 错误信息
+}
+
+// Error 错误信息
+func (e *ThresholdError) Error() string {
+	return e.Message
+}
+
+// Thresholdf
+// @title		Thresholdf -> 创建请求错误
+// @description	按格式创建旧版以 shim.ERRORTHRESHOLD 返回的错误,新版合约函数中与普通错误相同。
+// @auth		lzb
+// @param		format	字符串	"错误信息格式"
+// @param		args	任意		"格式参数"
+// @return		err		错误		"*ThresholdError"
+func Thresholdf(format string, args ...interface{}) error {
+	return &ThresholdError{Message: fmt.Sprintf(format, args...)}
+}
+
+// Chaincode 兼容旧版函数名的链码
+type Chaincode struct {
+	contract  *contractapi.ContractChaincode
+	functions map[string]Function
+}
+
+// New
+// @title		New -> 创建兼容旧版函数名的链码
+// @description	函数名与旧版函数表中的名称完全相同时调用旧版函数,否则交给合约链码。
+// @auth		lzb
+// @param		contract	*contractapi.ContractChaincode	"合约链码"
+// @param		functions	map								"函数名 -> 旧版函数"
+// @return		cc			*Chaincode						"链码"
+func New(contract *contractapi.ContractChaincode, functions map[string]Function) *Chaincode {
+	return &Chaincode{contract: contract, functions: functions}
+}
+
+// Init
+// @title		Init -> 初始化
+// @description	实例化或升级链码时调用;函数名为空时交给合约链码,否则与 Invoke 相同。
+// @auth		lzb
+// @param 		stub	shim库	"包含所有链码API的库"
+// @return		pb		peer库	"返回状态码和响应信息"
+func (c *Chaincode) Init(stub shim.ChaincodeStubInterface) peer.Response {
+	if function, _ := stub.GetFunctionAndParameters(); function == "" {
+		return c.contract.Init(stub)
+	}
+	return c.Invoke(stub)
+}
+
+// Invoke
+// @title		Invoke -> 调用方法
+// @description	调用旧版函数时,成功返回旧版的响应信息,*ThresholdError 返回 shim.ERRORTHRESHOLD,其余错误返回 shim.ERROR。
+// @auth		lzb
+// @param 		stub	shim库	"包含所有链码API的库"
+// @return		pb		peer库	"返回状态码和响应信息"
+func (c *Chaincode) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
+	name, args := stub.GetFunctionAndParameters()
+	function, ok := c.functions[name]
+	if !ok {
+		return c.contract.Invoke(stub)
+	}
+	ctx := new(contractapi.TransactionContext)
+	ctx.SetStub(stub)
+	identity, err := cid.New(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get client identity error:%s", err))
+	}
+	ctx.SetClientIdentity(identity)
+
+	payload, err := function.Invoke(ctx, args)
+	if err != nil {
+		status := int32(shim.ERROR)
+		var threshold *ThresholdError
+		if errors.As(err, &threshold) {
+			status = shim.ERRORTHRESHOLD
+		}
+		return peer.Response{Status: status, Message: err.Error()}
+	}
+	return peer.Response{Status: shim.OK, Message: function.Message, Payload: payload}
+}
@@ -0,0 +1,121 @@
+package mockledger
+
+import (
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// Simulate
+// @title		Simulate -> 背书模拟
+// @description	在当前已提交的状态上模拟执行提案并记录读写集,但不提交;可将多笔模拟结果交给 CommitBlock 按顺序提交。
+// @auth		lzb
+// @param		proposal	Proposal		"交易提案"
+// @return		tx			*Transaction	"交易ID、时间、响应与读写集"
+func (l *Ledger) Simulate(proposal Proposal) *Transaction {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.simulate(false, proposal)
+}
+
+// CommitBlock
+// @title		CommitBlock -> 提交区块
+// @description	将多笔交易按顺序放入一个区块,像 Fabric 提交节点一样逐笔做 MVCC 校验:读集版本与当前状态(含本区块中之前的有效交易)不一致时标记为 MVCC_READ_CONFLICT,区间查询结果变化时标记为 PHANTOM_READ_CONFLICT,只有有效交易的写集会被提交。
+// @auth		lzb
+// @param		txs		[]*Transaction			"Simulate 得到的交易"
+// @return		codes	[]pb.TxValidationCode	"每笔交易的校验结果,同时写入 Transaction.ValidationCode"
+// @return		err		错误						"持久化失败的原因"
+func (l *Ledger) CommitBlock(txs ...*Transaction) ([]pb.TxValidationCode, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	codes := l.commit(txs)
+	return codes, l.save()
+}
+
+// commit 校验并提交一个区块,调用方需持有锁
+func (l *Ledger) commit(txs []*Transaction) []pb.TxValidationCode {
+	l.blockNum++
+	codes := make([]pb.TxValidationCode, len(txs))
+	inBlock := make(map[string]bool, len(txs))
+	for txNum, tx := range txs {
+		code := l.validate(tx, inBlock)
+		inBlock[tx.ID] = true
+		tx.ValidationCode, codes[txNum] = code, code
+		if code != pb.TxValidationCode_VALID {
+			continue
+		}
+		l.committed[tx.ID] = true
+		if len(tx.RWSet.Writes) > 0 {
+			l.keys = nil
+		}
+		version := Version{BlockNum: l.blockNum, TxNum: uint64(txNum)}
+		for _, write := range tx.RWSet.Writes {
+			if write.IsDelete {
+				delete(l.state, write.Key)
+			} else {
+				l.state[write.Key] = VersionedValue{Value: write.Value, Version: version}
+			}
+			l.changes[write.Key] = append(l.changes[write.Key], Modification{
+				TxID:      tx.ID,
+				Value:     write.Value,
+				Timestamp: tx.Timestamp,
+				IsDelete:  write.IsDelete,
+			})
+		}
+	}
+	return codes
+}
+
+// validate 校验一笔交易;之前的有效交易已写入状态,因此直接与当前状态比较即可
+func (l *Ledger) validate(tx *Transaction, inBlock map[string]bool) pb.TxValidationCode {
+	if l.committed[tx.ID] || inBlock[tx.ID] {
+		return pb.TxValidationCode_DUPLICATE_TXID
+	}
+	// 链码返回错误的交易不会被客户端提交,这里统一标记为无效
+	if tx.Response.Status != shim.OK {
+		return pb.TxValidationCode_INVALID_OTHER_REASON
+	}
+	for _, read := range tx.RWSet.Reads {
+		if !l.versionMatches(read) {
+			return pb.TxValidationCode_MVCC_READ_CONFLICT
+		}
+	}
+	for _, query := range tx.RWSet.RangeQueries {
+		if !l.rangeMatches(query) {
+			return pb.TxValidationCode_PHANTOM_READ_CONFLICT
+		}
+	}
+	return pb.TxValidationCode_VALID
+}
+
+// versionMatches 读取时的版本是否仍是当前版本
+func (l *Ledger) versionMatches(read KVRead) bool {
+	value, ok := l.state[read.Key]
+	if read.Version == nil || !ok {
+		return read.Version == nil && !ok
+	}
+	return *read.Version == value.Version
+}
+
+// rangeMatches 重新执行区间查询,结果(键与版本)与模拟时一致才有效;迭代器未读完时只比较到最后一个读到的键
+func (l *Ledger) rangeMatches(query RangeQuery) bool {
+	endKey := query.EndKey
+	var keys []string
+	if query.ItrExhausted {
+		keys = l.sortedKeys(query.StartKey, endKey)
+	} else {
+		if len(query.Reads) == 0 {
+			return true
+		}
+		last := query.Reads[len(query.Reads)-1].Key
+		keys = l.sortedKeys(query.StartKey, last+"\x00")
+	}
+	if len(keys) != len(query.Reads) {
+		return false
+	}
+	for i, key := range keys {
+		if key != query.Reads[i].Key || !l.versionMatches(query.Reads[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package mockledger
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// simulate 模拟一笔交易并要求背书成功
+func simulate(t *testing.T, ledger *Ledger, function string, args ...string) *Transaction {
+	tx := ledger.Simulate(Proposal{Function: function, Args: args})
+	if tx.Response.Status != shim.OK {
+		t.Fatalf("%s failed: %s", function, tx.Response.Message)
+	}
+	return tx
+}
+
+// commitBlock 提交区块并比较校验结果
+func commitBlock(t *testing.T, ledger *Ledger, expected []pb.TxValidationCode, txs ...*Transaction) {
+	codes, err := ledger.CommitBlock(txs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(codes, expected) {
+		t.Fatalf("expected validation codes %v, got %v", expected, codes)
+	}
+}
+
+func TestCommitBlock_SimulateDoesNotCommit(t *testing.T) {
+	ledger := newUserLedger(t, "")
+	blockNum := ledger.BlockNum()
+	simulate(t, ledger, "addUser", `{"id":"3","name":"lzb3","sex":"男"}`)
+	if _, ok := ledger.Get("user:Org1MSP:n01:3"); ok || ledger.BlockNum() != blockNum {
+		t.Fatal("simulation must not change the ledger")
+	}
+}
+
+func TestCommitBlock_MVCCReadConflict(t *testing.T) {
+	ledger := newUserLedger(t, "")
+	first := simulate(t, ledger, "alterUser", `{"id":"1","name":"lzb1","sex":"女"}`)
+	second := simulate(t, ledger, "alterUser", `{"id":"1","name":"lzb1","sex":"未知"}`)
+	commitBlock(t, ledger, []pb.TxValidationCode{pb.TxValidationCode_VALID, pb.TxValidationCode_MVCC_READ_CONFLICT}, first, second)
+
+	value, _ := ledger.Get("user:Org1MSP:n01:1")
+	if string(value.Value) != `{"id":"1","name":"lzb1","sex":"女"}` {
+		t.Fatalf("only the first transaction should be committed, got %s", value.Value)
+	}
+	if second.ValidationCode != pb.TxValidationCode_MVCC_READ_CONFLICT {
+		t.Fatal("validation code should be recorded on the transaction")
+	}
+	if len(ledger.History("user:Org1MSP:n01:1")) != 2 {
+		t.Fatal("invalid transaction must not appear in history")
+	}
+
+	// 冲突交易按最新状态重新模拟后可以提交
+	retry := simulate(t, ledger, "alterUser", `{"id":"1","name":"lzb1","sex":"未知"}`)
+	commitBlock(t, ledger, []pb.TxValidationCode{pb.TxValidationCode_VALID}, retry)
+}
+
+func TestCommitBlock_AcrossBlocks(t *testing.T) {
+	ledger := newUserLedger(t, "")
+	first := simulate(t, ledger, "alterUser", `{"id":"1","name":"lzb1","sex":"女"}`)
+	second := simulate(t, ledger, "alterUser", `{"id":"1","name":"lzb1","sex":"未知"}`)
+	commitBlock(t, ledger, []pb.TxValidationCode{pb.TxValidationCode_VALID}, first)
+	commitBlock(t, ledger, []pb.TxValidationCode{pb.TxValidationCode_MVCC_READ_CONFLICT}, second)
+}
+
+func TestCommitBlock_IndependentTransactions(t *testing.T) {
+	ledger := newUserLedger(t, "")
+	first := simulate(t, ledger, "addUser", `{"id":"3","name":"lzb3","sex":"男"}`)
+	second := simulate(t, ledger, "addUser", `{"id":"4","name":"lzb4","sex":"女"}`)
+	commitBlock(t, ledger, []pb.TxValidationCode{pb.TxValidationCode_VALID, pb.TxValidationCode_VALID}, first, second)
+	three, _ := ledger.Get("user:Org1MSP:n01:3")
+	four, _ := ledger.Get("user:Org1MSP:n01:4")
+	if three.Version.BlockNum != four.Version.BlockNum || three.Version.TxNum != 0 || four.Version.TxNum != 1 {
+		t.Fatalf("unexpected versions %+v %+v", three.Version, four.Version)
+	}
+}
+
+func TestCommitBlock_PhantomReadConflict(t *testing.T) {
+	ledger := newUserLedger(t, "")
+	query := simulate(t, ledger, "queryAllUser")
+	add := simulate(t, ledger, "addUser", `{"id":"3","name":"lzb3","sex":"男"}`)
+	// 查询排在新增之后时,区间查询的结果已经变化
+	commitBlock(t, ledger, []pb.TxValidationCode{pb.TxValidationCode_VALID, pb.TxValidationCode_PHANTOM_READ_CONFLICT}, add, query)
+}
+
+func TestCommitBlock_InvalidTransactions(t *testing.T) {
+	ledger := newUserLedger(t, "")
+	failed := ledger.Simulate(Proposal{Function: "addUser", Args: []string{`{"id":"1","name":"lzb1","sex":"男"}`}})
+	ok := simulate(t, ledger, "queryOnceUser", `{"id":"1"}`)
+	commitBlock(t, ledger, []pb.TxValidationCode{pb.TxValidationCode_INVALID_OTHER_REASON, pb.TxValidationCode_VALID, pb.TxValidationCode_DUPLICATE_TXID}, failed, ok, ok)
+	commitBlock(t, ledger, []pb.TxValidationCode{pb.TxValidationCode_DUPLICATE_TXID}, ok)
+}
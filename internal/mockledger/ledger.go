@@ -0,0 +1,450 @@
+// Package mockledger 在进程内运行链码的模拟账本,供测试、本地网关与命令行工具在没有 Fabric 网络时使用。
+//
+// 与 shimtest.MockStub 不同,模拟账本按 Fabric 的方式执行交易:链码读取已提交的状态,写入先进入写集,
+// 交易成功后才提交;每个键保存版本号与完整历史,每笔交易记录读写集,整个账本可以快照、恢复并导出为 JSON。
+package mockledger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// 复合键的命名空间前缀与分隔符,与 shim.CreateCompositeKey 一致
+const (
+	compositeKeyNamespace = "\x00"
+	compositeKeyDelimiter = "\x00"
+)
+
+// VersionedValue 已提交的值及其版本
+type VersionedValue struct {
+	Value   []byte  `json:"value"`
+	Version Version `json:"version"`
+}
+
+// Modification 键的一条历史记录
+type Modification struct {
+	TxID      string    `json:"txId"`
+	Value     []byte    `json:"value,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	IsDelete  bool      `json:"isDelete"`
+}
+
+// Snapshot 账本快照,也是 JSON 导出文件的格式
+type Snapshot struct {
+	TxSeq    uint64                    `json:"txSeq"`    // 已分配的交易序号
+	BlockNum uint64                    `json:"blockNum"` // 已提交的区块高度
+	State    map[string]VersionedValue `json:"state"`
+	History  map[string][]Modification `json:"history"` // 按提交顺序(从旧到新)保存
+}
+
+// Proposal 交易提案
+type Proposal struct {
+	Function  string            // 函数名
+	Args      []string          // 函数参数
+	Transient map[string][]byte // 临时数据,可为 nil
+	Creator   []byte            // 调用者身份,为 nil 时使用账本的默认身份
+}
+
+// Ledger 模拟账本 -> 串行执行交易,自动生成递增的交易ID,可选地将状态持久化到文件
+type Ledger struct {
+	mu        sync.Mutex
+	name      string
+	cc        shim.Chaincode
+	mock      *shimtest.MockStub // 提供复合键、私有数据、事件等与状态无关的接口
+	path      string             // 持久化文件路径,为空时仅保存在内存中
+	creator   []byte             // 默认调用者身份
+	clock     func() time.Time   // 交易时间来源
+	txSeq     uint64
+	blockNum  uint64
+	state     map[string]VersionedValue
+	keys      []string // 排序后的键,状态变化时置空,下次区间查询时重建
+	changes   map[string][]Modification
+	committed map[string]bool // 已提交的交易ID,用于检测重复提交
+}
+
+// New
+// @title		New -> 创建账本
+// @description	创建运行指定链码的账本;持久化文件存在时从文件恢复状态,否则以 initArgs 为参数执行一次 init 初始化链码。
+// @auth		lzb
+// @param		name	字符串	"链码名称"
+// @param		cc		链码		"待运行的链码"
+// @param		creator	字符组	"默认调用者身份(SerializedIdentity)"
+// @param		path	字符串	"持久化文件路径,为空时不持久化"
+// @param		initArgs	字符组	"全新部署时 init 的参数"
+// @return		ledger	*Ledger	"账本"
+// @return		err		错误		"恢复或初始化失败的原因"
+func New(name string, cc shim.Chaincode, creator []byte, path string, initArgs ...string) (*Ledger, error) {
+	ledger := &Ledger{
+		name:      name,
+		cc:        cc,
+		mock:      shimtest.NewMockStub(name, cc),
+		path:      path,
+		creator:   creator,
+		clock:     time.Now,
+		state:     make(map[string]VersionedValue),
+		changes:   make(map[string][]Modification),
+		committed: make(map[string]bool),
+	}
+	if path != "" {
+		err := ledger.LoadFile(path)
+		if err == nil {
+			return ledger, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+	if tx := ledger.execute(true, Proposal{Function: "init", Args: initArgs}); tx.Response.Status != shim.OK {
+		return nil, fmt.Errorf("init %s error:%s", name, tx.Response.Message)
+	}
+	return ledger, nil
+}
+
+// SetCreator 设置默认调用者身份
+func (l *Ledger) SetCreator(creator []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.creator = creator
+}
+
+// SetClock 设置交易时间来源,测试中可使用固定或递增的时间
+func (l *Ledger) SetClock(clock func() time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.clock = clock
+}
+
+// Invoke
+// @title		Invoke -> 调用链码
+// @description	以默认身份和新的交易ID调用链码函数;交易失败时不提交写集,成功时提交并持久化。
+// @auth		lzb
+// @param		function	字符串	"函数名"
+// @param		args		字符组	"函数参数"
+// @param		transient	map		"临时数据,可为 nil"
+// @return		response	响应		"链码响应"
+func (l *Ledger) Invoke(function string, args []string, transient map[string][]byte) pb.Response {
+	return l.Execute(Proposal{Function: function, Args: args, Transient: transient}).Response
+}
+
+// Execute
+// @title		Execute -> 执行交易
+// @description	模拟执行提案并记录读写集,链码返回成功时将写集作为一个新区块提交。
+// @auth		lzb
+// @param		proposal	Proposal		"交易提案"
+// @return		tx			*Transaction	"交易ID、时间、响应与读写集"
+func (l *Ledger) Execute(proposal Proposal) *Transaction {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.execute(false, proposal)
+}
+
+// TxSeq 返回已分配的交易序号
+func (l *Ledger) TxSeq() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.txSeq
+}
+
+// BlockNum 返回已提交的区块高度
+func (l *Ledger) BlockNum() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.blockNum
+}
+
+// execute 模拟并提交一笔交易,调用方需持有锁
+func (l *Ledger) execute(init bool, proposal Proposal) *Transaction {
+	tx := l.simulate(init, proposal)
+	if tx.Response.Status != shim.OK {
+		return tx
+	}
+	l.commit([]*Transaction{tx})
+	if err := l.save(); err != nil {
+		tx.Response = shim.Error(err.Error())
+	}
+	return tx
+}
+
+// simulate 在已提交的状态上执行链码并记录读写集,不修改状态
+func (l *Ledger) simulate(init bool, proposal Proposal) *Transaction {
+	l.txSeq++
+	creator := proposal.Creator
+	if creator == nil {
+		creator = l.creator
+	}
+	tx := &Transaction{
+		ID:        fmt.Sprintf("tx%d", l.txSeq),
+		Timestamp: l.clock().UTC(),
+		Creator:   creator,
+		Function:  proposal.Function,
+		Args:      proposal.Args,
+	}
+	args := make([][]byte, 0, len(proposal.Args)+1)
+	args = append(args, []byte(proposal.Function))
+	for _, arg := range proposal.Args {
+		args = append(args, []byte(arg))
+	}
+	stub := &txStub{
+		MockStub:  l.mock,
+		ledger:    l,
+		tx:        tx,
+		args:      args,
+		transient: proposal.Transient,
+		rwset:     newRWSetBuilder(),
+	}
+	if init {
+		tx.Response = l.cc.Init(stub)
+	} else {
+		tx.Response = l.cc.Invoke(stub)
+	}
+	tx.RWSet = stub.rwset.build()
+	return tx
+}
+
+// KV 账本中的键值对
+type KV struct {
+	Key     string  // 原始键,复合键包含 0x00 分隔符
+	Value   []byte  // 值
+	Version Version // 版本
+}
+
+// Get 读取已提交的值,不经过链码也不分配交易ID
+func (l *Ledger) Get(key string) (VersionedValue, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	value, ok := l.state[key]
+	return value, ok
+}
+
+// Range
+// @title		Range -> 区间读取
+// @description	按字典序读取 [startKey, endKey) 区间内的已提交键值对,不经过链码也不分配交易ID;起止键为空表示不限制。
+// @auth		lzb
+// @param		startKey	字符串	"起始键(包含)"
+// @param		endKey		字符串	"终止键(不包含)"
+// @return		kvs			[]KV	"键值对列表"
+func (l *Ledger) Range(startKey, endKey string) []KV {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	keys := l.sortedKeys(startKey, endKey)
+	kvs := make([]KV, len(keys))
+	for i, key := range keys {
+		value := l.state[key]
+		kvs[i] = KV{Key: key, Value: value.Value, Version: value.Version}
+	}
+	return kvs
+}
+
+// History 返回键的历史记录,按从新到旧排列
+func (l *Ledger) History(key string) []Modification {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.history(key)
+}
+
+// history 返回键历史的副本,按从新到旧排列,调用方需持有锁
+func (l *Ledger) history(key string) []Modification {
+	changes := l.changes[key]
+	modifications := make([]Modification, len(changes))
+	for i, change := range changes {
+		modifications[len(changes)-1-i] = change
+	}
+	return modifications
+}
+
+// sortedKeys 返回 [startKey, endKey) 内排序后的已提交键,结果只读,调用方需持有锁
+func (l *Ledger) sortedKeys(startKey, endKey string) []string {
+	if l.keys == nil {
+		l.keys = make([]string, 0, len(l.state))
+		for key := range l.state {
+			l.keys = append(l.keys, key)
+		}
+		sort.Strings(l.keys)
+	}
+	from := sort.SearchStrings(l.keys, startKey)
+	to := len(l.keys)
+	if endKey != "" {
+		to = sort.SearchStrings(l.keys, endKey)
+	}
+	if to < from {
+		to = from
+	}
+	return l.keys[from:to:to]
+}
+
+// Snapshot 返回账本当前状态与历史的深拷贝
+func (l *Ledger) Snapshot() *Snapshot {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.snapshot()
+}
+
+// Restore 用快照替换账本的状态与历史,快照本身不会被后续交易修改
+func (l *Ledger) Restore(snap *Snapshot) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.restore(snap)
+}
+
+// snapshot 深拷贝当前状态,调用方需持有锁
+func (l *Ledger) snapshot() *Snapshot {
+	return &Snapshot{
+		TxSeq:    l.txSeq,
+		BlockNum: l.blockNum,
+		State:    copyState(l.state),
+		History:  copyHistory(l.changes),
+	}
+}
+
+// restore 用快照的深拷贝替换当前状态,调用方需持有锁
+func (l *Ledger) restore(snap *Snapshot) {
+	l.txSeq = snap.TxSeq
+	l.blockNum = snap.BlockNum
+	l.state = copyState(snap.State)
+	l.keys = nil
+	l.changes = copyHistory(snap.History)
+	l.committed = make(map[string]bool)
+	for _, changes := range l.changes {
+		for _, change := range changes {
+			l.committed[change.TxID] = true
+		}
+	}
+}
+
+// Dump 将账本以 JSON 格式写出
+func (l *Ledger) Dump(w io.Writer) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return dump(w, l.snapshot())
+}
+
+// Load 从 JSON 读取账本,替换当前的状态与历史
+func (l *Ledger) Load(r io.Reader) error {
+	var snap Snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return fmt.Errorf("unmarshal ledger error:%s", err)
+	}
+	l.Restore(&snap)
+	return nil
+}
+
+// DumpFile 将账本导出为 JSON 文件,先写临时文件再重命名,避免中途失败损坏原文件
+func (l *Ledger) DumpFile(path string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return dumpFile(path, l.snapshot())
+}
+
+// LoadFile 从 JSON 文件导入账本
+func (l *Ledger) LoadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := l.Load(file); err != nil {
+		return fmt.Errorf("load ledger %s error:%s", path, err)
+	}
+	return nil
+}
+
+// save 将账本写入持久化文件,调用方需持有锁
+func (l *Ledger) save() error {
+	if l.path == "" {
+		return nil
+	}
+	return dumpFile(l.path, l.snapshot())
+}
+
+// dump 以缩进 JSON 写出快照
+func dump(w io.Writer, snap *Snapshot) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(snap); err != nil {
+		return fmt.Errorf("marshal ledger error:%s", err)
+	}
+	return nil
+}
+
+// dumpFile 将快照写入文件
+func dumpFile(path string, snap *Snapshot) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create ledger dir error:%s", err)
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("write ledger error:%s", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := dump(tmp, snap); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write ledger error:%s", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("rename ledger error:%s", err)
+	}
+	return nil
+}
+
+// copyState 深拷贝状态
+func copyState(state map[string]VersionedValue) map[string]VersionedValue {
+	copied := make(map[string]VersionedValue, len(state))
+	for key, value := range state {
+		copied[key] = VersionedValue{Value: append([]byte(nil), value.Value...), Version: value.Version}
+	}
+	return copied
+}
+
+// copyHistory 深拷贝历史
+func copyHistory(history map[string][]Modification) map[string][]Modification {
+	copied := make(map[string][]Modification, len(history))
+	for key, changes := range history {
+		copied[key] = append([]Modification(nil), changes...)
+	}
+	return copied
+}
+
+// DecodeKey
+// @title		DecodeKey -> 解析键
+// @description	将复合键解析为 objectType[attr1, attr2] 的可读形式,普通键原样返回。
+// @auth		lzb
+// @param		key		字符串	"原始键"
+// @return		text	字符串	"可读形式"
+func DecodeKey(key string) string {
+	if !strings.HasPrefix(key, compositeKeyNamespace) {
+		return key
+	}
+	parts := strings.Split(strings.TrimSuffix(key[len(compositeKeyNamespace):], compositeKeyDelimiter), compositeKeyDelimiter)
+	return fmt.Sprintf("%s[%s]", parts[0], strings.Join(parts[1:], ", "))
+}
+
+// FormatValue 显示账本中的值:JSON 压缩显示,可打印文本原样显示,其余以十六进制显示
+func FormatValue(value []byte) string {
+	var out bytes.Buffer
+	if json.Valid(value) && json.Compact(&out, value) == nil {
+		return out.String()
+	}
+	if utf8.Valid(value) && !bytes.ContainsAny(value, "\x00") {
+		return string(value)
+	}
+	return fmt.Sprintf("0x%x", value)
+}
@@ -0,0 +1,293 @@
+package mockledger
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	examplecc "github.com/lzb13612/Example-Chaincode/example/chaincode"
+	"github.com/lzb13612/Example-Chaincode/internal/creator"
+	usercc "github.com/lzb13612/Example-Chaincode/user/chaincode"
+)
+
+var testCreator = creator.MustNew("Org1MSP", "lzb", nil)
+
+// newUserLedger 创建运行 User 链码的账本,不要求用户属于组织
+func newUserLedger(t *testing.T, path string) *Ledger {
+	cc, err := usercc.NewChaincode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledger, err := New("user", cc, testCreator, path, `{"requireOrg":false}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ledger
+}
+
+func TestLedger_AutoIncrementTxID(t *testing.T) {
+	ledger := newUserLedger(t, "")
+	if ledger.TxSeq() != 1 {
+		t.Fatalf("expected init to use tx1, got %d", ledger.TxSeq())
+	}
+	ledger.Invoke("queryAllUser", nil, nil)
+	ledger.Invoke("queryAllUser", nil, nil)
+	if ledger.TxSeq() != 3 {
+		t.Fatalf("expected tx sequence 3, got %d", ledger.TxSeq())
+	}
+}
+
+func TestLedger_FailedTxDiscardsWrites(t *testing.T) {
+	ledger := newUserLedger(t, "")
+	before := ledger.Snapshot()
+	// 失败的交易不应留下任何状态
+	res := ledger.Invoke("addUser", []string{`{"id":"1","name":"x","sex":"男"}`}, nil)
+	if res.Status == shim.OK {
+		t.Fatal("expected duplicate user to fail")
+	}
+	if after := ledger.Snapshot(); len(after.State) != len(before.State) || after.BlockNum != before.BlockNum {
+		t.Fatalf("expected failed tx not to be committed, blocks %d -> %d", before.BlockNum, after.BlockNum)
+	}
+}
+
+func TestLedger_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "user.json")
+
+	ledger := newUserLedger(t, path)
+	if res := ledger.Invoke("addUser", []string{`{"id":"3","name":"lzb3","sex":"男"}`}, nil); res.Status != shim.OK {
+		t.Fatal(res.Message)
+	}
+
+	reopened := newUserLedger(t, path)
+	if reopened.TxSeq() != ledger.TxSeq() {
+		t.Fatalf("expected tx sequence %d, got %d", ledger.TxSeq(), reopened.TxSeq())
+	}
+	res := reopened.Invoke("queryOnceUser", []string{`{"id":"3"}`}, nil)
+	if res.Status != shim.OK {
+		t.Fatal(res.Message)
+	}
+	if string(res.Payload) != `{"id":"3","name":"lzb3","sex":"男"}` {
+		t.Fatalf("unexpected payload %s", res.Payload)
+	}
+	// 范围查询依赖恢复后的有序键列表
+	res = reopened.Invoke("queryAllUser", nil, nil)
+	if res.Status != shim.OK || string(res.Payload) == "[]" {
+		t.Fatalf("expected users after restore, got %s %s", res.Payload, res.Message)
+	}
+}
+
+func TestLedger_Range(t *testing.T) {
+	ledger := newUserLedger(t, "")
+	kvs := ledger.Range("user:", "user;")
+	if len(kvs) != 2 || kvs[0].Key != "user:Org1MSP:n01:1" || kvs[1].Key != "user:Org1MSP:n01:2" {
+		t.Fatalf("unexpected user keys %q", kvs)
+	}
+	if ledger.TxSeq() != 1 {
+		t.Fatal("range must not allocate tx ids")
+	}
+	// 排序后的键在提交与恢复快照后重建
+	snap := ledger.Snapshot()
+	if res := ledger.Invoke("addUser", []string{`{"id":"3","name":"lzb3","sex":"男"}`}, nil); res.Status != shim.OK {
+		t.Fatal(res.Message)
+	}
+	if kvs := ledger.Range("user:", "user;"); len(kvs) != 3 {
+		t.Fatalf("expected committed user to be visible, got %q", kvs)
+	}
+	ledger.Restore(snap)
+	if kvs := ledger.Range("user:", "user;"); len(kvs) != 2 {
+		t.Fatalf("expected restored users only, got %q", kvs)
+	}
+}
+
+func TestDecodeKey(t *testing.T) {
+	tests := map[string]string{
+		"name1":                       "name1",
+		"\x00user\x001\x00":           "user[1]",
+		"\x00name~id\x00lzb\x001\x00": "name~id[lzb, 1]",
+	}
+	for key, expected := range tests {
+		if actual := DecodeKey(key); actual != expected {
+			t.Errorf("DecodeKey(%q) = %q, expected %q", key, actual, expected)
+		}
+	}
+}
+
+// fixedClock 每次调用前进一秒的时钟
+func fixedClock(start time.Time) func() time.Time {
+	now := start
+	return func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+}
+
+// invokeOK 调用链码并要求成功
+func invokeOK(t *testing.T, ledger *Ledger, function string, args ...string) *Transaction {
+	tx := ledger.Execute(Proposal{Function: function, Args: args})
+	if tx.Response.Status != shim.OK {
+		t.Fatalf("%s failed: %s", function, tx.Response.Message)
+	}
+	return tx
+}
+
+func TestLedger_History(t *testing.T) {
+	ledger := newUserLedger(t, "")
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ledger.SetClock(fixedClock(start))
+
+	added := invokeOK(t, ledger, "addUser", `{"id":"3","name":"lzb3","sex":"男"}`)
+	altered := invokeOK(t, ledger, "alterUser", `{"id":"3","name":"lzb3","sex":"女"}`)
+	deleted := invokeOK(t, ledger, "delUser", `{"id":"3"}`)
+
+	history := ledger.History("user:Org1MSP:n01:3")
+	if len(history) != 3 {
+		t.Fatalf("expected 3 modifications, got %d", len(history))
+	}
+	expected := []struct {
+		txID     string
+		isDelete bool
+		seconds  int
+	}{{deleted.ID, true, 3}, {altered.ID, false, 2}, {added.ID, false, 1}}
+	for i, e := range expected {
+		m := history[i]
+		if m.TxID != e.txID || m.IsDelete != e.isDelete || !m.Timestamp.Equal(start.Add(time.Duration(e.seconds)*time.Second)) {
+			t.Errorf("history[%d] = %+v, expected tx %s isDelete %v", i, m, e.txID, e.isDelete)
+		}
+	}
+	if !strings.Contains(string(history[1].Value), `"sex":"女"`) {
+		t.Errorf("unexpected altered value %s", history[1].Value)
+	}
+	if _, ok := ledger.Get("user:Org1MSP:n01:3"); ok {
+		t.Error("deleted user should not be in state")
+	}
+}
+
+func TestLedger_ReadWriteSet(t *testing.T) {
+	ledger := newUserLedger(t, "")
+	userKey := "user:Org1MSP:n01:1"
+	value, _ := ledger.Get(userKey)
+
+	tx := invokeOK(t, ledger, "alterUser", `{"id":"1","name":"lzb1","sex":"女"}`)
+	read := false
+	for _, r := range tx.RWSet.Reads {
+		read = read || (r.Key == userKey && r.Version != nil && *r.Version == value.Version)
+	}
+	if !read {
+		t.Fatalf("expected read of %q at %+v, got %+v", userKey, value.Version, tx.RWSet.Reads)
+	}
+	if len(tx.RWSet.Writes) != 1 || tx.RWSet.Writes[0].Key != userKey || tx.RWSet.Writes[0].IsDelete {
+		t.Fatalf("unexpected writes %+v", tx.RWSet.Writes)
+	}
+	committed, _ := ledger.Get(userKey)
+	if committed.Version != (Version{BlockNum: ledger.BlockNum(), TxNum: 0}) {
+		t.Fatalf("expected version of the new block, got %+v", committed.Version)
+	}
+
+	tx = invokeOK(t, ledger, "queryAllUser")
+	if len(tx.RWSet.Writes) != 0 || len(tx.RWSet.RangeQueries) != 1 {
+		t.Fatalf("expected one range query and no writes, got %+v", tx.RWSet)
+	}
+	query := tx.RWSet.RangeQueries[0]
+	if !query.ItrExhausted || len(query.Reads) != 2 {
+		t.Fatalf("expected exhausted range query over 2 users, got %+v", query)
+	}
+	blockNum := ledger.BlockNum()
+	invokeOK(t, ledger, "queryOnceUser", `{"id":"1"}`)
+	if ledger.BlockNum() != blockNum+1 {
+		t.Fatal("every successful transaction should be committed in its own block")
+	}
+}
+
+func TestLedger_ReadsDoNotSeeOwnWrites(t *testing.T) {
+	// Example 的 putState 写入后立即读取,在 Fabric 中读不到本交易的写入
+	cc, err := examplecc.NewChaincode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	example, err := New("example", cc, testCreator, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := invokeOK(t, example, "putState")
+	if len(tx.RWSet.Reads) != 1 || tx.RWSet.Reads[0].Version != nil {
+		t.Fatalf("expected read of missing key, got %+v", tx.RWSet.Reads)
+	}
+	if len(tx.RWSet.Writes) != 1 || tx.RWSet.Writes[0].Key != tx.RWSet.Reads[0].Key {
+		t.Fatalf("expected write of the read key, got %+v", tx.RWSet.Writes)
+	}
+	if _, ok := example.Get(tx.RWSet.Writes[0].Key); !ok {
+		t.Fatal("write should be visible after commit")
+	}
+}
+
+func TestLedger_Creator(t *testing.T) {
+	ledger := newUserLedger(t, "")
+	alice := creator.MustNew("Org1MSP", "alice", nil)
+	bob := creator.MustNew("Org2MSP", "bob", nil)
+
+	tx := ledger.Execute(Proposal{Function: "registerSelf", Args: []string{`{"name":"alice","sex":"女"}`}, Creator: alice})
+	if tx.Response.Status != shim.OK || string(tx.Creator) != string(alice) {
+		t.Fatalf("registerSelf as alice: %s", tx.Response.Message)
+	}
+	ledger.SetCreator(bob)
+	if res := ledger.Invoke("whoAmI", nil, nil); res.Status == shim.OK {
+		t.Fatal("bob should not be registered")
+	}
+	tx = ledger.Execute(Proposal{Function: "whoAmI", Creator: alice})
+	if tx.Response.Status != shim.OK || !strings.Contains(string(tx.Response.Payload), `"name":"alice"`) {
+		t.Fatalf("whoAmI as alice: %s %s", tx.Response.Payload, tx.Response.Message)
+	}
+}
+
+func TestLedger_SnapshotRestore(t *testing.T) {
+	ledger := newUserLedger(t, "")
+	snap := ledger.Snapshot()
+	invokeOK(t, ledger, "addUser", `{"id":"3","name":"lzb3","sex":"男"}`)
+	invokeOK(t, ledger, "delUser", `{"id":"1"}`)
+
+	ledger.Restore(snap)
+	if _, ok := ledger.Get("user:Org1MSP:n01:3"); ok {
+		t.Fatal("user 3 should not exist after restore")
+	}
+	if _, ok := ledger.Get("user:Org1MSP:n01:1"); !ok {
+		t.Fatal("user 1 should exist after restore")
+	}
+	if len(ledger.History("user:Org1MSP:n01:1")) != 1 || ledger.TxSeq() != snap.TxSeq {
+		t.Fatal("history and tx sequence should be restored")
+	}
+	// 快照不受恢复之后的交易影响,可以重复恢复
+	invokeOK(t, ledger, "delUser", `{"id":"1"}`)
+	ledger.Restore(snap)
+	if _, ok := ledger.Get("user:Org1MSP:n01:1"); !ok {
+		t.Fatal("snapshot should be reusable")
+	}
+}
+
+func TestLedger_DumpLoad(t *testing.T) {
+	ledger := newUserLedger(t, "")
+	invokeOK(t, ledger, "addUser", `{"id":"3","name":"lzb3","sex":"男"}`)
+	invokeOK(t, ledger, "delUser", `{"id":"3"}`)
+	var dumped bytes.Buffer
+	if err := ledger.Dump(&dumped); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := newUserLedger(t, "")
+	if err := loaded.Load(bytes.NewReader(dumped.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Snapshot(), ledger.Snapshot()) {
+		t.Fatal("loaded ledger differs from the dumped ledger")
+	}
+	history := loaded.History("user:Org1MSP:n01:3")
+	if len(history) != 2 || !history[0].IsDelete {
+		t.Fatalf("expected delete to survive dump/load, got %+v", history)
+	}
+	if err := loaded.Load(strings.NewReader("{")); err == nil {
+		t.Fatal("expected malformed dump to fail")
+	}
+}
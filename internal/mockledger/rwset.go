@@ -0,0 +1,109 @@
+package mockledger
+
+import (
+	"sort"
+	"time"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// Version 键的版本 -> 提交该键的区块号与区块内的交易序号,与 Fabric 的 MVCC 版本一致
+type Version struct {
+	BlockNum uint64 `json:"blockNum"`
+	TxNum    uint64 `json:"txNum"`
+}
+
+// KVRead 读集中的一项,Version 为 nil 表示读取时键不存在
+type KVRead struct {
+	Key     string   `json:"key"`
+	Version *Version `json:"version,omitempty"`
+}
+
+// KVWrite 写集中的一项
+type KVWrite struct {
+	Key      string `json:"key"`
+	Value    []byte `json:"value,omitempty"`
+	IsDelete bool   `json:"isDelete"`
+}
+
+// RangeQuery 区间查询信息,用于提交时检测幻读
+type RangeQuery struct {
+	StartKey     string   `json:"startKey"`
+	EndKey       string   `json:"endKey"`
+	ItrExhausted bool     `json:"itrExhausted"` // 迭代器是否被读完,未读完时只校验已读到的键
+	Reads        []KVRead `json:"reads"`
+}
+
+// ReadWriteSet 交易模拟得到的读写集
+type ReadWriteSet struct {
+	Reads        []KVRead     `json:"reads"`
+	RangeQueries []RangeQuery `json:"rangeQueries"`
+	Writes       []KVWrite    `json:"writes"`
+}
+
+// Transaction 一次链码调用的模拟结果
+type Transaction struct {
+	ID        string       `json:"txId"`
+	Timestamp time.Time    `json:"timestamp"`
+	Creator   []byte       `json:"creator"`
+	Function  string       `json:"function"`
+	Args      []string     `json:"args"`
+	Response  pb.Response  `json:"-"`
+	RWSet     ReadWriteSet `json:"rwset"`
+
+	ValidationCode pb.TxValidationCode `json:"validationCode"` // 提交时的校验结果,未提交时为 VALID
+}
+
+// rwsetBuilder 模拟过程中收集读写集:每个键只记录第一次读取的版本,写入以最后一次为准
+type rwsetBuilder struct {
+	reads        map[string]*Version
+	readOrder    []string
+	writes       map[string]KVWrite
+	rangeQueries []*RangeQuery
+}
+
+// newRWSetBuilder 创建读写集收集器
+func newRWSetBuilder() *rwsetBuilder {
+	return &rwsetBuilder{reads: make(map[string]*Version), writes: make(map[string]KVWrite)}
+}
+
+// addRead 记录读取的键及其版本
+func (b *rwsetBuilder) addRead(key string, version *Version) {
+	if _, ok := b.reads[key]; ok {
+		return
+	}
+	b.reads[key] = version
+	b.readOrder = append(b.readOrder, key)
+}
+
+// addWrite 记录写入,空值视为删除(与 Fabric 一致)
+func (b *rwsetBuilder) addWrite(key string, value []byte) {
+	b.writes[key] = KVWrite{Key: key, Value: value, IsDelete: len(value) == 0}
+}
+
+// addRangeQuery 记录一次区间查询,返回的指针由迭代器在读取时追加读到的键
+func (b *rwsetBuilder) addRangeQuery(startKey, endKey string) *RangeQuery {
+	query := &RangeQuery{StartKey: startKey, EndKey: endKey, Reads: make([]KVRead, 0)}
+	b.rangeQueries = append(b.rangeQueries, query)
+	return query
+}
+
+// build 生成读写集,写集按键排序
+func (b *rwsetBuilder) build() ReadWriteSet {
+	rwset := ReadWriteSet{
+		Reads:        make([]KVRead, 0, len(b.readOrder)),
+		RangeQueries: make([]RangeQuery, 0, len(b.rangeQueries)),
+		Writes:       make([]KVWrite, 0, len(b.writes)),
+	}
+	for _, key := range b.readOrder {
+		rwset.Reads = append(rwset.Reads, KVRead{Key: key, Version: b.reads[key]})
+	}
+	for _, query := range b.rangeQueries {
+		rwset.RangeQueries = append(rwset.RangeQueries, *query)
+	}
+	for _, write := range b.writes {
+		rwset.Writes = append(rwset.Writes, write)
+	}
+	sort.Slice(rwset.Writes, func(i, j int) bool { return rwset.Writes[i].Key < rwset.Writes[j].Key })
+	return rwset
+}
@@ -0,0 +1,270 @@
+package mockledger
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// emptyKeySubstitute 区间查询起始键为空时的替代值,使普通区间查询不包含复合键(与 shim 一致)
+const emptyKeySubstitute = "\x01"
+
+// txStub 单笔交易的链码接口 -> 读取已提交的状态并记录读写集,写入在提交前不可见
+//
+// 复合键、私有数据、事件等其余接口沿用 shimtest.MockStub 的实现。
+type txStub struct {
+	*shimtest.MockStub
+	ledger    *Ledger
+	tx        *Transaction
+	args      [][]byte
+	transient map[string][]byte
+	rwset     *rwsetBuilder
+}
+
+var _ shim.ChaincodeStubInterface = (*txStub)(nil)
+
+// GetArgs 返回交易参数
+func (s *txStub) GetArgs() [][]byte { return s.args }
+
+// GetStringArgs 以字符串返回交易参数
+func (s *txStub) GetStringArgs() []string {
+	args := make([]string, len(s.args))
+	for i, arg := range s.args {
+		args[i] = string(arg)
+	}
+	return args
+}
+
+// GetFunctionAndParameters 返回函数名与参数
+func (s *txStub) GetFunctionAndParameters() (string, []string) {
+	args := s.GetStringArgs()
+	if len(args) == 0 {
+		return "", []string{}
+	}
+	return args[0], args[1:]
+}
+
+// GetTxID 返回交易ID
+func (s *txStub) GetTxID() string { return s.tx.ID }
+
+// GetTxTimestamp 返回交易时间
+func (s *txStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	return ptypes.TimestampProto(s.tx.Timestamp)
+}
+
+// GetCreator 返回交易发起者身份
+func (s *txStub) GetCreator() ([]byte, error) { return s.tx.Creator, nil }
+
+// GetTransient 返回临时数据
+func (s *txStub) GetTransient() (map[string][]byte, error) { return s.transient, nil }
+
+// GetState 读取已提交的值并记录读集
+func (s *txStub) GetState(key string) ([]byte, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+	value, ok := s.ledger.state[key]
+	if !ok {
+		s.rwset.addRead(key, nil)
+		return nil, nil
+	}
+	version := value.Version
+	s.rwset.addRead(key, &version)
+	return value.Value, nil
+}
+
+// PutState 记录写集
+func (s *txStub) PutState(key string, value []byte) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	s.rwset.addWrite(key, value)
+	return nil
+}
+
+// DelState 记录删除
+func (s *txStub) DelState(key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	s.rwset.addWrite(key, nil)
+	return nil
+}
+
+// GetStateByRange 区间查询普通键
+func (s *txStub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	iterator, _, err := s.GetStateByRangeWithPagination(startKey, endKey, 0, "")
+	return iterator, err
+}
+
+// GetStateByRangeWithPagination 分页区间查询普通键,书签为下一页的起始键
+func (s *txStub) GetStateByRangeWithPagination(startKey, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	if err := validateSimpleKeys(startKey, endKey); err != nil {
+		return nil, nil, err
+	}
+	if startKey == "" {
+		startKey = emptyKeySubstitute
+	}
+	return s.rangeQuery(startKey, endKey, pageSize, bookmark)
+}
+
+// GetStateByPartialCompositeKey 按复合键前缀查询
+func (s *txStub) GetStateByPartialCompositeKey(objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	iterator, _, err := s.GetStateByPartialCompositeKeyWithPagination(objectType, keys, 0, "")
+	return iterator, err
+}
+
+// GetStateByPartialCompositeKeyWithPagination 按复合键前缀分页查询
+func (s *txStub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	startKey, err := s.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.rangeQuery(startKey, startKey+string(utf8.MaxRune), pageSize, bookmark)
+}
+
+// GetQueryResult 富查询需要 CouchDB,模拟账本不支持
+func (s *txStub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	return nil, errors.New("rich queries are not supported by the mock ledger")
+}
+
+// GetQueryResultWithPagination 富查询需要 CouchDB,模拟账本不支持
+func (s *txStub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	return nil, nil, errors.New("rich queries are not supported by the mock ledger")
+}
+
+// GetHistoryForKey 返回键的已提交历史,按从新到旧排列(与 Fabric 2.x 一致)
+func (s *txStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+	return &historyIterator{modifications: s.ledger.history(key)}, nil
+}
+
+// rangeQuery 读取 [startKey, endKey) 内的已提交键值,pageSize 大于 0 时分页
+func (s *txStub) rangeQuery(startKey, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	if pageSize < 0 {
+		return nil, nil, fmt.Errorf("page size %d must not be negative", pageSize)
+	}
+	from := startKey
+	if bookmark != "" {
+		if bookmark < startKey || (endKey != "" && bookmark >= endKey) {
+			return nil, nil, fmt.Errorf("bookmark %q is outside the query range", bookmark)
+		}
+		from = bookmark
+	}
+	keys := s.ledger.sortedKeys(from, endKey)
+	metadata := new(pb.QueryResponseMetadata)
+	if pageSize > 0 && len(keys) > int(pageSize) {
+		metadata.Bookmark = keys[pageSize]
+		keys = keys[:pageSize]
+	}
+	metadata.FetchedRecordsCount = int32(len(keys))
+	iterator := &stateIterator{
+		namespace: s.Name,
+		ledger:    s.ledger,
+		keys:      keys,
+		query:     s.rwset.addRangeQuery(from, endKey),
+	}
+	return iterator, metadata, nil
+}
+
+// stateIterator 区间查询迭代器,读取时将键与版本追加到区间查询信息
+type stateIterator struct {
+	namespace string
+	ledger    *Ledger
+	keys      []string
+	next      int
+	query     *RangeQuery
+	closed    bool
+}
+
+// HasNext 是否还有数据
+func (it *stateIterator) HasNext() bool {
+	if it.closed {
+		return false
+	}
+	if it.next >= len(it.keys) {
+		it.query.ItrExhausted = true
+		return false
+	}
+	return true
+}
+
+// Next 读取下一个键值
+func (it *stateIterator) Next() (*queryresult.KV, error) {
+	if !it.HasNext() {
+		return nil, errors.New("iterator has no more results")
+	}
+	key := it.keys[it.next]
+	it.next++
+	value := it.ledger.state[key]
+	version := value.Version
+	it.query.Reads = append(it.query.Reads, KVRead{Key: key, Version: &version})
+	return &queryresult.KV{Namespace: it.namespace, Key: key, Value: value.Value}, nil
+}
+
+// Close 关闭迭代器
+func (it *stateIterator) Close() error {
+	it.closed = true
+	return nil
+}
+
+// historyIterator 历史查询迭代器
+type historyIterator struct {
+	modifications []Modification
+	next          int
+}
+
+// HasNext 是否还有数据
+func (it *historyIterator) HasNext() bool { return it.next < len(it.modifications) }
+
+// Next 读取下一条历史
+func (it *historyIterator) Next() (*queryresult.KeyModification, error) {
+	if !it.HasNext() {
+		return nil, errors.New("iterator has no more results")
+	}
+	modification := it.modifications[it.next]
+	it.next++
+	ts, err := ptypes.TimestampProto(modification.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+	return &queryresult.KeyModification{
+		TxId:      modification.TxID,
+		Value:     modification.Value,
+		Timestamp: ts,
+		IsDelete:  modification.IsDelete,
+	}, nil
+}
+
+// Close 关闭迭代器
+func (it *historyIterator) Close() error { return nil }
+
+// validateKey 校验键非空且为合法 UTF-8
+func validateKey(key string) error {
+	if key == "" {
+		return errors.New("key must not be an empty string")
+	}
+	if !utf8.ValidString(key) {
+		return fmt.Errorf("key %q is not a valid UTF-8 string", key)
+	}
+	return nil
+}
+
+// validateSimpleKeys 普通区间查询的起止键不能以复合键命名空间开头
+func validateSimpleKeys(keys ...string) error {
+	for _, key := range keys {
+		if len(key) > 0 && key[:1] == compositeKeyNamespace {
+			return fmt.Errorf("first character of the key [%s] contains a null character which is not allowed", key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Package server 按环境变量选择链码的启动方式:外部链码服务(Chaincode-as-a-Service)或由 peer 启动。
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// 外部链码服务的环境变量
+const (
+	EnvChaincodeId         = "CHAINCODE_ID"                      // 链码包 ID,例如 user_1.0:<hash>
+	EnvServerAddress       = "CHAINCODE_SERVER_ADDRESS"          // 监听地址,例如 0.0.0.0:9999
+	EnvTLSDisabled         = "CHAINCODE_TLS_DISABLED"            // 为 true 时关闭 TLS
+	EnvTLSKeyFile          = "CHAINCODE_TLS_KEY_FILE"            // 服务端私钥文件
+	EnvTLSCertFile         = "CHAINCODE_TLS_CERT_FILE"           // 服务端证书文件
+	EnvTLSClientCACertFile = "CHAINCODE_TLS_CLIENT_CA_CERT_FILE" // 客户端 CA 证书文件,设置后要求 peer 提供客户端证书
+)
+
+// Config 外部链码服务配置
+type Config struct {
+	CCID     string             // 链码包 ID
+	Address  string             // 监听地址
+	TLSProps shim.TLSProperties // TLS 配置
+}
+
+// LoadConfig
+// @title		LoadConfig -> 读取配置
+// @description	从环境变量读取外部链码服务配置,CHAINCODE_ID 与 CHAINCODE_SERVER_ADDRESS 均未设置时返回 nil,表示由 peer 启动链码。
+// @auth		lzb
+// @param		lookup	函数		"环境变量查询函数,通常为 os.LookupEnv"
+// @return		config	*Config	"外部链码服务配置"
+// @return		err		错误		"配置不完整或 TLS 文件读取失败的原因"
+func LoadConfig(lookup func(string) (string, bool)) (*Config, error) {
+	getenv := func(key string) string {
+		value, _ := lookup(key)
+		return value
+	}
+	ccid, address := getenv(EnvChaincodeId), getenv(EnvServerAddress)
+	if ccid == "" && address == "" {
+		return nil, nil
+	}
+	if ccid == "" {
+		return nil, fmt.Errorf("%s must be set when %s is set", EnvChaincodeId, EnvServerAddress)
+	}
+	if address == "" {
+		return nil, fmt.Errorf("%s must be set when %s is set", EnvServerAddress, EnvChaincodeId)
+	}
+	config := &Config{CCID: ccid, Address: address}
+
+	disabled := true
+	if value := getenv(EnvTLSDisabled); value != "" {
+		var err error
+		if disabled, err = strconv.ParseBool(value); err != nil {
+			return nil, fmt.Errorf("parse %s error:%s", EnvTLSDisabled, err)
+		}
+	} else if getenv(EnvTLSKeyFile) != "" || getenv(EnvTLSCertFile) != "" {
+		// 未显式配置时,提供了证书文件即开启 TLS
+		disabled = false
+	}
+	config.TLSProps.Disabled = disabled
+	if disabled {
+		return config, nil
+	}
+
+	var err error
+	if config.TLSProps.Key, err = readFile(getenv(EnvTLSKeyFile), EnvTLSKeyFile); err != nil {
+		return nil, err
+	}
+	if config.TLSProps.Cert, err = readFile(getenv(EnvTLSCertFile), EnvTLSCertFile); err != nil {
+		return nil, err
+	}
+	if path := getenv(EnvTLSClientCACertFile); path != "" {
+		if config.TLSProps.ClientCACerts, err = readFile(path, EnvTLSClientCACertFile); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// readFile 读取环境变量指定的文件
+func readFile(path, env string) ([]byte, error) {
+	if path == "" {
+		return nil, fmt.Errorf("%s must be set when TLS is enabled", env)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s error:%s", env, err)
+	}
+	return content, nil
+}
+
+// Start
+// @title		Start -> 启动链码
+// @description	配置了外部链码服务时以 gRPC 服务端方式运行链码,否则回退到 shim.Start 由 peer 启动。
+// @auth		lzb
+// @param		cc		链码		"待启动的链码"
+// @return		err		错误		"启动失败的原因"
+func Start(cc shim.Chaincode) error {
+	config, err := LoadConfig(os.LookupEnv)
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		return shim.Start(cc)
+	}
+	server := &shim.ChaincodeServer{
+		CCID:     config.CCID,
+		Address:  config.Address,
+		CC:       cc,
+		TLSProps: config.TLSProps,
+	}
+	return server.Start()
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// envLookup 以 map 模拟环境变量
+func envLookup(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
+
+// writeFile 在临时目录写入文件并返回路径
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig_NotConfigured(t *testing.T) {
+	config, err := LoadConfig(envLookup(nil))
+	if err != nil || config != nil {
+		t.Fatalf("expected fallback to peer launch, got %+v %v", config, err)
+	}
+}
+
+func TestLoadConfig_Incomplete(t *testing.T) {
+	if _, err := LoadConfig(envLookup(map[string]string{EnvChaincodeId: "user_1.0:abc"})); err == nil {
+		t.Fatal("expected error without server address")
+	}
+	if _, err := LoadConfig(envLookup(map[string]string{EnvServerAddress: "0.0.0.0:9999"})); err == nil {
+		t.Fatal("expected error without chaincode id")
+	}
+}
+
+func TestLoadConfig_WithoutTLS(t *testing.T) {
+	config, err := LoadConfig(envLookup(map[string]string{
+		EnvChaincodeId:   "user_1.0:abc",
+		EnvServerAddress: "0.0.0.0:9999",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.CCID != "user_1.0:abc" || config.Address != "0.0.0.0:9999" || !config.TLSProps.Disabled {
+		t.Fatalf("unexpected config %+v", config)
+	}
+}
+
+func TestLoadConfig_WithTLS(t *testing.T) {
+	dir := t.TempDir()
+	config, err := LoadConfig(envLookup(map[string]string{
+		EnvChaincodeId:         "user_1.0:abc",
+		EnvServerAddress:       "0.0.0.0:9999",
+		EnvTLSKeyFile:          writeFile(t, dir, "key.pem", "key"),
+		EnvTLSCertFile:         writeFile(t, dir, "cert.pem", "cert"),
+		EnvTLSClientCACertFile: writeFile(t, dir, "ca.pem", "ca"),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := config.TLSProps
+	if props.Disabled || string(props.Key) != "key" || string(props.Cert) != "cert" || string(props.ClientCACerts) != "ca" {
+		t.Fatalf("unexpected tls properties %+v", props)
+	}
+}
+
+func TestLoadConfig_TLSMissingFile(t *testing.T) {
+	_, err := LoadConfig(envLookup(map[string]string{
+		EnvChaincodeId:   "user_1.0:abc",
+		EnvServerAddress: "0.0.0.0:9999",
+		EnvTLSDisabled:   "false",
+	}))
+	if err == nil {
+		t.Fatal("expected error when tls enabled without key file")
+	}
+}
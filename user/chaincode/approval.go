@@ -0,0 +1,370 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/lzb13612/Example-Chaincode/internal/iterate"
+)
+
+// 需要审批的用户修改
+const (
+	ChangeAlter  = "alter"  // 修改用户
+	ChangeDelete = "delete" // 删除用户
+)
+
+// 修改申请的状态,expired 不写入账本,由查询时的交易时间计算
+const (
+	ChangePending  = "pending"
+	ChangeApplied  = "applied"
+	ChangeRejected = "rejected"
+	ChangeExpired  = "expired"
+)
+
+const (
+	changeRequestKey = "changereq" // 修改申请的复合键类型 -> changereq[租户, 申请id]
+	checkerRole      = "checker"   // 审批角色
+)
+
+// ApprovalConfig 审批配置 -> 设置后 Actions 中的修改不能直接执行,必须由其他身份审批
+type ApprovalConfig struct {
+	Actions    []string `json:"actions"`    // 需要审批的修改(alter、delete)
+	Quorum     int      `json:"quorum"`     // 执行修改所需的审批数
+	TTLSeconds int64    `json:"ttlSeconds"` // 申请的有效期(秒),按交易时间计算
+}
+
+// Approval 一次审批
+type Approval struct {
+	Checker string `json:"checker"` // 审批者的用户id
+	At      string `json:"at"`      // 审批的交易时间(RFC 3339)
+}
+
+// ChangeRequest 修改申请
+type ChangeRequest struct {
+	Id        string     `json:"id"`                                       // 申请id,即提交申请的交易ID
+	Action    string     `json:"action"`                                   // alter 或 delete
+	User      UserInfo   `json:"user"`                                     // 修改后的用户,删除时只有id;提供加密密钥时已加密
+	Maker     string     `json:"maker"`                                    // 申请者的用户id
+	CreatedAt string     `json:"createdAt"`                                // 申请的交易时间
+	ExpiresAt string     `json:"expiresAt"`                                // 过期时间
+	Quorum    int        `json:"quorum"`                                   // 申请时配置的审批数
+	Approvals []Approval `json:"approvals"`                                // 已有的审批
+	Status    string     `json:"status"`                                   // 申请状态
+	DecidedBy string     `json:"decidedBy,omitempty" metadata:",optional"` // 执行修改或拒绝申请的审批者
+	DecidedAt string     `json:"decidedAt,omitempty" metadata:",optional"` // 执行修改或拒绝申请的交易时间
+	Reason    string     `json:"reason,omitempty" metadata:",optional"`    // 拒绝原因
+}
+
+// validateApproval 校验审批配置
+func validateApproval(config *ApprovalConfig) error {
+	if config == nil {
+		return nil
+	}
+	if len(config.Actions) == 0 {
+		return errors.New("approval actions are required")
+	}
+	for _, action := range config.Actions {
+		if action != ChangeAlter && action != ChangeDelete {
+			return fmt.Errorf("unknown approval action %s", action)
+		}
+	}
+	if config.Quorum < 1 {
+		return errors.New("approval quorum must be positive")
+	}
+	if config.TTLSeconds <= 0 {
+		return errors.New("approval ttl must be positive")
+	}
+	return nil
+}
+
+// approvalFor 读取修改所需的审批配置,不需要审批时返回 nil
+func approvalFor(stub shim.ChaincodeStubInterface, action string) (*ApprovalConfig, error) {
+	config, err := getConfig(stub)
+	if err != nil {
+		return nil, err
+	}
+	if config.Approval == nil || !containsField(config.Approval.Actions, action) {
+		return nil, nil
+	}
+	return config.Approval, nil
+}
+
+// checkDirectChange 需要审批的修改不能直接执行
+func checkDirectChange(stub shim.ChaincodeStubInterface, action string) error {
+	approval, err := approvalFor(stub, action)
+	if err != nil {
+		return err
+	}
+	if approval != nil {
+		return errors.New("approval required, use requestUserChange")
+	}
+	return nil
+}
+
+// getChangeRequest 读取租户内的修改申请
+func getChangeRequest(stub shim.ChaincodeStubInterface, tenant, id string) (*ChangeRequest, error) {
+	key, err := stub.CreateCompositeKey(changeRequestKey, []string{tenant, id})
+	if err != nil {
+		return nil, fmt.Errorf("create change request key error:%s", err)
+	}
+	requestBytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("get change request state error:%s", err)
+	}
+	if len(requestBytes) == 0 {
+		return nil, fmt.Errorf("change request %s does not exist", id)
+	}
+	request := new(ChangeRequest)
+	if err := json.Unmarshal(requestBytes, request); err != nil {
+		return nil, fmt.Errorf("unmarshal change request error:%s", err)
+	}
+	return request, nil
+}
+
+// putChangeRequest 写入租户内的修改申请
+func putChangeRequest(stub shim.ChaincodeStubInterface, tenant string, request *ChangeRequest) error {
+	key, err := stub.CreateCompositeKey(changeRequestKey, []string{tenant, request.Id})
+	if err != nil {
+		return fmt.Errorf("create change request key error:%s", err)
+	}
+	requestBytes, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("marshal change request error:%s", err)
+	}
+	if err := stub.PutState(key, requestBytes); err != nil {
+		return fmt.Errorf("put change request state error:%s", err)
+	}
+	return nil
+}
+
+// expired 判断待审批的申请在交易时间 now 是否已过期
+func (r *ChangeRequest) expired(now time.Time) bool {
+	expiresAt, err := time.Parse(time.RFC3339Nano, r.ExpiresAt)
+	return err != nil || !now.Before(expiresAt)
+}
+
+// decidable 读取申请并校验调用者可以审批:具有本租户的审批角色、不是申请者、申请待审批且未过期
+func decidable(ctx contractapi.TransactionContextInterface, id string) (tenant string, request *ChangeRequest, checker string, now time.Time, err error) {
+	stub := ctx.GetStub()
+	if tenant, err = resolveTenant(ctx, true); err != nil {
+		return
+	}
+	if !hasTenantRole(ctx, tenant, checkerRole) {
+		err = errors.New("permission denied")
+		return
+	}
+	if request, err = getChangeRequest(stub, tenant, id); err != nil {
+		return
+	}
+	if checker, err = callerUserId(ctx); err != nil {
+		return
+	}
+	if now, err = txTime(stub); err != nil {
+		return
+	}
+	switch {
+	case request.Status != ChangePending:
+		err = fmt.Errorf("change request %s is %s", id, request.Status)
+	case request.expired(now):
+		err = fmt.Errorf("change request %s is %s", id, ChangeExpired)
+	case checker == request.Maker:
+		err = errors.New("maker cannot decide own change request")
+	}
+	return
+}
+
+// RequestUserChange
+// @title		RequestUserChange -> 提交修改申请
+// @description	申请修改或删除用户,申请保存在链上,由审批者审批;提交时按申请者的身份校验修改权限,提供加密密钥时申请中的用户信息加密保存。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @param		action	字符串		"alter 或 delete"
+// @param		user	UserInfo	"修改后的用户,删除时只需要id"
+// @return		id		字符串		"申请id"
+// @return		err		错误			"提交失败的原因"
+func (e *UserContract) RequestUserChange(ctx contractapi.TransactionContextInterface, action string, user UserInfo) (string, error) {
+	stub := ctx.GetStub()
+	tenant, err := resolveTenant(ctx, true)
+	if err != nil {
+		return "", err
+	}
+	if action != ChangeAlter && action != ChangeDelete {
+		return "", fmt.Errorf("request user change error:unknown action %s", action)
+	}
+	approval, err := approvalFor(stub, action)
+	if err != nil {
+		return "", err
+	}
+	if approval == nil {
+		return "", fmt.Errorf("request user change error:%s does not require approval", action)
+	}
+	old, found, err := getUser(stub, tenant, user.Id)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", fmt.Errorf("user %s does not exist", user.Id)
+	}
+	if err := checkOwner(ctx, tenant, old); err != nil {
+		return "", fmt.Errorf("request user change error:%s", err)
+	}
+	maker, err := callerUserId(ctx)
+	if err != nil {
+		return "", err
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		return "", err
+	}
+	// 只保存修改涉及的字段
+	requested := UserInfo{Id: user.Id}
+	if action == ChangeAlter {
+		if err := checkNotClosed(&old); err != nil {
+			return "", fmt.Errorf("request user change error:%s", err)
+		}
+		requested.Name, requested.Sex = user.Name, user.Sex
+		if err := checkPlaintext(&requested); err != nil {
+			return "", fmt.Errorf("request user change error:%s", err)
+		}
+		encKey, err := getEncryptionKey(stub)
+		if err != nil {
+			return "", err
+		}
+		if encKey != nil {
+			if err := encryptUser(encKey, &requested); err != nil {
+				return "", fmt.Errorf("encrypt user error:%s", err)
+			}
+		}
+	}
+	request := &ChangeRequest{
+		Id:        stub.GetTxID(),
+		Action:    action,
+		User:      requested,
+		Maker:     maker,
+		CreatedAt: now.Format(time.RFC3339Nano),
+		ExpiresAt: now.Add(time.Duration(approval.TTLSeconds) * time.Second).Format(time.RFC3339Nano),
+		Quorum:    approval.Quorum,
+		Approvals: []Approval{},
+		Status:    ChangePending,
+	}
+	return request.Id, putChangeRequest(stub, tenant, request)
+}
+
+// ApproveUserChange
+// @title		ApproveUserChange -> 审批修改申请
+// @description	审批者同意申请,同一审批者只计一次;审批数达到申请时的 quorum 后在同一交易内执行修改。执行修改时需要与申请时相同的加密密钥。
+// @auth		lzb
+// @param 		ctx		交易上下文		"包含所有链码API的库"
+// @param		id		字符串			"申请id"
+// @return		request	*ChangeRequest	"审批后的申请"
+func (e *UserContract) ApproveUserChange(ctx contractapi.TransactionContextInterface, id string) (*ChangeRequest, error) {
+	stub := ctx.GetStub()
+	tenant, request, checker, now, err := decidable(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("approve user change error:%s", err)
+	}
+	for _, approval := range request.Approvals {
+		if approval.Checker == checker {
+			return nil, fmt.Errorf("approve user change error:already approved by %s", checker)
+		}
+	}
+	at := now.Format(time.RFC3339Nano)
+	request.Approvals = append(request.Approvals, Approval{Checker: checker, At: at})
+	if len(request.Approvals) >= request.Quorum {
+		if err := applyChange(stub, tenant, request); err != nil {
+			return nil, err
+		}
+		request.Status, request.DecidedBy, request.DecidedAt = ChangeApplied, checker, at
+	}
+	return request, putChangeRequest(stub, tenant, request)
+}
+
+// applyChange 执行审批通过的修改,修改前的权限已在提交申请时校验
+func applyChange(stub shim.ChaincodeStubInterface, tenant string, request *ChangeRequest) error {
+	if request.Action == ChangeDelete {
+		return delUser(stub, tenant, request.User.Id, nil)
+	}
+	user := request.User
+	// 升级前提交的申请没有记录加密方案
+	markLegacyEncryption(&user)
+	if isEncrypted(user) {
+		encKey, err := getEncryptionKey(stub)
+		if err != nil {
+			return err
+		}
+		if encKey == nil {
+			return errors.New("user is encrypted, encryption key required")
+		}
+		if err := decryptUser(encKey, &user); err != nil {
+			return fmt.Errorf("decrypt user error:%s", err)
+		}
+	}
+	return alterUser(stub, tenant, user, nil)
+}
+
+// RejectUserChange
+// @title		RejectUserChange -> 拒绝修改申请
+// @description	审批者拒绝申请,申请不再能被审批。
+// @auth		lzb
+// @param 		ctx		交易上下文		"包含所有链码API的库"
+// @param		id		字符串			"申请id"
+// @param		reason	字符串			"拒绝原因"
+// @return		request	*ChangeRequest	"拒绝后的申请"
+func (e *UserContract) RejectUserChange(ctx contractapi.TransactionContextInterface, id string, reason string) (*ChangeRequest, error) {
+	tenant, request, checker, now, err := decidable(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("reject user change error:%s", err)
+	}
+	if reason == "" {
+		return nil, errors.New("reject user change error:reason is required")
+	}
+	request.Status, request.DecidedBy, request.DecidedAt, request.Reason = ChangeRejected, checker, now.Format(time.RFC3339Nano), reason
+	return request, putChangeRequest(ctx.GetStub(), tenant, request)
+}
+
+// ListUserChanges
+// @title		ListUserChanges -> 查询修改申请
+// @description	按申请id返回本租户的修改申请,申请中的用户信息按读取策略处理;超过有效期仍未处理的申请状态为 expired。
+// @auth		lzb
+// @param 		ctx			交易上下文			"包含所有链码API的库"
+// @param		status		字符串				"只返回该状态的申请,为空时返回全部"
+// @return		requests	[]*ChangeRequest	"修改申请"
+func (e *UserContract) ListUserChanges(ctx contractapi.TransactionContextInterface, status string) ([]*ChangeRequest, error) {
+	stub := ctx.GetStub()
+	tenant, v, err := readScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch status {
+	case "", ChangePending, ChangeApplied, ChangeRejected, ChangeExpired:
+	default:
+		return nil, fmt.Errorf("invalid change request status %q", status)
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		return nil, err
+	}
+	resultIterator, err := stub.GetStateByPartialCompositeKey(changeRequestKey, []string{tenant})
+	if err != nil {
+		return nil, fmt.Errorf("get change requests by partial composite key error:%s", err)
+	}
+	requests := make([]*ChangeRequest, 0)
+	err = iterate.Decode(resultIterator, maxQueryResults, func(key string, request *ChangeRequest) error {
+		if request.Status == ChangePending && request.expired(now) {
+			request.Status = ChangeExpired
+		}
+		// 申请中的用户信息与用户查询使用相同的读取策略
+		if (status == "" || request.Status == status) && v.redact(tenant, &request.User) {
+			requests = append(requests, request)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("change request iterator error:%s", err)
+	}
+	return requests, nil
+}
@@ -0,0 +1,157 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lzb13612/Example-Chaincode/internal/mockledger"
+)
+
+// approvalLedger 创建要求两人审批修改与删除的账本,返回两个审批者;申请者 testCreator 也具有审批角色
+func approvalLedger(t *testing.T) (*mockledger.Ledger, [][]byte) {
+	ledger := NewLedger(t, `{"approval":{"actions":["alter","delete"],"quorum":2,"ttlSeconds":3600}}`)
+	admin := newCreator(t, "Org1MSP", "admin", map[string]string{roleAttribute: adminRole})
+	for _, subject := range []string{"CN=checker1,O=Org1MSP", "CN=checker2,O=Org1MSP", "CN=lzb,O=Org1MSP"} {
+		if _, err := invokeAs(ledger, admin, "", "grantRole", testTenant, subject, checkerRole); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ledger, [][]byte{newCreator(t, "Org1MSP", "checker1", nil), newCreator(t, "Org1MSP", "checker2", nil)}
+}
+
+// requestChange 提交修改申请并返回申请id
+func requestChange(t *testing.T, ledger *mockledger.Ledger, action, user string) string {
+	t.Helper()
+	payload, err := invokeAs(ledger, testCreator, "", "requestUserChange", action, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var id ChangeRequestId
+	if err := json.Unmarshal(payload, &id); err != nil || id.Id == "" {
+		t.Fatalf("unexpected request payload %s", payload)
+	}
+	return id.Id
+}
+
+func TestApproval_Quorum(t *testing.T) {
+	ledger, checkers := approvalLedger(t)
+	for _, args := range [][]string{{"alterUser", `{"id":"1","name":"x"}`}, {"delUser", `{"id":"1"}`}} {
+		if _, err := invokeAs(ledger, testCreator, "", args[0], args[1]); err == nil || !strings.Contains(err.Error(), "approval required") {
+			t.Fatalf("%s: expected approval required, got %v", args[0], err)
+		}
+	}
+	id := requestChange(t, ledger, ChangeAlter, `{"id":"1","name":"renamed","sex":"女"}`)
+
+	steps := []struct {
+		identity []byte
+		err      string
+		status   string
+	}{
+		{testCreator, "maker cannot decide", ""},
+		{checkers[0], "", ChangePending},
+		{checkers[0], "already approved", ""},
+		{checkers[1], "", ChangeApplied},
+		{checkers[1], "is applied", ""},
+	}
+	for i, step := range steps {
+		payload, err := invokeAs(ledger, step.identity, "", "approveUserChange", id)
+		if step.err != "" {
+			if err == nil || !strings.Contains(err.Error(), step.err) {
+				t.Fatalf("step %d: expected %q, got %v", i, step.err, err)
+			}
+			continue
+		}
+		var request ChangeRequest
+		if err != nil || json.Unmarshal(payload, &request) != nil || request.Status != step.status {
+			t.Fatalf("step %d: expected %s, got %s %v", i, step.status, payload, err)
+		}
+		// 达到审批数之前用户不变
+		user, _ := invokeAs(ledger, testCreator, "", "queryOnceUser", `{"id":"1"}`)
+		if renamed := strings.Contains(string(user), "renamed"); renamed != (step.status == ChangeApplied) {
+			t.Fatalf("step %d: unexpected user %s", i, user)
+		}
+	}
+	if payload, _ := invokeAs(ledger, testCreator, "", "queryUserByName", `{"name":"renamed"}`); userIds(t, payload) != "1" {
+		t.Fatalf("name index not updated: %s", payload)
+	}
+}
+
+func TestApproval_RejectAndDelete(t *testing.T) {
+	ledger, checkers := approvalLedger(t)
+	outsider := newCreator(t, "Org1MSP", "alice", nil)
+	rejected := requestChange(t, ledger, ChangeDelete, `{"id":"2"}`)
+	if _, err := invokeAs(ledger, outsider, "", "rejectUserChange", rejected, "no"); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+	if _, err := invokeAs(ledger, checkers[0], "", "rejectUserChange", rejected, ""); err == nil || !strings.Contains(err.Error(), "reason is required") {
+		t.Fatalf("expected reason error, got %v", err)
+	}
+	if _, err := invokeAs(ledger, checkers[0], "", "rejectUserChange", rejected, "still needed"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := invokeAs(ledger, checkers[1], "", "approveUserChange", rejected); err == nil || !strings.Contains(err.Error(), "is rejected") {
+		t.Fatalf("expected rejected error, got %v", err)
+	}
+
+	deleted := requestChange(t, ledger, ChangeDelete, `{"id":"2"}`)
+	for _, checker := range checkers {
+		if _, err := invokeAs(ledger, checker, "", "approveUserChange", deleted); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if payload, _ := invokeAs(ledger, testCreator, "", "queryAllUser"); userIds(t, payload) != "1" {
+		t.Fatalf("user not deleted: %s", payload)
+	}
+	payload, _ := invokeAs(ledger, testCreator, "", "listUserChanges", ChangeRejected)
+	var requests []ChangeRequest
+	_ = json.Unmarshal(payload, &requests)
+	if len(requests) != 1 || requests[0].Id != rejected || requests[0].Reason != "still needed" || requests[0].DecidedBy == "" {
+		t.Fatalf("unexpected rejected requests %s", payload)
+	}
+}
+
+func TestApproval_Expiry(t *testing.T) {
+	ledger, checkers := approvalLedger(t)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ledger.SetClock(func() time.Time { return now })
+	id := requestChange(t, ledger, ChangeAlter, `{"id":"1","name":"late"}`)
+	if _, err := invokeAs(ledger, checkers[0], "", "approveUserChange", id); err != nil {
+		t.Fatal(err)
+	}
+
+	// 有效期按交易时间计算,到期后不能再审批
+	now = now.Add(time.Hour)
+	if _, err := invokeAs(ledger, checkers[1], "", "approveUserChange", id); err == nil || !strings.Contains(err.Error(), "is expired") {
+		t.Fatalf("expected expired error, got %v", err)
+	}
+	for status, count := range map[string]int{"": 1, ChangePending: 0, ChangeExpired: 1} {
+		payload, err := invokeAs(ledger, testCreator, "", "listUserChanges", status)
+		var requests []ChangeRequest
+		if err != nil || json.Unmarshal(payload, &requests) != nil || len(requests) != count {
+			t.Fatalf("status %q: expected %d requests, got %s %v", status, count, payload, err)
+		}
+	}
+	if _, err := invokeAs(ledger, testCreator, "", "listUserChanges", "unknown"); err == nil {
+		t.Fatal("expected invalid status error")
+	}
+}
+
+func TestApproval_Config(t *testing.T) {
+	ledger := NewLedger(t, "")
+	admin := newCreator(t, "Org1MSP", "admin", map[string]string{roleAttribute: adminRole})
+	if _, err := invokeAs(ledger, testCreator, "", "requestUserChange", ChangeDelete, `{"id":"1"}`); err == nil || !strings.Contains(err.Error(), "does not require approval") {
+		t.Fatalf("expected approval not configured, got %v", err)
+	}
+	for _, config := range []string{
+		`{"approval":{"actions":[],"quorum":1,"ttlSeconds":60}}`,
+		`{"approval":{"actions":["rename"],"quorum":1,"ttlSeconds":60}}`,
+		`{"approval":{"actions":["alter"],"quorum":0,"ttlSeconds":60}}`,
+		`{"approval":{"actions":["alter"],"quorum":1,"ttlSeconds":0}}`,
+	} {
+		if _, err := invokeAs(ledger, admin, "", "setConfig", config); err == nil || !strings.Contains(err.Error(), "config error") {
+			t.Fatalf("%s: expected config error, got %v", config, err)
+		}
+	}
+}
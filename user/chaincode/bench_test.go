@@ -0,0 +1,128 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/lzb13612/Example-Chaincode/internal/mockledger"
+)
+
+// benchSizes 基准测试中账本的用户数
+var benchSizes = []int{1000, 10000, 100000}
+
+// benchSnapshots 按用户数缓存已写入用户的账本快照,避免每个基准测试重复写入
+var benchSnapshots = make(map[int]*mockledger.Snapshot)
+
+// benchUser 第 i 个基准测试用户的 JSON 参数
+func benchUser(i int) string {
+	return fmt.Sprintf(`{"id":"%d","name":"user%d","sex":"男"}`, i, i)
+}
+
+// newBenchLedger 创建包含 n 个用户(含两个种子用户)的账本
+func newBenchLedger(b *testing.B, n int) *mockledger.Ledger {
+	if n >= 100000 && testing.Short() {
+		b.Skip("skipping large ledger in short mode")
+	}
+	ledger := NewLedger(b, "")
+	if snap, ok := benchSnapshots[n]; ok {
+		ledger.Restore(snap)
+	} else {
+		for i := len(seedUsers) + 1; i <= n; i++ {
+			if res := ledger.Invoke("addUser", []string{benchUser(i)}, nil); res.Status != shim.OK {
+				b.Fatal(res.Message)
+			}
+		}
+		benchSnapshots[n] = ledger.Snapshot()
+	}
+	// 预先完成一次全量区间读取,使账本建立排序后的键,首次区间查询的排序不计入结果
+	ledger.Range("", "")
+	return ledger
+}
+
+// benchRun 在每个用户数下重复执行 run,i 为迭代序号
+func benchRun(b *testing.B, run func(b *testing.B, ledger *mockledger.Ledger, n, i int)) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("users=%d", n), func(b *testing.B) {
+			ledger := newBenchLedger(b, n)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				run(b, ledger, n, i)
+			}
+		})
+	}
+}
+
+// benchInvoke 在每个用户数下重复调用 function,args 根据迭代序号生成参数
+func benchInvoke(b *testing.B, function string, args func(n, i int) []string) {
+	benchRun(b, func(b *testing.B, ledger *mockledger.Ledger, n, i int) {
+		if res := ledger.Invoke(function, args(n, i), nil); res.Status != shim.OK {
+			b.Fatal(res.Message)
+		}
+	})
+}
+
+// benchReadAll 读取账本中的全部 n 个用户:不超过 maxQueryResults 个时调用非分页函数 all,否则按 maxQueryResults 调用分页函数 page 直到没有书签
+func benchReadAll(b *testing.B, ledger *mockledger.Ledger, n int, all, page string) {
+	if n <= maxQueryResults {
+		if res := ledger.Invoke(all, nil, nil); res.Status != shim.OK {
+			b.Fatal(res.Message)
+		}
+		return
+	}
+	bookmark := ""
+	for {
+		res := ledger.Invoke(page, []string{strconv.Itoa(maxQueryResults), bookmark}, nil)
+		if res.Status != shim.OK {
+			b.Fatal(res.Message)
+		}
+		var userPage UserPage
+		if err := json.Unmarshal(res.Payload, &userPage); err != nil {
+			b.Fatal(err)
+		}
+		if userPage.Bookmark == "" || userPage.Count == 0 {
+			return
+		}
+		bookmark = userPage.Bookmark
+	}
+}
+
+func BenchmarkAddUser(b *testing.B) {
+	benchInvoke(b, "addUser", func(n, i int) []string {
+		return []string{benchUser(n + i + 1)}
+	})
+}
+
+func BenchmarkQueryOnceUser(b *testing.B) {
+	benchInvoke(b, "queryOnceUser", func(n, i int) []string {
+		return []string{fmt.Sprintf(`{"id":"%d"}`, i%n+1)}
+	})
+}
+
+// BenchmarkQueryAllUser 对比旧版函数的流式编码与合约函数先构造 []*UserInfo 再序列化;用户超过 maxQueryResults 个时非分页查询会返回错误,改为分页读取全部用户
+func BenchmarkQueryAllUser(b *testing.B) {
+	b.Run("stream", func(b *testing.B) {
+		benchRun(b, func(b *testing.B, ledger *mockledger.Ledger, n, i int) {
+			benchReadAll(b, ledger, n, "queryAllUser", "queryUserPage")
+		})
+	})
+	b.Run("decode", func(b *testing.B) {
+		benchRun(b, func(b *testing.B, ledger *mockledger.Ledger, n, i int) {
+			benchReadAll(b, ledger, n, "QueryAllUser", "QueryUserPage")
+		})
+	})
+}
+
+// BenchmarkQueryUserPage 查询第一页,每页 100 个用户
+func BenchmarkQueryUserPage(b *testing.B) {
+	pageSize := strconv.Itoa(100)
+	b.Run("stream", func(b *testing.B) {
+		benchInvoke(b, "queryUserPage", func(n, i int) []string { return []string{pageSize} })
+	})
+	b.Run("decode", func(b *testing.B) {
+		benchInvoke(b, "QueryUserPage", func(n, i int) []string { return []string{pageSize, ""} })
+	})
+}
@@ -0,0 +1,103 @@
+package chaincode
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/lzb13612/Example-Chaincode/internal/mockledger"
+)
+
+// NewLedger 创建运行用户链码的模拟账本,config 作为全新部署的链码配置,见 testConfig
+func NewLedger(t testing.TB, config string) *mockledger.Ledger {
+	cc, err := NewChaincode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledger, err := mockledger.New("user", cc, testCreator, "", testConfig(t, config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ledger
+}
+
+// endorse 在同一个已提交状态上模拟多笔交易,模拟均需成功
+func endorse(t *testing.T, ledger *mockledger.Ledger, function string, args ...string) []*mockledger.Transaction {
+	txs := make([]*mockledger.Transaction, len(args))
+	for i, arg := range args {
+		txs[i] = ledger.Simulate(mockledger.Proposal{Function: function, Args: []string{arg}})
+		if txs[i].Response.Status != shim.OK {
+			t.Fatalf("%s %s failed: %s", function, arg, txs[i].Response.Message)
+		}
+	}
+	return txs
+}
+
+// expectCodes 提交区块并比较校验结果
+func expectCodes(t *testing.T, ledger *mockledger.Ledger, txs []*mockledger.Transaction, expected ...pb.TxValidationCode) {
+	codes, err := ledger.CommitBlock(txs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(codes, expected) {
+		t.Fatalf("expected %v, got %v", expected, codes)
+	}
+}
+
+func TestConcurrency_AlterUserSameState(t *testing.T) {
+	ledger := NewLedger(t, "")
+	txs := endorse(t, ledger, "alterUser",
+		`{"id":"1","name":"lzb1","sex":"女"}`,
+		`{"id":"1","name":"lzb1_new","sex":"男"}`,
+	)
+	expectCodes(t, ledger, txs, pb.TxValidationCode_VALID, pb.TxValidationCode_MVCC_READ_CONFLICT)
+
+	res := ledger.Invoke("queryOnceUser", []string{`{"id":"1"}`}, nil)
+	if string(res.Payload) != `{"id":"1","name":"lzb1","sex":"女"}` {
+		t.Fatalf("expected first alterUser to win, got %s", res.Payload)
+	}
+	// 被拒绝的改名交易没有留下新用户名的索引
+	res = ledger.Invoke("queryUserByName", []string{`{"name":"lzb1_new"}`}, nil)
+	if string(res.Payload) != "[]" {
+		t.Fatalf("expected no user named lzb1_new, got %s", res.Payload)
+	}
+}
+
+func TestConcurrency_AlterAndDelete(t *testing.T) {
+	ledger := NewLedger(t, "")
+	alter := endorse(t, ledger, "alterUser", `{"id":"2","name":"lzb2","sex":"男"}`)
+	del := endorse(t, ledger, "delUser", `{"id":"2"}`)
+	expectCodes(t, ledger, append(del, alter...), pb.TxValidationCode_VALID, pb.TxValidationCode_MVCC_READ_CONFLICT)
+	if res := ledger.Invoke("queryOnceUser", []string{`{"id":"2"}`}, nil); res.Status == shim.OK {
+		t.Fatal("alterUser must not resurrect a deleted user")
+	}
+}
+
+func TestConcurrency_AddUserSameId(t *testing.T) {
+	ledger := NewLedger(t, "")
+	txs := endorse(t, ledger, "addUser",
+		`{"id":"3","name":"lzb3","sex":"男"}`,
+		`{"id":"3","name":"lzb33","sex":"女"}`,
+	)
+	expectCodes(t, ledger, txs, pb.TxValidationCode_VALID, pb.TxValidationCode_MVCC_READ_CONFLICT)
+}
+
+func TestConcurrency_UniqueNames(t *testing.T) {
+	ledger := NewLedger(t, `{"uniqueNames":true}`)
+	txs := endorse(t, ledger, "addUser",
+		`{"id":"3","name":"same","sex":"男"}`,
+		`{"id":"4","name":"same","sex":"女"}`,
+	)
+	// 两笔交易都没有看到对方占用的用户名,第二笔的区间查询结果在提交时已经变化
+	expectCodes(t, ledger, txs, pb.TxValidationCode_VALID, pb.TxValidationCode_PHANTOM_READ_CONFLICT)
+}
+
+func TestConcurrency_IndependentUsers(t *testing.T) {
+	ledger := NewLedger(t, "")
+	txs := endorse(t, ledger, "alterUser",
+		`{"id":"1","name":"lzb1","sex":"女"}`,
+		`{"id":"2","name":"lzb2","sex":"男"}`,
+	)
+	expectCodes(t, ledger, txs, pb.TxValidationCode_VALID, pb.TxValidationCode_VALID)
+}
@@ -0,0 +1,287 @@
+package chaincode
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/lzb13612/Example-Chaincode/internal/iterate"
+)
+
+func init() {
+	registerMigration(4, "explicit encryption scheme", markEncryptedUsers)
+}
+
+// transientKeyName 通过 transient 传入加密密钥时使用的字段名,密钥不会写入账本
+const transientKeyName = "userEncryptionKey"
+
+// 加密方案,记录在 UserInfo.Encryption 中,由链码维护
+const (
+	encryptionNone   = 0 // 明文
+	encryptionLegacy = 1 // 旧版方案:值带 encryptedPrefix 前缀,AES-GCM 与 nonce 派生共用 transient 中的密钥,只用于读取升级前的数据
+	encryptionHKDF   = 2 // 当前方案:AES-GCM 与 nonce 派生分别使用由 HKDF 从 transient 密钥派生的子密钥
+)
+
+// encryptedPrefix 旧版方案加密字段值的前缀;当前版本不再以前缀区分明文与密文,并拒绝以它开头的明文输入
+const encryptedPrefix = "enc:"
+
+// HKDF 派生子密钥时使用的标签,不同用途的子密钥互不相同
+const (
+	aeadKeyLabel      = "user-encryption/aes-gcm"
+	nonceKeyLabel     = "user-encryption/nonce"
+	nameIndexKeyLabel = "user-encryption/name-index"
+)
+
+// sensitiveFields 需要加密的用户字段,键名作为附加认证数据参与加密
+func sensitiveFields(user *UserInfo) map[string]*string {
+	return map[string]*string{
+		"name": &user.Name,
+		"sex":  &user.Sex,
+	}
+}
+
+// getEncryptionKey
+// @title		getEncryptionKey -> 获取加密密钥
+// @description	从交易的 transient 中读取 AES 密钥,未提供时返回 nil 表示不加密。
+// @auth		lzb
+// @param 		stub	shim库	"包含所有链码API的库"
+// @return		key		字符组	"AES-128/192/256 密钥"
+// @return		err		错误		"密钥长度不合法等错误"
+func getEncryptionKey(stub shim.ChaincodeStubInterface) ([]byte, error) {
+	transient, err := stub.GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("get transient error:%s", err)
+	}
+	key, ok := transient[transientKeyName]
+	if !ok || len(key) == 0 {
+		return nil, nil
+	}
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("invalid encryption key length %d", len(key))
+	}
+}
+
+// deriveKey
+// @title		deriveKey -> 派生子密钥
+// @description	按 RFC 5869 的 HKDF-SHA256(空 salt)从密钥派生 size 字节的子密钥,size 不超过 32。
+// @auth		lzb
+// @param		key		字符组	"transient 中的密钥"
+// @param		label	字符串	"子密钥用途标签(HKDF info)"
+// @param		size	整型		"子密钥长度"
+// @return		subkey	字符组	"子密钥"
+func deriveKey(key []byte, label string, size int) []byte {
+	extract := hmac.New(sha256.New, make([]byte, sha256.Size))
+	extract.Write(key)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write([]byte(label))
+	expand.Write([]byte{1})
+	return expand.Sum(nil)[:size]
+}
+
+// encryptField
+// @title		encryptField -> 加密字段
+// @description	按当前方案使用 AES-GCM 加密字段值,AES 子密钥与 transient 密钥长度相同。nonce 由另一个子密钥对(用户id,字段名,明文)做 HMAC 派生,
+// @description	相同输入得到相同密文,保证各背书节点的读写集一致。
+// @auth		lzb
+// @param		key			字符组	"AES 密钥"
+// @param		id			字符串	"用户id"
+// @param		field		字符串	"字段名"
+// @param		plaintext	字符串	"明文"
+// @return		value		字符串	"base64 密文"
+func encryptField(key []byte, id, field, plaintext string) (string, error) {
+	if plaintext == "" {
+		return plaintext, nil
+	}
+	aead, err := newAEAD(deriveKey(key, aeadKeyLabel, len(key)))
+	if err != nil {
+		return "", err
+	}
+	aad := []byte(id + "\x00" + field)
+	mac := hmac.New(sha256.New, deriveKey(key, nonceKeyLabel, sha256.Size))
+	mac.Write(aad)
+	mac.Write([]byte{0})
+	mac.Write([]byte(plaintext))
+	nonce := mac.Sum(nil)[:aead.NonceSize()]
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), aad)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptField
+// @title		decryptField -> 解密字段
+// @description	按记录的加密方案解密字段值,空值原样返回。
+// @auth		lzb
+// @param		key			字符组	"AES 密钥"
+// @param		scheme		整型		"加密方案"
+// @param		id			字符串	"用户id"
+// @param		field		字符串	"字段名"
+// @param		value		字符串	"base64 密文"
+// @return		plaintext	字符串	"明文"
+func decryptField(key []byte, scheme int, id, field, value string) (string, error) {
+	if value == "" {
+		return value, nil
+	}
+	aeadKey := key
+	switch scheme {
+	case encryptionLegacy:
+		if !strings.HasPrefix(value, encryptedPrefix) {
+			return value, nil
+		}
+		value = strings.TrimPrefix(value, encryptedPrefix)
+	case encryptionHKDF:
+		aeadKey = deriveKey(key, aeadKeyLabel, len(key))
+	default:
+		return "", fmt.Errorf("unknown encryption scheme %d", scheme)
+	}
+	aead, err := newAEAD(aeadKey)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", fmt.Errorf("decode %s error:%s", field, err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("%s ciphertext too short", field)
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(id+"\x00"+field))
+	if err != nil {
+		return "", fmt.Errorf("decrypt %s error:%s", field, err)
+	}
+	return string(plaintext), nil
+}
+
+// encryptUser 按当前方案加密用户的敏感字段并记录加密方案
+func encryptUser(key []byte, user *UserInfo) error {
+	for field, value := range sensitiveFields(user) {
+		encrypted, err := encryptField(key, user.Id, field, *value)
+		if err != nil {
+			return err
+		}
+		*value = encrypted
+	}
+	user.Encryption = encryptionHKDF
+	return nil
+}
+
+// decryptUser 按记录的加密方案解密用户的敏感字段,明文用户不做处理
+func decryptUser(key []byte, user *UserInfo) error {
+	if !isEncrypted(*user) {
+		return nil
+	}
+	for field, value := range sensitiveFields(user) {
+		decrypted, err := decryptField(key, user.Encryption, user.Id, field, *value)
+		if err != nil {
+			return err
+		}
+		*value = decrypted
+	}
+	user.Encryption = encryptionNone
+	return nil
+}
+
+// writeScheme 返回本次交易写入用户时使用的加密方案:提供密钥时按当前方案加密,否则写入明文
+func writeScheme(key []byte) int {
+	if key == nil {
+		return encryptionNone
+	}
+	return encryptionHKDF
+}
+
+// isEncrypted 判断用户的敏感字段是否已加密
+func isEncrypted(user UserInfo) bool {
+	return user.Encryption != encryptionNone
+}
+
+// checkPlaintext
+// @title		checkPlaintext -> 校验明文输入
+// @description	加密方案只由链码记录,清除调用者提交的方案;敏感字段不能以旧版密文前缀开头,避免与升级前的密文混淆。
+// @auth		lzb
+// @param		user	*UserInfo	"调用者提交的用户,原地修改"
+// @return		err		错误			"字段以旧版密文前缀开头"
+func checkPlaintext(user *UserInfo) error {
+	user.Encryption = encryptionNone
+	for field, value := range sensitiveFields(user) {
+		if strings.HasPrefix(*value, encryptedPrefix) {
+			return fmt.Errorf("%s must not start with %q", field, encryptedPrefix)
+		}
+	}
+	return nil
+}
+
+// markLegacyEncryption 升级前的数据没有加密方案字段:敏感字段带旧版密文前缀的未标记用户按旧版方案处理。
+// 当前版本拒绝以该前缀开头的明文,因此只有升级前写入的数据会被标记。
+func markLegacyEncryption(user *UserInfo) {
+	if user.Encryption != encryptionNone {
+		return
+	}
+	for _, value := range sensitiveFields(user) {
+		if strings.HasPrefix(*value, encryptedPrefix) {
+			user.Encryption = encryptionLegacy
+			return
+		}
+	}
+}
+
+// markLegacyValue 为用户数据标记旧版加密方案,返回当前格式的用户数据,不需要标记时原样返回
+func markLegacyValue(value []byte) ([]byte, error) {
+	var user UserInfo
+	if err := json.Unmarshal(value, &user); err != nil {
+		return nil, fmt.Errorf("unmarshal user error:%s", err)
+	}
+	if isEncrypted(user) {
+		return value, nil
+	}
+	if markLegacyEncryption(&user); !isEncrypted(user) {
+		return value, nil
+	}
+	return json.Marshal(user)
+}
+
+// markEncryptedUsers 迁移步骤 -> 为版本 4 的账本中以旧版方案加密的用户记录加密方案;之前的迁移步骤写入用户时已经标记
+func markEncryptedUsers(stub shim.ChaincodeStubInterface) error {
+	resultIterator, err := stub.GetStateByRange(userKeyPrefix, userKeyEnd)
+	if err != nil {
+		return fmt.Errorf("get user info by range error:%s", err)
+	}
+	// 先读出全部用户,再统一写入,避免边遍历边修改
+	users := make([]*queryresult.KV, 0)
+	err = iterate.States(resultIterator, iterate.Unlimited, func(kv *queryresult.KV) error {
+		users = append(users, kv)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("user iterator error:%s", err)
+	}
+	for _, kv := range users {
+		value, err := markLegacyValue(kv.Value)
+		if err != nil {
+			return fmt.Errorf("user of key %q error:%s", kv.Key, err)
+		}
+		if string(value) == string(kv.Value) {
+			continue
+		}
+		if err := stub.PutState(kv.Key, value); err != nil {
+			return fmt.Errorf("put user state of key %q error:%s", kv.Key, err)
+		}
+	}
+	return nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("new cipher error:%s", err)
+	}
+	return cipher.NewGCM(block)
+}
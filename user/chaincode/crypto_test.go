@@ -0,0 +1,231 @@
+package chaincode
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+var testEncryptionKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestUser_addUserEncrypted(t *testing.T) {
+	stub := GetNewStub()
+	stub.TransientMap = map[string][]byte{transientKeyName: testEncryptionKey}
+	res := stub.MockInvoke("1", [][]byte{[]byte("addUser"), user1})
+	if res.Status != shim.OK {
+		t.Fatalf("addUser: %s", res.Message)
+	}
+
+	key, _ := userKey(testTenant, id1)
+	var stored UserInfo
+	_ = json.Unmarshal(stub.State[key], &stored)
+	if stored.Encryption != encryptionHKDF || stored.Name == name1 || stored.Sex == sex1 {
+		t.Fatalf("fields stored in plaintext: %+v", stored)
+	}
+	if strings.HasPrefix(stored.Name, encryptedPrefix) {
+		t.Fatalf("ciphertext must not carry the legacy prefix: %+v", stored)
+	}
+	if stored.Id != id1 {
+		t.Fatalf("id must stay in plaintext: %+v", stored)
+	}
+
+	res = stub.MockInvoke("2", [][]byte{[]byte("queryOnceUser"), user1})
+	var got UserInfoTest
+	_ = json.Unmarshal(res.Payload, &got)
+	if got != userInfoTest1 {
+		t.Fatalf("queryOnceUser with key = %+v, want %+v", got, userInfoTest1)
+	}
+
+	stub.TransientMap = nil
+	res = stub.MockInvoke("3", [][]byte{[]byte("queryOnceUser"), user1})
+	_ = json.Unmarshal(res.Payload, &got)
+	if got.Name != stored.Name {
+		t.Fatalf("queryOnceUser without key should return ciphertext, got %+v", got)
+	}
+}
+
+func TestUser_alterUserEncrypted(t *testing.T) {
+	stub := GetNewStub()
+	stub.TransientMap = map[string][]byte{transientKeyName: testEncryptionKey}
+	stub.MockInvoke("1", [][]byte{[]byte("addUser"), user1})
+
+	newUserInfo, _ := json.Marshal(UserInfoTest{Id: id1, Name: "test", Sex: sex1})
+	res := stub.MockInvoke("2", [][]byte{[]byte("alterUser"), newUserInfo})
+	if res.Status != shim.OK {
+		t.Fatalf("alterUser: %s", res.Message)
+	}
+	res = stub.MockInvoke("3", [][]byte{[]byte("queryOnceUser"), user1})
+	var got UserInfoTest
+	_ = json.Unmarshal(res.Payload, &got)
+	if got.Name != "test" || got.Sex != sex1 {
+		t.Fatalf("alterUser with key = %+v", got)
+	}
+
+	stub.TransientMap = map[string][]byte{transientKeyName: []byte("fedcba9876543210fedcba9876543210")}
+	res = stub.MockInvoke("4", [][]byte{[]byte("queryOnceUser"), user1})
+	if res.Status == shim.OK {
+		t.Fatalf("queryOnceUser with wrong key should fail, got %s", res.Payload)
+	}
+}
+
+func TestEncryptField_Deterministic(t *testing.T) {
+	a, err := encryptField(testEncryptionKey, id1, "name", name1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := encryptField(testEncryptionKey, id1, "name", name1)
+	if a != b {
+		t.Fatalf("ciphertext differs between endorsements: %s != %s", a, b)
+	}
+	c, _ := encryptField(testEncryptionKey, id2, "name", name1)
+	if a == c {
+		t.Fatal("ciphertext must be bound to the user id")
+	}
+	if _, err := decryptField(testEncryptionKey, encryptionHKDF, id2, "name", a); err == nil {
+		t.Fatal("ciphertext moved to another user should not decrypt")
+	}
+	plain, err := decryptField(testEncryptionKey, encryptionHKDF, id1, "name", a)
+	if err != nil || plain != name1 {
+		t.Fatalf("decryptField = %q, %v", plain, err)
+	}
+	if bytes.Contains([]byte(a), []byte(name1)) {
+		t.Fatal("plaintext leaked into ciphertext")
+	}
+}
+
+func TestGetEncryptionKey_InvalidLength(t *testing.T) {
+	stub := GetNewStub()
+	stub.TransientMap = map[string][]byte{transientKeyName: []byte("short")}
+	res := stub.MockInvoke("1", [][]byte{[]byte("addUser"), user1})
+	if res.Status == shim.OK {
+		t.Fatal("addUser with invalid key length should fail")
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	// RFC 5869 A.3:salt 与 info 为空
+	okm := deriveKey(bytes.Repeat([]byte{0x0b}, 22), "", 32)
+	if got := hex.EncodeToString(okm); got != "8da4e775a563c18f715f802a063c5a31b8a11f5c5ee1879ec3454e5f3c738d2d" {
+		t.Fatalf("deriveKey = %s", got)
+	}
+	aeadKey := deriveKey(testEncryptionKey, aeadKeyLabel, len(testEncryptionKey))
+	nonceKey := deriveKey(testEncryptionKey, nonceKeyLabel, len(testEncryptionKey))
+	if bytes.Equal(aeadKey, nonceKey) || bytes.Equal(aeadKey, testEncryptionKey) {
+		t.Fatal("subkeys must differ from each other and from the transient key")
+	}
+}
+
+func TestUser_rejectsCiphertextInput(t *testing.T) {
+	stub := GetNewStub()
+	forged, _ := json.Marshal(UserInfo{Id: id1, Name: encryptedPrefix + "abc", Sex: sex1})
+	if res := stub.MockInvoke("1", [][]byte{[]byte("addUser"), forged}); res.Status == shim.OK {
+		t.Fatal("addUser with a legacy ciphertext prefix should fail")
+	}
+	stub.MockInvoke("2", [][]byte{[]byte("addUser"), user1})
+	if res := stub.MockInvoke("3", [][]byte{[]byte("alterUser"), forged}); res.Status == shim.OK {
+		t.Fatal("alterUser with a legacy ciphertext prefix should fail")
+	}
+
+	// 调用者提交的加密方案被忽略,明文不会被当作密文
+	marked, _ := json.Marshal(UserInfo{Id: id2, Name: name2, Sex: sex2, Encryption: encryptionHKDF})
+	if res := stub.MockInvoke("4", [][]byte{[]byte("addUser"), marked}); res.Status != shim.OK {
+		t.Fatalf("addUser: %s", res.Message)
+	}
+	key, _ := userKey(testTenant, id2)
+	var stored UserInfo
+	_ = json.Unmarshal(stub.State[key], &stored)
+	if stored.Encryption != encryptionNone || stored.Name != name2 {
+		t.Fatalf("submitted encryption scheme kept: %+v", stored)
+	}
+}
+
+// legacyEncryptField 旧版方案的加密:AES 与 nonce 共用密钥,值带前缀
+func legacyEncryptField(key []byte, id, field, plaintext string) string {
+	aead, _ := newAEAD(key)
+	aad := []byte(id + "\x00" + field)
+	mac := hmac.New(sha256.New, key)
+	mac.Write(aad)
+	mac.Write([]byte{0})
+	mac.Write([]byte(plaintext))
+	nonce := mac.Sum(nil)[:aead.NonceSize()]
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(plaintext), aad))
+}
+
+func TestMarkEncryptedUsers(t *testing.T) {
+	// 版本 4 的账本:旧版方案加密的用户没有记录加密方案
+	stub := NewStub("v4")
+	legacyUser := UserInfo{Id: id1, Name: legacyEncryptField(testEncryptionKey, id1, "name", name1), Sex: legacyEncryptField(testEncryptionKey, id1, "sex", sex1)}
+	legacyBytes, _ := json.Marshal(legacyUser)
+	plainBytes, _ := json.Marshal(UserInfo{Id: id2, Name: name2, Sex: sex2})
+	legacyKey, _ := userKey(testTenant, id1)
+	plainKey, _ := userKey(testTenant, id2)
+	stub.MockTransactionStart("v4")
+	_ = putMeta(stub, ChaincodeMeta{Version: 4, Deployer: testTenant})
+	_ = stub.PutState(legacyKey, legacyBytes)
+	_ = stub.PutState(plainKey, plainBytes)
+	// 以当前调用者为添加者,使其可以修改该用户
+	identity, _ := cid.New(stub)
+	creator, _ := identityUserId(identity)
+	_ = putCreator(stub, testTenant, id1, creator)
+	// 升级前的用户名索引直接以 transient 密钥计算 HMAC
+	legacyIndexName := nameIndexValue(testEncryptionKey, encryptionLegacy, name1)
+	_ = putNameIndex(stub, testTenant, legacyIndexName, id1)
+	stub.MockTransactionEnd("v4")
+
+	if res := stub.MockInit("upgrade", [][]byte{[]byte("init")}); res.Status != shim.OK {
+		t.Fatalf("upgrade: %s", res.Message)
+	}
+	var stored UserInfo
+	_ = json.Unmarshal(stub.State[legacyKey], &stored)
+	if stored.Encryption != encryptionLegacy || stored.Name != legacyUser.Name {
+		t.Fatalf("legacy user not marked: %+v", stored)
+	}
+	if !bytes.Equal(stub.State[plainKey], plainBytes) {
+		t.Fatalf("plaintext user changed: %s", stub.State[plainKey])
+	}
+
+	stub.TransientMap = map[string][]byte{transientKeyName: testEncryptionKey}
+	res := stub.MockInvoke("1", [][]byte{[]byte("queryOnceUser"), user1})
+	var got UserInfoTest
+	_ = json.Unmarshal(res.Payload, &got)
+	if got != userInfoTest1 {
+		t.Fatalf("queryOnceUser of legacy user = %+v %s, want %+v", got, res.Message, userInfoTest1)
+	}
+	if users := queryByName(t, stub, "name", name1); len(users) != 1 || users[0] != userInfoTest1 {
+		t.Fatalf("legacy user not found by name: %+v", users)
+	}
+
+	// 修改后按当前方案重新加密
+	newUserInfo, _ := json.Marshal(UserInfoTest{Id: id1, Name: name1, Sex: sex2})
+	if res := stub.MockInvoke("2", [][]byte{[]byte("alterUser"), newUserInfo}); res.Status != shim.OK {
+		t.Fatalf("alterUser: %s", res.Message)
+	}
+	_ = json.Unmarshal(stub.State[legacyKey], &stored)
+	if stored.Encryption != encryptionHKDF || strings.HasPrefix(stored.Name, encryptedPrefix) {
+		t.Fatalf("altered user not re-encrypted with the current scheme: %+v", stored)
+	}
+	if ids := nameIndexIds(t, stub, legacyIndexName); len(ids) != 0 {
+		t.Fatalf("legacy name index kept after re-encryption: %v", ids)
+	}
+	if ids := nameIndexIds(t, stub, nameIndexValue(testEncryptionKey, encryptionHKDF, name1)); len(ids) != 1 {
+		t.Fatalf("re-encrypted user not indexed with the name index subkey: %v", ids)
+	}
+}
+
+func TestNameIndexValue_Subkey(t *testing.T) {
+	current := nameIndexValue(testEncryptionKey, encryptionHKDF, name1)
+	if current == nameIndexValue(testEncryptionKey, encryptionLegacy, name1) {
+		t.Fatal("name index HMAC keyed with the raw transient key")
+	}
+	if current == nameIndexValue(testEncryptionKey, encryptionHKDF, name2) {
+		t.Fatal("different names share an index value")
+	}
+}
@@ -0,0 +1,465 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/lzb13612/Example-Chaincode/internal/iterate"
+	"github.com/lzb13612/Example-Chaincode/internal/legacy"
+)
+
+// 用户组的复合键类型,成员关系双向索引,按组查成员与按用户查组都是前缀查询
+const (
+	groupKey       = "group"      // 用户组 -> group[租户, 组id]
+	groupUserIndex = "group~user" // 组的成员 -> group~user[租户, 组id, 用户id]
+	userGroupIndex = "user~group" // 用户所在的组 -> user~group[租户, 用户id, 组id]
+)
+
+// Group 用户组
+type Group struct {
+	Id          string `json:"id"`                                         // 组id
+	Name        string `json:"name"`                                       // 组名
+	Description string `json:"description,omitempty" metadata:",optional"` // 描述
+}
+
+// GroupPage 用户组分页查询结果
+type GroupPage struct {
+	Groups   []*Group `json:"groups"`   // 本页用户组
+	Bookmark string   `json:"bookmark"` // 下一页的书签,为空表示没有更多数据
+	Count    int32    `json:"count"`    // 本页用户组数
+}
+
+// validEntityId 判断用户组、组织等的id能否作为复合键的属性:非空的合法 UTF-8,且不含复合键使用的分隔字符
+func validEntityId(id string) bool {
+	return id != "" && utf8.ValidString(id) && !strings.ContainsRune(id, 0) && !strings.ContainsRune(id, utf8.MaxRune)
+}
+
+// checkGroup 校验用户组
+func checkGroup(group Group) error {
+	if !validEntityId(group.Id) {
+		return fmt.Errorf("invalid group id %q", group.Id)
+	}
+	if group.Name == "" {
+		return errors.New("group name is required")
+	}
+	return nil
+}
+
+// getGroup 读取租户内的用户组,不存在时 found 为 false
+func getGroup(stub shim.ChaincodeStubInterface, tenant, id string) (group Group, found bool, err error) {
+	key, err := stub.CreateCompositeKey(groupKey, []string{tenant, id})
+	if err != nil {
+		return group, false, fmt.Errorf("create group key error:%s", err)
+	}
+	groupBytes, err := stub.GetState(key)
+	if err != nil {
+		return group, false, fmt.Errorf("get group state error:%s", err)
+	}
+	if len(groupBytes) == 0 {
+		return group, false, nil
+	}
+	if err := json.Unmarshal(groupBytes, &group); err != nil {
+		return group, false, fmt.Errorf("unmarshal group error:%s", err)
+	}
+	return group, true, nil
+}
+
+// putGroup 写入租户内的用户组
+func putGroup(stub shim.ChaincodeStubInterface, tenant string, group Group) error {
+	key, err := stub.CreateCompositeKey(groupKey, []string{tenant, group.Id})
+	if err != nil {
+		return fmt.Errorf("create group key error:%s", err)
+	}
+	groupBytes, err := json.Marshal(group)
+	if err != nil {
+		return fmt.Errorf("marshal group error:%s", err)
+	}
+	if err := stub.PutState(key, groupBytes); err != nil {
+		return fmt.Errorf("put group state error:%s", err)
+	}
+	return nil
+}
+
+// putMembership 写入成员关系的双向索引
+func putMembership(stub shim.ChaincodeStubInterface, tenant, groupId, userId string) error {
+	for objectType, attributes := range map[string][]string{
+		groupUserIndex: {tenant, groupId, userId},
+		userGroupIndex: {tenant, userId, groupId},
+	} {
+		key, err := stub.CreateCompositeKey(objectType, attributes)
+		if err != nil {
+			return fmt.Errorf("create %s key error:%s", objectType, err)
+		}
+		// 空值等同于删除,因此写入一个空字符
+		if err := stub.PutState(key, []byte{0x00}); err != nil {
+			return fmt.Errorf("put %s error:%s", objectType, err)
+		}
+	}
+	return nil
+}
+
+// delMembership 删除成员关系的双向索引
+func delMembership(stub shim.ChaincodeStubInterface, tenant, groupId, userId string) error {
+	for objectType, attributes := range map[string][]string{
+		groupUserIndex: {tenant, groupId, userId},
+		userGroupIndex: {tenant, userId, groupId},
+	} {
+		key, err := stub.CreateCompositeKey(objectType, attributes)
+		if err != nil {
+			return fmt.Errorf("create %s key error:%s", objectType, err)
+		}
+		if err := stub.DelState(key); err != nil {
+			return fmt.Errorf("del %s error:%s", objectType, err)
+		}
+	}
+	return nil
+}
+
+// indexedIds 读取索引 objectType[租户, id, 关联id] 中与 id 关联的全部id,用于级联删除,不限制数量
+func indexedIds(stub shim.ChaincodeStubInterface, objectType, tenant, id string) ([]string, error) {
+	resultIterator, err := stub.GetStateByPartialCompositeKey(objectType, []string{tenant, id})
+	if err != nil {
+		return nil, fmt.Errorf("get %s by partial composite key error:%s", objectType, err)
+	}
+	ids := make([]string, 0)
+	err = iterate.States(resultIterator, iterate.Unlimited, func(kv *queryresult.KV) error {
+		_, attributes, err := stub.SplitCompositeKey(kv.Key)
+		if err != nil {
+			return fmt.Errorf("split %s key error:%s", objectType, err)
+		}
+		ids = append(ids, attributes[2])
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s iterator error:%s", objectType, err)
+	}
+	return ids, nil
+}
+
+// removeUserMemberships 删除用户时移除其全部成员关系
+func removeUserMemberships(stub shim.ChaincodeStubInterface, tenant, userId string) error {
+	groupIds, err := indexedIds(stub, userGroupIndex, tenant, userId)
+	if err != nil {
+		return err
+	}
+	for _, groupId := range groupIds {
+		if err := delMembership(stub, tenant, groupId, userId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexPage
+// @title		indexPage -> 分页读取索引
+// @description	分页读取索引 objectType[租户, id, 关联id] 中与 id 关联的id,书签必须属于同一前缀,防止借书签读取其他租户或其他组。
+// @auth		lzb
+// @param 		stub		shim库	"包含所有链码API的库"
+// @param		objectType	字符串	"索引的复合键类型"
+// @param		tenant		字符串	"租户"
+// @param		id			字符串	"组id或用户id"
+// @param		pageSize	整型		"每页数量"
+// @param		bookmark	字符串	"上一页返回的书签,第一页为空"
+// @return		ids			字符串组	"本页关联的id"
+// @return		next		字符串	"下一页的书签"
+// @return		err			错误		"查询失败的原因"
+func indexPage(stub shim.ChaincodeStubInterface, objectType, tenant, id string, pageSize int32, bookmark string) (ids []string, next string, err error) {
+	if pageSize <= 0 {
+		return nil, "", fmt.Errorf("page size %d must be positive", pageSize)
+	}
+	prefix, err := stub.CreateCompositeKey(objectType, []string{tenant, id})
+	if err != nil {
+		return nil, "", fmt.Errorf("create %s key error:%s", objectType, err)
+	}
+	if bookmark != "" && !strings.HasPrefix(bookmark, prefix) {
+		return nil, "", fmt.Errorf("bookmark %q is outside the queried range", bookmark)
+	}
+	resultIterator, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination(objectType, []string{tenant, id}, pageSize, bookmark)
+	if err != nil {
+		return nil, "", fmt.Errorf("get %s by partial composite key error:%s", objectType, err)
+	}
+	ids = make([]string, 0)
+	err = iterate.States(resultIterator, int(pageSize), func(kv *queryresult.KV) error {
+		_, attributes, err := stub.SplitCompositeKey(kv.Key)
+		if err != nil {
+			return fmt.Errorf("split %s key error:%s", objectType, err)
+		}
+		ids = append(ids, attributes[2])
+		return nil
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("%s iterator error:%s", objectType, err)
+	}
+	return ids, metadata.GetBookmark(), nil
+}
+
+// CreateGroup
+// @title		CreateGroup -> 创建用户组
+// @description	在本租户中创建用户组,需要本租户的管理员角色。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @param		group	Group		"用户组"
+// @return		err		错误			"创建失败的原因"
+func (e *UserContract) CreateGroup(ctx contractapi.TransactionContextInterface, group Group) error {
+	tenant, err := adminScope(ctx)
+	if err != nil {
+		return fmt.Errorf("create group error:%s", err)
+	}
+	if err := checkGroup(group); err != nil {
+		return fmt.Errorf("create group error:%s", err)
+	}
+	_, found, err := getGroup(ctx.GetStub(), tenant, group.Id)
+	if err != nil {
+		return err
+	}
+	if found {
+		return errors.New("group exist")
+	}
+	return putGroup(ctx.GetStub(), tenant, group)
+}
+
+// QueryGroup
+// @title		QueryGroup -> 查询用户组
+// @description	根据组id查询本租户的用户组。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @param		id		字符串		"组id"
+// @return		group	*Group		"用户组"
+func (e *UserContract) QueryGroup(ctx contractapi.TransactionContextInterface, id string) (*Group, error) {
+	tenant, err := resolveTenant(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	group, found, err := getGroup(ctx.GetStub(), tenant, id)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("group %s does not exist", id)
+	}
+	return &group, nil
+}
+
+// UpdateGroup
+// @title		UpdateGroup -> 修改用户组
+// @description	修改组名与描述,需要本租户的管理员角色;用户组不存在时返回错误。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @param		group	Group		"新的用户组"
+// @return		err		错误			"修改失败的原因"
+func (e *UserContract) UpdateGroup(ctx contractapi.TransactionContextInterface, group Group) error {
+	tenant, err := adminScope(ctx)
+	if err != nil {
+		return fmt.Errorf("update group error:%s", err)
+	}
+	if err := checkGroup(group); err != nil {
+		return fmt.Errorf("update group error:%s", err)
+	}
+	_, found, err := getGroup(ctx.GetStub(), tenant, group.Id)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("group %s does not exist", group.Id)
+	}
+	return putGroup(ctx.GetStub(), tenant, group)
+}
+
+// DeleteGroup
+// @title		DeleteGroup -> 删除用户组
+// @description	删除用户组及其全部成员关系,需要本租户的管理员角色;用户组不存在时不做处理。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @param		id		字符串		"组id"
+// @return		err		错误			"删除失败的原因"
+func (e *UserContract) DeleteGroup(ctx contractapi.TransactionContextInterface, id string) error {
+	stub := ctx.GetStub()
+	tenant, err := adminScope(ctx)
+	if err != nil {
+		return fmt.Errorf("delete group error:%s", err)
+	}
+	userIds, err := indexedIds(stub, groupUserIndex, tenant, id)
+	if err != nil {
+		return err
+	}
+	for _, userId := range userIds {
+		if err := delMembership(stub, tenant, id, userId); err != nil {
+			return err
+		}
+	}
+	key, err := stub.CreateCompositeKey(groupKey, []string{tenant, id})
+	if err != nil {
+		return fmt.Errorf("create group key error:%s", err)
+	}
+	if err := stub.DelState(key); err != nil {
+		return fmt.Errorf("del group error:%s", err)
+	}
+	return nil
+}
+
+// ListGroups
+// @title		ListGroups -> 分页查询用户组
+// @description	按组id分页查询本租户的用户组。
+// @auth		lzb
+// @param 		ctx			交易上下文	"包含所有链码API的库"
+// @param		pageSize	整型			"每页用户组数"
+// @param		bookmark	字符串		"上一页返回的书签,第一页为空"
+// @return		page		*GroupPage	"本页用户组与下一页书签"
+func (e *UserContract) ListGroups(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*GroupPage, error) {
+	stub := ctx.GetStub()
+	tenant, err := resolveTenant(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("page size %d must be positive", pageSize)
+	}
+	prefix, err := stub.CreateCompositeKey(groupKey, []string{tenant})
+	if err != nil {
+		return nil, fmt.Errorf("create group key error:%s", err)
+	}
+	if bookmark != "" && !strings.HasPrefix(bookmark, prefix) {
+		return nil, fmt.Errorf("bookmark %q is outside the queried range", bookmark)
+	}
+	resultIterator, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination(groupKey, []string{tenant}, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("get groups by partial composite key error:%s", err)
+	}
+	groups, err := iterate.Collect[Group](resultIterator, int(pageSize))
+	if err != nil {
+		return nil, fmt.Errorf("list groups error:%s", err)
+	}
+	return &GroupPage{Groups: groups, Bookmark: metadata.GetBookmark(), Count: int32(len(groups))}, nil
+}
+
+// AddMember
+// @title		AddMember -> 添加组成员
+// @description	将本租户的用户加入用户组,同时写入 group~user 与 user~group 索引,需要本租户的管理员角色。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @param		groupId	字符串		"组id"
+// @param		userId	字符串		"用户id"
+// @return		err		错误			"添加失败的原因"
+func (e *UserContract) AddMember(ctx contractapi.TransactionContextInterface, groupId string, userId string) error {
+	stub := ctx.GetStub()
+	tenant, err := adminScope(ctx)
+	if err != nil {
+		return fmt.Errorf("add member error:%s", err)
+	}
+	if _, found, err := getGroup(stub, tenant, groupId); err != nil {
+		return err
+	} else if !found {
+		return fmt.Errorf("group %s does not exist", groupId)
+	}
+	if _, found, err := getUser(stub, tenant, userId); err != nil {
+		return err
+	} else if !found {
+		return fmt.Errorf("user %s does not exist", userId)
+	}
+	return putMembership(stub, tenant, groupId, userId)
+}
+
+// RemoveMember
+// @title		RemoveMember -> 移除组成员
+// @description	将用户移出用户组,同时删除双向索引,需要本租户的管理员角色;不是成员时不做处理。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @param		groupId	字符串		"组id"
+// @param		userId	字符串		"用户id"
+// @return		err		错误			"移除失败的原因"
+func (e *UserContract) RemoveMember(ctx contractapi.TransactionContextInterface, groupId string, userId string) error {
+	tenant, err := adminScope(ctx)
+	if err != nil {
+		return fmt.Errorf("remove member error:%s", err)
+	}
+	return delMembership(ctx.GetStub(), tenant, groupId, userId)
+}
+
+// QueryGroupMembers
+// @title		QueryGroupMembers -> 分页查询组成员
+// @description	按用户id分页查询用户组的成员,成员按读取策略处理,调用者不可见的成员不返回。
+// @auth		lzb
+// @param 		ctx			交易上下文	"包含所有链码API的库"
+// @param		groupId		字符串		"组id"
+// @param		pageSize	整型			"每页成员数"
+// @param		bookmark	字符串		"上一页返回的书签,第一页为空"
+// @return		page		*UserPage	"本页成员与下一页书签"
+func (e *UserContract) QueryGroupMembers(ctx contractapi.TransactionContextInterface, groupId string, pageSize int32, bookmark string) (*UserPage, error) {
+	stub := ctx.GetStub()
+	tenant, v, err := readScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	userIds, next, err := indexPage(stub, groupUserIndex, tenant, groupId, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	encKey, err := getEncryptionKey(stub)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]*UserInfo, 0, len(userIds))
+	for _, userId := range userIds {
+		user, found, err := getUser(stub, tenant, userId)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+		if encKey != nil {
+			if err := decryptUser(encKey, &user); err != nil {
+				return nil, fmt.Errorf("decrypt user error:%s", err)
+			}
+		}
+		if v.redact(tenant, &user) {
+			users = append(users, &user)
+		}
+	}
+	return &UserPage{Users: users, Bookmark: next, Count: int32(len(users))}, nil
+}
+
+// QueryUserGroups
+// @title		QueryUserGroups -> 分页查询用户所在的组
+// @description	按组id分页查询用户所在的用户组;与 QueryOnceUser 一致,用户不存在或按读取策略对调用者不可见时返回用户不存在。
+// @auth		lzb
+// @param 		ctx			交易上下文	"包含所有链码API的库"
+// @param		userId		字符串		"用户id"
+// @param		pageSize	整型			"每页用户组数"
+// @param		bookmark	字符串		"上一页返回的书签,第一页为空"
+// @return		page		*GroupPage	"本页用户组与下一页书签"
+func (e *UserContract) QueryUserGroups(ctx contractapi.TransactionContextInterface, userId string, pageSize int32, bookmark string) (*GroupPage, error) {
+	stub := ctx.GetStub()
+	tenant, v, err := readScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user, found, err := getUser(stub, tenant, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !found || !v.redact(tenant, &user) {
+		return nil, legacy.Thresholdf("user %s does not exist", userId)
+	}
+	groupIds, next, err := indexPage(stub, userGroupIndex, tenant, userId, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]*Group, 0, len(groupIds))
+	for _, groupId := range groupIds {
+		group, found, err := getGroup(stub, tenant, groupId)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			groups = append(groups, &group)
+		}
+	}
+	return &GroupPage{Groups: groups, Bookmark: next, Count: int32(len(groups))}, nil
+}
@@ -0,0 +1,183 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lzb13612/Example-Chaincode/internal/mockledger"
+)
+
+// groupLedger 创建包含用户 1-4 与用户组 dev、ops 的账本,返回本租户管理员
+func groupLedger(t *testing.T) (*mockledger.Ledger, []byte) {
+	t.Helper()
+	ledger := NewLedger(t, "")
+	admin := newCreator(t, "Org1MSP", "admin", map[string]string{roleAttribute: adminRole})
+	for i := 3; i <= 4; i++ {
+		if _, err := invokeAs(ledger, testCreator, "", "addUser", benchUser(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, group := range []string{`{"id":"dev","name":"开发"}`, `{"id":"ops","name":"运维"}`} {
+		if _, err := invokeAs(ledger, admin, "", "createGroup", group); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ledger, admin
+}
+
+// groupIds 解析用户组分页结果中的组id与书签
+func groupIds(t *testing.T, payload []byte) (string, string) {
+	t.Helper()
+	var page GroupPage
+	if err := json.Unmarshal(payload, &page); err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, len(page.Groups))
+	for i, group := range page.Groups {
+		ids[i] = group.Id
+	}
+	return strings.Join(ids, ","), page.Bookmark
+}
+
+func TestGroup_CRUD(t *testing.T) {
+	ledger, admin := groupLedger(t)
+	for _, c := range []struct {
+		identity []byte
+		function string
+		arg      string
+		err      string
+	}{
+		{testCreator, "createGroup", `{"id":"qa","name":"测试"}`, "permission denied"},
+		{admin, "createGroup", `{"id":"dev","name":"x"}`, "group exist"},
+		{admin, "createGroup", `{"id":"","name":"x"}`, "invalid group id"},
+		{admin, "createGroup", `{"id":"qa"}`, "group name is required"},
+		{admin, "updateGroup", `{"id":"qa","name":"测试"}`, "group qa does not exist"},
+		{testCreator, "deleteGroup", `{"id":"dev"}`, "permission denied"},
+	} {
+		if _, err := invokeAs(ledger, c.identity, "", c.function, c.arg); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Fatalf("%s %s: expected %q, got %v", c.function, c.arg, c.err, err)
+		}
+	}
+	if _, err := invokeAs(ledger, admin, "", "updateGroup", `{"id":"dev","name":"研发","description":"后端"}`); err != nil {
+		t.Fatal(err)
+	}
+	payload, err := invokeAs(ledger, testCreator, "", "queryGroup", `{"id":"dev"}`)
+	if err != nil || string(payload) != `{"id":"dev","name":"研发","description":"后端"}` {
+		t.Fatalf("unexpected group %s %v", payload, err)
+	}
+
+	// 分页查询用户组,其他组织看不到本租户的用户组
+	payload, _ = invokeAs(ledger, testCreator, "", "listGroups", "1")
+	if ids, bookmark := groupIds(t, payload); ids != "dev" || bookmark == "" {
+		t.Fatalf("unexpected first page %s", payload)
+	} else if payload, _ = invokeAs(ledger, testCreator, "", "listGroups", "1", bookmark); !strings.Contains(string(payload), `"id":"ops"`) {
+		t.Fatalf("unexpected second page %s", payload)
+	}
+	other := newCreator(t, "Org2MSP", "bob", nil)
+	if payload, _ := invokeAs(ledger, other, "", "listGroups", "10"); !strings.Contains(string(payload), `"count":0`) {
+		t.Fatalf("other tenant listed groups %s", payload)
+	}
+	if _, err := invokeAs(ledger, other, "", "queryGroup", `{"id":"dev"}`); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("expected missing group, got %v", err)
+	}
+}
+
+func TestGroup_Membership(t *testing.T) {
+	ledger, admin := groupLedger(t)
+	for _, member := range [][]string{{"dev", "1"}, {"dev", "2"}, {"dev", "3"}, {"ops", "3"}, {"ops", "4"}} {
+		if _, err := invokeAs(ledger, admin, "", "addMember", member...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, c := range []struct {
+		identity []byte
+		args     []string
+		err      string
+	}{
+		{testCreator, []string{"dev", "4"}, "permission denied"},
+		{admin, []string{"qa", "4"}, "group qa does not exist"},
+		{admin, []string{"dev", "9"}, "user 9 does not exist"},
+	} {
+		if _, err := invokeAs(ledger, c.identity, "", "addMember", c.args...); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Fatalf("%q: expected %q, got %v", c.args, c.err, err)
+		}
+	}
+
+	// 按组分页查询成员,书签不能用于其他组
+	payload, err := invokeAs(ledger, testCreator, "", "queryGroupMembers", "dev", "2")
+	var page UserPage
+	if err != nil || json.Unmarshal(payload, &page) != nil || page.Count != 2 || page.Users[1].Id != "2" || page.Users[1].Name != "lzb2" {
+		t.Fatalf("unexpected members %s %v", payload, err)
+	}
+	if _, err := invokeAs(ledger, testCreator, "", "queryGroupMembers", "ops", "2", page.Bookmark); err == nil || !strings.Contains(err.Error(), "outside the queried range") {
+		t.Fatalf("expected bookmark error, got %v", err)
+	}
+	if payload, _ := invokeAs(ledger, testCreator, "", "queryGroupMembers", "dev", "2", page.Bookmark); !strings.Contains(string(payload), `"count":1`) {
+		t.Fatalf("unexpected second page %s", payload)
+	}
+	payload, _ = invokeAs(ledger, testCreator, "", "queryUserGroups", "3", "10")
+	if ids, _ := groupIds(t, payload); ids != "dev,ops" {
+		t.Fatalf("unexpected user groups %s", payload)
+	}
+
+	// 移除成员、删除用户与删除用户组时双向索引一起清理
+	if _, err := invokeAs(ledger, admin, "", "removeMember", "dev", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := invokeAs(ledger, testCreator, "", "delUser", `{"id":"3"}`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := invokeAs(ledger, admin, "", "deleteGroup", `{"id":"ops"}`); err != nil {
+		t.Fatal(err)
+	}
+	keys := make([]string, 0)
+	for _, kv := range ledger.Range("", "") {
+		if key := mockledger.DecodeKey(kv.Key); strings.HasPrefix(key, groupUserIndex) || strings.HasPrefix(key, userGroupIndex) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) != 2 || !strings.Contains(keys[0], "dev") || !strings.Contains(keys[0], "2") {
+		t.Fatalf("unexpected membership keys %q", keys)
+	}
+}
+
+func TestGroup_MembersRedacted(t *testing.T) {
+	ledger, admin := groupLedger(t)
+	if _, err := invokeAs(ledger, admin, "", "addMember", "dev", "1"); err != nil {
+		t.Fatal(err)
+	}
+	// 其他组织的审计员按默认读取策略只能看到成员的id与用户名
+	auditor := newAuditor(t, ledger)
+	payload, err := invokeAs(ledger, auditor, testTenant, "queryGroupMembers", "dev", "10")
+	if err != nil || !strings.Contains(string(payload), `"id":"1"`) || !strings.Contains(string(payload), `"sex":""`) {
+		t.Fatalf("unexpected redacted members %s %v", payload, err)
+	}
+}
+
+func TestGroup_UserGroupsHidden(t *testing.T) {
+	ledger := NewLedger(t, `{"readPolicy":{"sameTenant":["id","name"],"otherTenant":[]}}`)
+	admin := newCreator(t, "Org1MSP", "admin", map[string]string{roleAttribute: adminRole})
+	if _, err := invokeAs(ledger, admin, "", "createGroup", `{"id":"dev","name":"开发"}`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := invokeAs(ledger, admin, "", "addMember", "dev", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if payload, err := invokeAs(ledger, testCreator, "", "queryUserGroups", "1", "10"); err != nil || !strings.Contains(string(payload), `"id":"dev"`) {
+		t.Fatalf("unexpected user groups %s %v", payload, err)
+	}
+	// 与 queryOnceUser 一致,读取策略下看不到的用户与不存在的用户都查不到所在的组
+	auditor := newAuditor(t, ledger)
+	for _, c := range []struct {
+		identity []byte
+		userId   string
+	}{
+		{auditor, "1"},
+		{testCreator, "9"},
+	} {
+		if _, err := invokeAs(ledger, c.identity, testTenant, "queryUserGroups", c.userId, "10"); err == nil || !strings.Contains(err.Error(), "user "+c.userId+" does not exist") {
+			t.Fatalf("user %s: expected does not exist, got %v", c.userId, err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package chaincode
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/lzb13612/Example-Chaincode/internal/iterate"
+)
+
+// UserHistory 用户的一次历史修改
+type UserHistory struct {
+	TxId      string    `json:"txId"`                                // 修改用户的交易ID
+	Timestamp string    `json:"timestamp"`                           // 交易时间(RFC 3339)
+	IsDelete  bool      `json:"isDelete"`                            // 是否为删除
+	User      *UserInfo `json:"user,omitempty" metadata:",optional"` // 修改后的用户,删除时为空
+}
+
+// QueryUserHistory
+// @title		QueryUserHistory -> 查询用户历史
+// @description	按从新到旧的顺序返回本租户中用户的历史修改,需要节点打开历史数据库;每个版本都按读取策略处理,没有任何可见版本时视为用户不存在。
+// @auth		lzb
+// @param 		ctx			交易上下文		"包含所有链码API的库"
+// @param		id			字符串			"用户id"
+// @return		history		[]*UserHistory	"历史修改"
+func (e *UserContract) QueryUserHistory(ctx contractapi.TransactionContextInterface, id string) ([]*UserHistory, error) {
+	stub := ctx.GetStub()
+	tenant, v, err := readScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key, err := userKey(tenant, id)
+	if err != nil {
+		return nil, fmt.Errorf("create user key error:%s", err)
+	}
+	encKey, err := getEncryptionKey(stub)
+	if err != nil {
+		return nil, err
+	}
+	historyIterator, err := stub.GetHistoryForKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("get history for key error:%s", err)
+	}
+	history := make([]*UserHistory, 0)
+	err = iterate.DecodeHistory(historyIterator, maxQueryResults, func(modification *queryresult.KeyModification, user *UserInfo) error {
+		timestamp, err := formatTimestamp(modification.Timestamp)
+		if err != nil {
+			return fmt.Errorf("timestamp of tx %s error:%s", modification.TxId, err)
+		}
+		entry := &UserHistory{TxId: modification.TxId, Timestamp: timestamp, IsDelete: modification.IsDelete}
+		if user != nil {
+			// 升级前写入的历史版本没有记录加密方案
+			markLegacyEncryption(user)
+			if encKey != nil {
+				if err := decryptUser(encKey, user); err != nil {
+					return fmt.Errorf("decrypt user of tx %s error:%s", modification.TxId, err)
+				}
+			}
+			if !v.redact(tenant, user) {
+				return nil
+			}
+			entry.User = user
+		} else if len(v.tenantFields(tenant)) == 0 {
+			// 删除记录不含用户数据,只有能看到该租户用户的调用者才能看到
+			return nil
+		}
+		history = append(history, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("history iterator error:%s", err)
+	}
+	if len(history) == 0 {
+		return nil, fmt.Errorf("user %s does not exist", id)
+	}
+	return history, nil
+}
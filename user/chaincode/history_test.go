@@ -0,0 +1,54 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestUser_queryUserHistory(t *testing.T) {
+	ledger := NewLedger(t, "")
+	auditor := newAuditor(t, ledger)
+	for _, args := range [][]string{
+		{"addUser", `{"id":"3","name":"three","sex":"男"}`},
+		{"alterUser", `{"id":"3","name":"three","sex":"女"}`},
+		{"delUser", `{"id":"3"}`},
+	} {
+		if _, err := invokeAs(ledger, testCreator, "", args[0], args[1:]...); err != nil {
+			t.Fatalf("%s: %v", args[0], err)
+		}
+	}
+
+	// 从新到旧返回每次修改
+	payload, err := invokeAs(ledger, testCreator, "", "queryUserHistory", `{"id":"3"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var history []UserHistory
+	if err := json.Unmarshal(payload, &history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 || !history[0].IsDelete || history[0].User != nil || history[1].User.Sex != "女" || history[2].User.Sex != "男" {
+		t.Fatalf("unexpected history %s", payload)
+	}
+	for _, entry := range history {
+		if entry.TxId == "" || entry.Timestamp == "" {
+			t.Fatalf("history entry without tx %s", payload)
+		}
+	}
+
+	// 其他组织看到的历史同样只有id与用户名
+	payload, err = invokeAs(ledger, auditor, testTenant, "queryUserHistory", `{"id":"3"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history = nil
+	_ = json.Unmarshal(payload, &history)
+	if len(history) != 3 || history[1].User.Sex != "" || history[1].User.Name != "three" {
+		t.Fatalf("unexpected history for auditor %s", payload)
+	}
+
+	if _, err := invokeAs(ledger, testCreator, "", "queryUserHistory", `{"id":"9"}`); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("expected missing user, got %v", err)
+	}
+}
@@ -0,0 +1,212 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/lzb13612/Example-Chaincode/internal/legacy"
+)
+
+// roleAttribute 证书中表示角色的属性名
+const roleAttribute = "role"
+
+// adminRole 租户管理员角色,可以修改本租户内的任意用户
+const adminRole = "admin"
+
+// auditorRole 跨租户审计角色,可以查询其他租户的用户
+const auditorRole = "auditor"
+
+// creatorKey 用户添加者的复合键类型 -> creator[租户, 用户id],值为添加者的用户id;不写入 UserInfo,查询结果与旧版一致
+const creatorKey = "creator"
+
+// callerUserId
+// @title		callerUserId -> 获取调用者的用户id
+// @description	由调用者证书的 MSP ID 与 subject/issuer 的哈希组成,同一身份总是得到相同的id。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @return		id		字符串		"用户id"
+func callerUserId(ctx contractapi.TransactionContextInterface) (string, error) {
+	return identityUserId(ctx.GetClientIdentity())
+}
+
+// identityUserId 由身份的 MSP ID 与 subject/issuer 的哈希组成用户id
+func identityUserId(client cid.ClientIdentity) (string, error) {
+	mspId, err := client.GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("get msp id error:%s", err)
+	}
+	// GetID 返回 subject 与 issuer 组成的唯一标识
+	identity, err := client.GetID()
+	if err != nil {
+		return "", fmt.Errorf("get client id error:%s", err)
+	}
+	return fmt.Sprintf("%s::%x", mspId, sha256.Sum256([]byte(identity))), nil
+}
+
+// isAuditor 判断调用者是否具有跨租户审计角色,见 hasRole
+func isAuditor(ctx contractapi.TransactionContextInterface) bool {
+	return hasRole(ctx, auditorRole)
+}
+
+// putCreator 记录用户的添加者
+func putCreator(stub shim.ChaincodeStubInterface, tenant, userId, creator string) error {
+	key, err := stub.CreateCompositeKey(creatorKey, []string{tenant, userId})
+	if err != nil {
+		return fmt.Errorf("create creator key error:%s", err)
+	}
+	if err := stub.PutState(key, []byte(creator)); err != nil {
+		return fmt.Errorf("put creator state error:%s", err)
+	}
+	return nil
+}
+
+// getCreator 读取用户的添加者,没有记录时返回空字符串
+func getCreator(stub shim.ChaincodeStubInterface, tenant, userId string) (string, error) {
+	key, err := stub.CreateCompositeKey(creatorKey, []string{tenant, userId})
+	if err != nil {
+		return "", fmt.Errorf("create creator key error:%s", err)
+	}
+	creator, err := stub.GetState(key)
+	if err != nil {
+		return "", fmt.Errorf("get creator state error:%s", err)
+	}
+	return string(creator), nil
+}
+
+// delCreator 删除用户时删除添加者记录
+func delCreator(stub shim.ChaincodeStubInterface, tenant, userId string) error {
+	key, err := stub.CreateCompositeKey(creatorKey, []string{tenant, userId})
+	if err != nil {
+		return fmt.Errorf("create creator key error:%s", err)
+	}
+	if err := stub.DelState(key); err != nil {
+		return fmt.Errorf("del creator state error:%s", err)
+	}
+	return nil
+}
+
+// checkOwner
+// @title		checkOwner -> 校验修改权限
+// @description	与身份绑定的用户只能由本人或该租户的管理员修改,其余用户只能由添加者或该租户的管理员修改;
+// @description	没有记录添加者的旧数据只能由管理员修改。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @param		tenant	字符串		"用户所属租户"
+// @param		user	UserInfo	"被修改的用户"
+// @return		err		错误			"无权限时返回错误"
+func checkOwner(ctx contractapi.TransactionContextInterface, tenant string, user UserInfo) error {
+	if isTenantAdmin(ctx, tenant) {
+		return nil
+	}
+	allowed := user.Owner
+	if allowed == "" {
+		creator, err := getCreator(ctx.GetStub(), tenant, user.Id)
+		if err != nil {
+			return err
+		}
+		allowed = creator
+	}
+	if allowed == "" {
+		return errors.New("permission denied")
+	}
+	callerId, err := callerUserId(ctx)
+	if err != nil {
+		return err
+	}
+	if callerId != allowed {
+		return errors.New("permission denied")
+	}
+	return nil
+}
+
+// RegisterSelf
+// @title		RegisterSelf -> 以调用者身份注册用户
+// @description	用户id由调用者证书派生并与该身份绑定,用户保存在调用者所属的租户。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @param		name	字符串		"用户名"
+// @param		sex		字符串		"用户性别"
+// @return		user	*UserInfo	"注册后的用户"
+func (e *UserContract) RegisterSelf(ctx contractapi.TransactionContextInterface, name string, sex string) (*UserInfo, error) {
+	return registerSelf(ctx, name, sex, "")
+}
+
+// registerSelf 以调用者身份注册用户,orgId 为用户所属的组织,旧版函数可以在注册时指定
+func registerSelf(ctx contractapi.TransactionContextInterface, name, sex, orgId string) (*UserInfo, error) {
+	stub := ctx.GetStub()
+	tenant, err := resolveTenant(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	callerId, err := callerUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+	config, err := getConfig(stub)
+	if err != nil {
+		return nil, err
+	}
+	userInfo := UserInfo{
+		Id:     callerId,
+		Name:   name,
+		Sex:    sex,
+		Owner:  callerId,
+		Status: initialStatus(config),
+		OrgId:  orgId,
+	}
+	if err := checkPlaintext(&userInfo); err != nil {
+		return nil, fmt.Errorf("register self error:%s", err)
+	}
+	if err := checkOrgRef(stub, tenant, config, orgId); err != nil {
+		return nil, err
+	}
+	_, found, err := getUser(stub, tenant, userInfo.Id)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return nil, legacy.Thresholdf("user exist")
+	}
+	registered := userInfo
+	encKey, err := getEncryptionKey(stub)
+	if err != nil {
+		return nil, err
+	}
+	if err := claimName(stub, tenant, encKey, userInfo); err != nil {
+		return nil, err
+	}
+	if encKey != nil {
+		if err := encryptUser(encKey, &userInfo); err != nil {
+			return nil, fmt.Errorf("encrypt user error:%s", err)
+		}
+	}
+	if err := setOrgIndex(stub, tenant, userInfo.Id, "", orgId); err != nil {
+		return nil, err
+	}
+	if err := putUser(stub, tenant, userInfo); err != nil {
+		return nil, err
+	}
+	return &registered, nil
+}
+
+// WhoAmI
+// @title		WhoAmI -> 查询调用者本人
+// @description	返回与调用者证书绑定的用户记录,总是在调用者所属的租户中查询。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @return		user	*UserInfo	"用户信息"
+func (e *UserContract) WhoAmI(ctx contractapi.TransactionContextInterface) (*UserInfo, error) {
+	tenant, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("get msp id error:%s", err)
+	}
+	callerId, err := callerUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return queryOnceUser(ctx.GetStub(), tenant, callerId)
+}
@@ -0,0 +1,132 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/lzb13612/Example-Chaincode/internal/creator"
+)
+
+// newCreator 生成一个带有属性的自签名证书身份,作为 MockStub 的调用者
+func newCreator(t *testing.T, mspId, commonName string, attrs map[string]string) []byte {
+	identity, err := creator.New(mspId, commonName, attrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return identity
+}
+
+func TestUser_registerSelf(t *testing.T) {
+	stub := GetNewStub()
+	stub.Creator = newCreator(t, "Org1MSP", "alice", nil)
+
+	res := stub.MockInvoke("1", [][]byte{[]byte("registerSelf"), []byte(`{"id":"ignored","name":"alice","sex":"女"}`)})
+	if res.Status != shim.OK {
+		t.Fatalf("registerSelf: %s", res.Message)
+	}
+	var registered UserInfo
+	_ = json.Unmarshal(res.Payload, &registered)
+	if registered.Id == "ignored" || registered.Id != registered.Owner {
+		t.Fatalf("id not derived from identity: %+v", registered)
+	}
+
+	res = stub.MockInvoke("2", [][]byte{[]byte("registerSelf"), []byte(`{"name":"alice"}`)})
+	if res.Status == shim.OK {
+		t.Fatal("same identity registered twice")
+	}
+
+	res = stub.MockInvoke("3", [][]byte{[]byte("whoAmI")})
+	var me UserInfo
+	_ = json.Unmarshal(res.Payload, &me)
+	if res.Status != shim.OK || me != registered {
+		t.Fatalf("whoAmI = %+v (%s), want %+v", me, res.Message, registered)
+	}
+
+	stub.Creator = newCreator(t, "Org1MSP", "bob", nil)
+	res = stub.MockInvoke("4", [][]byte{[]byte("whoAmI")})
+	if res.Status == shim.OK {
+		t.Fatalf("whoAmI for unregistered identity returned %s", res.Payload)
+	}
+}
+
+func TestUser_ownerOnlyEdit(t *testing.T) {
+	stub := GetNewStub()
+	alice := newCreator(t, "Org1MSP", "alice", nil)
+	stub.Creator = alice
+	res := stub.MockInvoke("1", [][]byte{[]byte("registerSelf"), []byte(`{"name":"alice","sex":"女"}`)})
+	var registered UserInfo
+	_ = json.Unmarshal(res.Payload, &registered)
+
+	altered := registered
+	altered.Name = "mallory"
+	alteredByte, _ := json.Marshal(altered)
+
+	stub.Creator = newCreator(t, "Org1MSP", "mallory", nil)
+	if res := stub.MockInvoke("2", [][]byte{[]byte("alterUser"), alteredByte}); res.Status == shim.OK {
+		t.Fatal("other identity altered the user")
+	}
+	if res := stub.MockInvoke("3", [][]byte{[]byte("delUser"), alteredByte}); res.Status == shim.OK {
+		t.Fatal("other identity deleted the user")
+	}
+
+	stub.Creator = alice
+	if res := stub.MockInvoke("4", [][]byte{[]byte("alterUser"), alteredByte}); res.Status != shim.OK {
+		t.Fatalf("owner alterUser: %s", res.Message)
+	}
+
+	stub.Creator = newCreator(t, "Org1MSP", "admin", map[string]string{roleAttribute: adminRole})
+	if res := stub.MockInvoke("5", [][]byte{[]byte("delUser"), alteredByte}); res.Status != shim.OK {
+		t.Fatalf("admin delUser: %s", res.Message)
+	}
+}
+
+func TestUser_creatorOnlyEdit(t *testing.T) {
+	stub := GetNewStub()
+	alice := newCreator(t, "Org1MSP", "alice", nil)
+	stub.Creator = alice
+	if res := stub.MockInvoke("1", [][]byte{[]byte("addUser"), user1}); res.Status != shim.OK {
+		t.Fatalf("addUser: %s", res.Message)
+	}
+	altered, _ := json.Marshal(UserInfo{Id: id1, Name: "mallory", Sex: sex1})
+
+	stub.Creator = newCreator(t, "Org1MSP", "mallory", nil)
+	if res := stub.MockInvoke("2", [][]byte{[]byte("alterUser"), altered}); res.Status == shim.OK {
+		t.Fatal("other identity altered the user")
+	}
+	if res := stub.MockInvoke("3", [][]byte{[]byte("delUser"), user1}); res.Status == shim.OK {
+		t.Fatal("other identity deleted the user")
+	}
+
+	stub.Creator = alice
+	if res := stub.MockInvoke("4", [][]byte{[]byte("alterUser"), altered}); res.Status != shim.OK {
+		t.Fatalf("creator alterUser: %s", res.Message)
+	}
+
+	// 没有记录添加者的旧数据只能由管理员修改
+	stub.MockTransactionStart("legacy")
+	_ = delCreator(stub, testTenant, id1)
+	stub.MockTransactionEnd("legacy")
+	if res := stub.MockInvoke("5", [][]byte{[]byte("alterUser"), user1}); res.Status == shim.OK {
+		t.Fatal("non-admin altered a user without creator")
+	}
+	stub.Creator = newCreator(t, "Org1MSP", "admin", map[string]string{roleAttribute: adminRole})
+	if res := stub.MockInvoke("6", [][]byte{[]byte("delUser"), user1}); res.Status != shim.OK {
+		t.Fatalf("admin delUser: %s", res.Message)
+	}
+	if creator, _ := getCreator(stub, testTenant, id1); creator != "" {
+		t.Fatalf("creator %s left after delete", creator)
+	}
+}
+
+func TestUser_addUserRejectsBoundIdentity(t *testing.T) {
+	stub := GetNewStub()
+	res := stub.MockInvoke("1", [][]byte{[]byte("addUser"), []byte(`{"id":"Org1MSP::abc","name":"x"}`)})
+	if res.Status == shim.OK {
+		t.Fatal("addUser accepted an identity derived id")
+	}
+	res = stub.MockInvoke("2", [][]byte{[]byte("addUser"), []byte(`{"id":"9","name":"x","owner":"someone"}`)})
+	if res.Status == shim.OK {
+		t.Fatal("addUser accepted an owner")
+	}
+}
@@ -0,0 +1,176 @@
+package chaincode
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// 链码分配用户id的方式,通过 ChaincodeConfig.IdGenerator 设置,为空时由客户端提供id
+const (
+	IdGeneratorTxID    = "txid"    // 使用交易ID作为用户id,不读写任何共享状态
+	IdGeneratorCounter = "counter" // 使用分片计数器生成数值id
+)
+
+const (
+	idCounterKey    = "idseq" // 计数器的复合键类型 -> idseq[租户, 分片]
+	defaultIdShards = 8       // 未配置分片数时使用的分片数
+	maxIdShards     = 1024    // 分片数上限
+	maxIdAttempts   = 100     // 逐个跳过已被占用的id的最大次数
+	maxIdJumps      = 40      // 逐个跳过仍未找到空闲id后,按倍增步长跳过的最大次数
+)
+
+// validateIdGenerator 校验用户id生成配置
+func validateIdGenerator(config ChaincodeConfig) error {
+	switch config.IdGenerator {
+	case "", IdGeneratorTxID, IdGeneratorCounter:
+	default:
+		return fmt.Errorf("unknown id generator %s", config.IdGenerator)
+	}
+	if config.IdShards < 0 || config.IdShards > maxIdShards {
+		return fmt.Errorf("id shards must be between 0 and %d", maxIdShards)
+	}
+	return nil
+}
+
+// counterShards 计数器方式实际使用的分片数
+func counterShards(config ChaincodeConfig) int {
+	if config.IdShards == 0 {
+		return defaultIdShards
+	}
+	return config.IdShards
+}
+
+// getIdShards 读取已固定的计数器分片数,0 表示从未配置过计数器方式
+func getIdShards(stub shim.ChaincodeStubInterface) (int, error) {
+	key, err := stub.CreateCompositeKey("meta", []string{"idShards"})
+	if err != nil {
+		return 0, fmt.Errorf("create id shards key error:%s", err)
+	}
+	shardsBytes, err := stub.GetState(key)
+	if err != nil {
+		return 0, fmt.Errorf("get id shards state error:%s", err)
+	}
+	if len(shardsBytes) != 0 {
+		shards, err := strconv.Atoi(string(shardsBytes))
+		if err != nil {
+			return 0, fmt.Errorf("parse id shards error:%s", err)
+		}
+		return shards, nil
+	}
+	// 固定分片数之前已配置计数器方式的部署,以当前配置的分片数为准
+	config, err := getConfig(stub)
+	if err != nil {
+		return 0, err
+	}
+	if config.IdGenerator == IdGeneratorCounter {
+		return counterShards(config), nil
+	}
+	return 0, nil
+}
+
+// fixIdShards
+// @title		fixIdShards -> 固定计数器分片数
+// @description	分片 s 生成 s+1, s+1+n ...,分片数 n 变化后新的序列会与已分配的id重叠,已删除用户的id会被再次分配;
+// @description	因此首次配置计数器方式时记录分片数,之后的配置(包括切换到其他方式后再切换回来)不能改变分片数。
+// @auth		lzb
+// @param 		stub	shim库			"包含所有链码API的库"
+// @param		config	ChaincodeConfig	"新的链码配置"
+// @return		err		错误				"分片数与已固定的不同"
+func fixIdShards(stub shim.ChaincodeStubInterface, config ChaincodeConfig) error {
+	if config.IdGenerator != IdGeneratorCounter {
+		return nil
+	}
+	shards := counterShards(config)
+	fixed, err := getIdShards(stub)
+	if err != nil {
+		return err
+	}
+	if fixed != 0 {
+		if fixed != shards {
+			return fmt.Errorf("id shards are fixed at %d once the counter generator is configured", fixed)
+		}
+		return nil
+	}
+	key, err := stub.CreateCompositeKey("meta", []string{"idShards"})
+	if err != nil {
+		return fmt.Errorf("create id shards key error:%s", err)
+	}
+	if err := stub.PutState(key, []byte(strconv.Itoa(shards))); err != nil {
+		return fmt.Errorf("put id shards state error:%s", err)
+	}
+	return nil
+}
+
+// assignId
+// @title		assignId -> 分配用户id
+// @description	按链码配置为新用户分配id,所有背书节点对同一交易得到相同的结果。
+// @auth		lzb
+// @param 		stub	shim库			"包含所有链码API的库"
+// @param		tenant	字符串			"租户"
+// @param		config	ChaincodeConfig	"链码配置"
+// @return		id		字符串			"分配的用户id"
+// @return		err		错误				"分配失败的原因"
+func assignId(stub shim.ChaincodeStubInterface, tenant string, config ChaincodeConfig) (string, error) {
+	switch config.IdGenerator {
+	case IdGeneratorTxID:
+		return stub.GetTxID(), nil
+	case IdGeneratorCounter:
+		return nextCounterId(stub, tenant, counterShards(config))
+	default:
+		return "", errors.New("id generator is not configured")
+	}
+}
+
+// nextCounterId
+// @title		nextCounterId -> 分片计数器生成id
+// @description	每个租户有独立的计数器。按交易ID的哈希选择分片,分片 s 依次生成 s+1, s+1+n, s+1+2n ...(n 为分片数,由 fixIdShards 固定)。只有落在同一分片的并发注册会产生 MVCC 冲突;id 已被客户端提供的id占用时跳过。
+// @description	配置计数器方式前客户端可能提供了大量连续的数值id,逐个跳过 maxIdAttempts 次仍未找到空闲id时按倍增的步长继续向后查找,跳过的id不再分配。
+// @description	出错的交易不会写入任何状态,只保存推进后的计数器再返回错误并不能让分片前进,因此必须在本交易内找到空闲id。
+// @auth		lzb
+// @param 		stub	shim库	"包含所有链码API的库"
+// @param		tenant	字符串	"租户"
+// @param		shards	整型		"分片数"
+// @return		id		字符串	"生成的用户id"
+// @return		err		错误		"生成失败的原因"
+func nextCounterId(stub shim.ChaincodeStubInterface, tenant string, shards int) (string, error) {
+	hash := fnv.New32a()
+	hash.Write([]byte(stub.GetTxID()))
+	shard := uint64(hash.Sum32() % uint32(shards))
+	key, err := stub.CreateCompositeKey(idCounterKey, []string{tenant, strconv.FormatUint(shard, 10)})
+	if err != nil {
+		return "", fmt.Errorf("create id counter key error:%s", err)
+	}
+	countBytes, err := stub.GetState(key)
+	if err != nil {
+		return "", fmt.Errorf("get id counter state error:%s", err)
+	}
+	var count uint64
+	if len(countBytes) != 0 {
+		if count, err = strconv.ParseUint(string(countBytes), 10, 64); err != nil {
+			return "", fmt.Errorf("parse id counter error:%s", err)
+		}
+	}
+	step := uint64(1)
+	for attempt := 0; attempt < maxIdAttempts+maxIdJumps; attempt++ {
+		id := strconv.FormatUint(count*uint64(shards)+shard+1, 10)
+		_, found, err := getUser(stub, tenant, id)
+		if err != nil {
+			return "", err
+		}
+		if !found {
+			if err := stub.PutState(key, []byte(strconv.FormatUint(count+1, 10))); err != nil {
+				return "", fmt.Errorf("put id counter state error:%s", err)
+			}
+			return id, nil
+		}
+		if attempt >= maxIdAttempts-1 {
+			step *= 2
+		}
+		count += step
+	}
+	return "", fmt.Errorf("no free user id in shard %d after %d attempts", shard, maxIdAttempts+maxIdJumps)
+}
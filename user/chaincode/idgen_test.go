@@ -0,0 +1,203 @@
+package chaincode
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/lzb13612/Example-Chaincode/internal/mockledger"
+)
+
+// addedId 解析 addUser 返回的用户id
+func addedId(t *testing.T, res pb.Response) string {
+	t.Helper()
+	if res.Status != shim.OK {
+		t.Fatal(res.Message)
+	}
+	var added UserId
+	if err := json.Unmarshal(res.Payload, &added); err != nil {
+		t.Fatal(err)
+	}
+	return added.Id
+}
+
+// queryName 按id查询用户名
+func queryName(t *testing.T, ledger *mockledger.Ledger, id string) string {
+	t.Helper()
+	query, _ := json.Marshal(UserInfo{Id: id})
+	res := ledger.Invoke("queryOnceUser", []string{string(query)}, nil)
+	var user UserInfo
+	if res.Status != shim.OK || json.Unmarshal(res.Payload, &user) != nil {
+		t.Fatalf("query user %s: %s %s", id, res.Payload, res.Message)
+	}
+	return user.Name
+}
+
+func TestIdGenerator_Config(t *testing.T) {
+	for _, config := range []string{`{"idGenerator":"uuid"}`, `{"idGenerator":"counter","idShards":-1}`, `{"idShards":2048}`} {
+		stub := NewStub("ex01")
+		if res := stub.MockInit("1", [][]byte{[]byte("init"), []byte(config)}); res.Status == shim.OK || !strings.Contains(res.Message, "config error") {
+			t.Fatalf("expected config %s to be rejected, got %d %s", config, res.Status, res.Message)
+		}
+	}
+}
+
+func TestIdGenerator_ClientId(t *testing.T) {
+	ledger := NewLedger(t, "")
+	if id := addedId(t, ledger.Invoke("addUser", []string{`{"id":"3","name":"lzb3"}`}, nil)); id != "3" {
+		t.Fatalf("expected client id 3, got %s", id)
+	}
+	if res := ledger.Invoke("addUser", []string{`{"name":"lzb4"}`}, nil); res.Status == shim.OK || res.Message != "user id is required" {
+		t.Fatalf("expected missing id error, got %d %s", res.Status, res.Message)
+	}
+}
+
+func TestIdGenerator_TxID(t *testing.T) {
+	ledger := NewLedger(t, `{"idGenerator":"txid"}`)
+	tx := ledger.Simulate(mockledger.Proposal{Function: "addUser", Args: []string{`{"name":"lzb3"}`}})
+	id := addedId(t, tx.Response)
+	if id != tx.ID {
+		t.Fatalf("expected tx id %s, got %s", tx.ID, id)
+	}
+	expectCodes(t, ledger, []*mockledger.Transaction{tx}, pb.TxValidationCode_VALID)
+	if name := queryName(t, ledger, id); name != "lzb3" {
+		t.Fatalf("expected lzb3, got %s", name)
+	}
+	// 链码分配id时客户端不能指定id
+	if res := ledger.Invoke("addUser", []string{`{"id":"9","name":"lzb9"}`}, nil); res.Status == shim.OK || res.Message != "user id is assigned by the chaincode" {
+		t.Fatalf("expected client id to be rejected, got %d %s", res.Status, res.Message)
+	}
+}
+
+func TestIdGenerator_Counter(t *testing.T) {
+	ledger := NewLedger(t, `{"idGenerator":"counter","idShards":1}`)
+	// 单个分片时依次生成 1,2,3...,已存在的种子用户 1、2 被跳过
+	for i, expected := range []string{"3", "4", "5"} {
+		id := addedId(t, ledger.Invoke("addUser", []string{`{"name":"user"}`}, nil))
+		if id != expected {
+			t.Fatalf("user %d: expected id %s, got %s", i, expected, id)
+		}
+	}
+	// 客户端无法占用后续id,删除用户后id不会被重新分配
+	if res := ledger.Invoke("delUser", []string{`{"id":"5"}`}, nil); res.Status != shim.OK {
+		t.Fatal(res.Message)
+	}
+	if id := addedId(t, ledger.Invoke("addUser", []string{`{"name":"user"}`}, nil)); id != "6" {
+		t.Fatalf("expected id 6, got %s", id)
+	}
+}
+
+func TestIdGenerator_CounterShards(t *testing.T) {
+	const shards = 4
+	ledger := NewLedger(t, `{"idGenerator":"counter","idShards":4}`)
+	args := make([]string, 8)
+	for i := range args {
+		args[i] = `{"name":"user"}`
+	}
+	txs := endorse(t, ledger, "addUser", args...)
+	codes, err := ledger.CommitBlock(txs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 同一分片内只有第一笔交易有效,不同分片的交易互不冲突
+	committed := make(map[uint32]bool)
+	ids := make(map[string]bool)
+	for i, tx := range txs {
+		hash := fnv.New32a()
+		hash.Write([]byte(tx.ID))
+		shard := hash.Sum32() % shards
+		expected := pb.TxValidationCode_VALID
+		if committed[shard] {
+			expected = pb.TxValidationCode_MVCC_READ_CONFLICT
+		}
+		if codes[i] != expected {
+			t.Fatalf("tx %s in shard %d: expected %v, got %v", tx.ID, shard, expected, codes[i])
+		}
+		if expected != pb.TxValidationCode_VALID {
+			continue
+		}
+		committed[shard] = true
+		id := addedId(t, tx.Response)
+		if ids[id] {
+			t.Fatalf("id %s assigned twice", id)
+		}
+		ids[id] = true
+		if name := queryName(t, ledger, id); name != "user" {
+			t.Fatalf("expected user %s to be committed, got %s", id, name)
+		}
+	}
+	if len(committed) < 2 {
+		t.Fatalf("expected transactions to spread over shards, got %v", committed)
+	}
+}
+
+func TestIdGenerator_ShardsFixed(t *testing.T) {
+	ledger := NewLedger(t, `{"idGenerator":"counter","idShards":4}`)
+	admin := newCreator(t, "Org1MSP", "admin", map[string]string{roleAttribute: adminRole})
+	first := addedId(t, ledger.Invoke("addUser", []string{`{"name":"first"}`}, nil))
+	if _, err := invokeAs(ledger, admin, "", "delUser", `{"id":"`+first+`"}`); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		config string
+		err    string
+	}{
+		{`{"idGenerator":"counter","idShards":8}`, "fixed at 4"},
+		{`{"idGenerator":"counter","idShards":4,"uniqueNames":true}`, ""},
+		// 切换到其他方式后再切换回来也不能改变分片数
+		{`{"idGenerator":"txid"}`, ""},
+		{`{"idGenerator":"counter"}`, "fixed at 4"},
+		{`{"idGenerator":"counter","idShards":4}`, ""},
+	} {
+		_, err := invokeAs(ledger, admin, "", "setConfig", c.config)
+		if c.err == "" && err != nil || c.err != "" && (err == nil || !strings.Contains(err.Error(), "config error") || !strings.Contains(err.Error(), c.err)) {
+			t.Fatalf("setConfig %s: expected %q, got %v", c.config, c.err, err)
+		}
+	}
+	// 分片数不变,已删除用户的id不会被再次分配
+	for i := 0; i < 16; i++ {
+		if id := addedId(t, ledger.Invoke("addUser", []string{`{"name":"user"}`}, nil)); id == first {
+			t.Fatalf("id %s of a deleted user reissued", id)
+		}
+	}
+}
+
+func TestIdGenerator_SignedRegistration(t *testing.T) {
+	ledger := NewLedger(t, `{"idGenerator":"counter"}`)
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	// 签名针对不含id的用户信息
+	userByte, registrationByte := signedRegistration(t, key, UserInfo{Name: name1, Sex: sex1}, "nonce-1")
+	id := addedId(t, ledger.Invoke("addUser", []string{string(userByte), string(registrationByte)}, nil))
+	if name := queryName(t, ledger, id); name != name1 {
+		t.Fatalf("expected %s, got %s", name1, name)
+	}
+}
+
+func TestIdGenerator_CounterSkipsTakenRange(t *testing.T) {
+	// 配置计数器方式前客户端提供了超过 maxIdAttempts 个连续的数值id
+	ledger := NewLedger(t, `{"admin":"CN=root,O=Org1MSP"}`)
+	root := newCreator(t, "Org1MSP", "root", nil)
+	taken := maxIdAttempts + 50
+	for i := len(seedUsers) + 1; i <= taken; i++ {
+		if _, err := invokeAs(ledger, testCreator, "", "addUser", benchUser(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := invokeAs(ledger, root, "", "setConfig", `{"idGenerator":"counter","idShards":1}`); err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		id := addedId(t, ledger.Invoke("addUser", []string{`{"name":"user"}`}, nil))
+		if n, err := strconv.Atoi(id); err != nil || n <= taken || seen[id] {
+			t.Fatalf("assigned id %s, want a new id above %d", id, taken)
+		}
+		seen[id] = true
+	}
+}
@@ -0,0 +1,219 @@
+package chaincode
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/lzb13612/Example-Chaincode/internal/iterate"
+)
+
+// 用户名索引的复合键类型
+const (
+	nameIndex       = "tenant~name~id" // 租户内的用户名索引
+	legacyNameIndex = "name~id"        // 版本 4 之前不区分租户的用户名索引
+)
+
+func init() {
+	registerMigration(1, "build name index", buildNameIndex)
+}
+
+// nameIndexValue
+// @title		nameIndexValue -> 计算索引中的用户名
+// @description	明文用户直接使用用户名;加密用户使用用户名的 HMAC,避免在索引中泄露明文。当前方案的 HMAC 使用 HKDF 派生的索引子密钥,
+// @description	旧版方案的用户沿用升级前直接以 transient 密钥计算的值。
+// @auth		lzb
+// @param		encKey	字符组	"加密密钥,明文用户可为 nil"
+// @param		scheme	整型		"用户记录的加密方案"
+// @param		name	字符串	"用户名明文"
+// @return		value	字符串	"索引中的用户名"
+func nameIndexValue(encKey []byte, scheme int, name string) string {
+	macKey := encKey
+	switch scheme {
+	case encryptionNone:
+		return name
+	case encryptionHKDF:
+		macKey = deriveKey(encKey, nameIndexKeyLabel, sha256.Size)
+	}
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write([]byte(name))
+	return "hmac:" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// idsByName 通过租户内的用户名索引查询用户id,同名用户超过 limit 个时返回 *iterate.LimitError
+func idsByName(stub shim.ChaincodeStubInterface, tenant, indexName string, limit int) ([]string, error) {
+	resultIterator, err := stub.GetStateByPartialCompositeKey(nameIndex, []string{tenant, indexName})
+	if err != nil {
+		return nil, fmt.Errorf("get name index error:%s", err)
+	}
+	ids := make([]string, 0)
+	err = iterate.States(resultIterator, limit, func(item *queryresult.KV) error {
+		_, attributes, err := stub.SplitCompositeKey(item.Key)
+		if err != nil {
+			return fmt.Errorf("split name index key error:%s", err)
+		}
+		ids = append(ids, attributes[2])
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("name index iterator error:%s", err)
+	}
+	return ids, nil
+}
+
+// putNameIndex 写入租户内的用户名索引
+func putNameIndex(stub shim.ChaincodeStubInterface, tenant, indexName, id string) error {
+	key, err := stub.CreateCompositeKey(nameIndex, []string{tenant, indexName, id})
+	if err != nil {
+		return fmt.Errorf("create name index key error:%s", err)
+	}
+	// 空值等同于删除,因此写入一个空字符
+	if err := stub.PutState(key, []byte{0x00}); err != nil {
+		return fmt.Errorf("put name index error:%s", err)
+	}
+	return nil
+}
+
+// claimName
+// @title		claimName -> 占用用户名
+// @description	按写入记录时的加密方案写入用户名索引;链码配置开启唯一约束时,用户名已被同一租户的其他用户占用则返回错误。
+// @auth		lzb
+// @param 		stub	shim库		"包含所有链码API的库"
+// @param		tenant	字符串		"租户"
+// @param		encKey	字符组		"加密密钥,可为 nil"
+// @param		user	UserInfo	"明文用户"
+// @return		err		错误			"重名等错误"
+func claimName(stub shim.ChaincodeStubInterface, tenant string, encKey []byte, user UserInfo) error {
+	if user.Name == "" {
+		return nil
+	}
+	indexName := nameIndexValue(encKey, writeScheme(encKey), user.Name)
+	config, err := getConfig(stub)
+	if err != nil {
+		return err
+	}
+	if config.UniqueNames {
+		// 用户名唯一时索引中最多有用户本人与另一个重名用户
+		ids, err := idsByName(stub, tenant, indexName, 2)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if id != user.Id {
+				return fmt.Errorf("name %s already used by user %s", user.Name, id)
+			}
+		}
+	}
+	return putNameIndex(stub, tenant, indexName, user.Id)
+}
+
+// releaseName 删除租户内的用户名索引,scheme 为账本中原记录的加密方案,user 为解密后的原记录
+func releaseName(stub shim.ChaincodeStubInterface, tenant string, encKey []byte, scheme int, user UserInfo) error {
+	if user.Name == "" {
+		return nil
+	}
+	key, err := stub.CreateCompositeKey(nameIndex, []string{tenant, nameIndexValue(encKey, scheme, user.Name), user.Id})
+	if err != nil {
+		return fmt.Errorf("create name index key error:%s", err)
+	}
+	if err := stub.DelState(key); err != nil {
+		return fmt.Errorf("del name index error:%s", err)
+	}
+	return nil
+}
+
+// buildNameIndex 迁移步骤 -> 为已有用户(此时仍以复合键保存)在执行升级的组织的租户下建立用户名索引,加密的用户名因缺少密钥而跳过
+func buildNameIndex(stub shim.ChaincodeStubInterface) error {
+	tenant, err := stubTenant(stub)
+	if err != nil {
+		return err
+	}
+	resultIterator, err := stub.GetStateByPartialCompositeKey(legacyUserKey, []string{})
+	if err != nil {
+		return fmt.Errorf("get user info by partial composite key error:%s", err)
+	}
+	return iterate.Decode(resultIterator, iterate.Unlimited, func(key string, userInfo *UserInfo) error {
+		if userInfo.Name == "" || strings.HasPrefix(userInfo.Name, encryptedPrefix) {
+			return nil
+		}
+		return putNameIndex(stub, tenant, userInfo.Name, userInfo.Id)
+	})
+}
+
+// QueryUserByName
+// @title		QueryUserByName -> 按用户名查询用户
+// @description	通过用户名索引查询本租户的用户,提供加密密钥时按密文索引查询并解密返回;调用者看不到用户名的用户不参与搜索。
+// @auth		lzb
+// @param 		ctx		交易上下文		"包含所有链码API的库"
+// @param		name	字符串			"用户名"
+// @return		users	[]*UserInfo		"用户列表"
+func (e *UserContract) QueryUserByName(ctx contractapi.TransactionContextInterface, name string) ([]*UserInfo, error) {
+	tenant, v, err := readScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	userInfos := make([]*UserInfo, 0)
+	err = eachUserByName(ctx.GetStub(), tenant, v, name, func(user *UserInfo) error {
+		userInfos = append(userInfos, user)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return userInfos, nil
+}
+
+// streamUsersByName 与 QueryUserByName 返回相同的 JSON,用户列表逐条写入
+func streamUsersByName(stub shim.ChaincodeStubInterface, tenant string, v *viewer, name string) ([]byte, error) {
+	enc := newUserListEncoder("")
+	if err := eachUserByName(stub, tenant, v, name, enc.writeUser); err != nil {
+		return nil, err
+	}
+	return enc.bytes(""), nil
+}
+
+// eachUserByName 依次处理用户名索引指向的用户,提供加密密钥时先解密,再按读取策略处理;跳过调用者看不到用户名的用户,避免借搜索确认用户名
+func eachUserByName(stub shim.ChaincodeStubInterface, tenant string, v *viewer, name string, visit func(user *UserInfo) error) error {
+	encKey, err := getEncryptionKey(stub)
+	if err != nil {
+		return err
+	}
+	ids, err := idsByName(stub, tenant, nameIndexValue(encKey, writeScheme(encKey), name), maxQueryResults)
+	if err != nil {
+		return err
+	}
+	if encKey != nil {
+		// 旧版方案加密的用户仍以升级前的 HMAC 索引,一并查询
+		legacyIds, err := idsByName(stub, tenant, nameIndexValue(encKey, encryptionLegacy, name), maxQueryResults)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, legacyIds...)
+	}
+	for _, id := range ids {
+		user, found, err := getUser(stub, tenant, id)
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+		if encKey != nil {
+			if err := decryptUser(encKey, &user); err != nil {
+				return fmt.Errorf("decrypt user error:%s", err)
+			}
+		}
+		if !v.redact(tenant, &user) || user.Name == "" {
+			continue
+		}
+		if err := visit(&user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+)
+
+// queryByName 按用户名查询并返回用户列表
+func queryByName(t *testing.T, stub *shimtest.MockStub, txId, name string) []UserInfoTest {
+	nameByte, _ := json.Marshal(UserInfoTest{Name: name})
+	res := stub.MockInvoke(txId, [][]byte{[]byte("queryUserByName"), nameByte})
+	if res.Status != shim.OK {
+		t.Fatalf("queryUserByName: %s", res.Message)
+	}
+	var users []UserInfoTest
+	_ = json.Unmarshal(res.Payload, &users)
+	return users
+}
+
+func TestUser_queryUserByName(t *testing.T) {
+	stub := GetNewStub()
+	if users := queryByName(t, stub, "1", name_1); len(users) != 1 || users[0] != userInfoTest_1 {
+		t.Fatalf("seed user not indexed: %+v", users)
+	}
+
+	stub.MockInvoke("2", [][]byte{[]byte("addUser"), user1})
+	newUserInfo, _ := json.Marshal(UserInfoTest{Id: id1, Name: "renamed", Sex: sex1})
+	res := stub.MockInvoke("3", [][]byte{[]byte("alterUser"), newUserInfo})
+	if res.Status != shim.OK {
+		t.Fatalf("alterUser: %s", res.Message)
+	}
+	if users := queryByName(t, stub, "4", name1); len(users) != 0 {
+		t.Fatalf("old name still indexed: %+v", users)
+	}
+	if users := queryByName(t, stub, "5", "renamed"); len(users) != 1 || users[0].Id != id1 {
+		t.Fatalf("new name not indexed: %+v", users)
+	}
+
+	stub.MockInvoke("6", [][]byte{[]byte("delUser"), newUserInfo})
+	if users := queryByName(t, stub, "7", "renamed"); len(users) != 0 {
+		t.Fatalf("deleted user still indexed: %+v", users)
+	}
+}
+
+func TestUser_uniqueNames(t *testing.T) {
+	stub := NewStub("ex01")
+	stub.MockInit("init", [][]byte{[]byte("init"), []byte(testConfig(t, `{"uniqueNames":true}`))})
+
+	duplicate, _ := json.Marshal(UserInfoTest{Id: id1, Name: name_1, Sex: sex1})
+	if res := stub.MockInvoke("1", [][]byte{[]byte("addUser"), duplicate}); res.Status == shim.OK {
+		t.Fatal("duplicate name accepted")
+	}
+	if res := stub.MockInvoke("2", [][]byte{[]byte("addUser"), user1}); res.Status != shim.OK {
+		t.Fatalf("addUser: %s", res.Message)
+	}
+	renamed, _ := json.Marshal(UserInfoTest{Id: id1, Name: name_2, Sex: sex1})
+	if res := stub.MockInvoke("3", [][]byte{[]byte("alterUser"), renamed}); res.Status == shim.OK {
+		t.Fatal("rename to a used name accepted")
+	}
+	// 释放的用户名可以被再次使用
+	renamed, _ = json.Marshal(UserInfoTest{Id: id_1, Name: "free", Sex: sex_1})
+	stub.MockInvoke("4", [][]byte{[]byte("alterUser"), renamed})
+	if res := stub.MockInvoke("5", [][]byte{[]byte("alterUser"), duplicate}); res.Status != shim.OK {
+		t.Fatalf("rename to a released name: %s", res.Message)
+	}
+	// 保持原名修改其他字段不受唯一约束影响
+	sameName, _ := json.Marshal(UserInfoTest{Id: id1, Name: name_1, Sex: sex2})
+	if res := stub.MockInvoke("6", [][]byte{[]byte("alterUser"), sameName}); res.Status != shim.OK {
+		t.Fatalf("alterUser keeping name: %s", res.Message)
+	}
+}
+
+func TestUser_nameIndexEncrypted(t *testing.T) {
+	stub := GetNewStub()
+	stub.TransientMap = map[string][]byte{transientKeyName: testEncryptionKey}
+	stub.MockInvoke("1", [][]byte{[]byte("addUser"), user1})
+
+	if users := queryByName(t, stub, "2", name1); len(users) != 1 || users[0] != userInfoTest1 {
+		t.Fatalf("encrypted user not found by name: %+v", users)
+	}
+	stub.TransientMap = nil
+	if users := queryByName(t, stub, "3", name1); len(users) != 0 {
+		t.Fatalf("plaintext name leaked into index: %+v", users)
+	}
+}
+
+func TestBuildNameIndex(t *testing.T) {
+	stub := NewStub("legacy")
+	stub.MockTransactionStart("legacy")
+	putLegacyUser(t, stub, UserInfo{Id: id1, Name: name1, Sex: sex1})
+	stub.MockTransactionEnd("legacy")
+	stub.MockInit("init", [][]byte{[]byte("init")})
+
+	if users := queryByName(t, stub, "1", name1); len(users) != 1 || users[0] != userInfoTest1 {
+		t.Fatalf("legacy user not indexed by migration: %+v", users)
+	}
+}
+
+// nameIndexIds 返回测试租户的用户名索引中 indexName 对应的用户id
+func nameIndexIds(t *testing.T, stub *shimtest.MockStub, indexName string) []string {
+	stub.MockTransactionStart("nameIndex")
+	defer stub.MockTransactionEnd("nameIndex")
+	ids, err := idsByName(stub, testTenant, indexName, maxQueryResults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func TestUser_nameIndexEncryptionChange(t *testing.T) {
+	stub := GetNewStub()
+	stub.MockInvoke("1", [][]byte{[]byte("addUser"), user1})
+
+	// 明文用户带密钥修改后按密文索引,明文索引被删除
+	stub.TransientMap = map[string][]byte{transientKeyName: testEncryptionKey}
+	if res := stub.MockInvoke("2", [][]byte{[]byte("alterUser"), user1}); res.Status != shim.OK {
+		t.Fatalf("alterUser: %s", res.Message)
+	}
+	if ids := nameIndexIds(t, stub, name1); len(ids) != 0 {
+		t.Fatalf("plaintext name index kept after encryption: %v", ids)
+	}
+	hmacName := nameIndexValue(testEncryptionKey, encryptionHKDF, name1)
+	if ids := nameIndexIds(t, stub, hmacName); len(ids) != 1 || ids[0] != id1 {
+		t.Fatalf("encrypted name not indexed: %v", ids)
+	}
+
+	if res := stub.MockInvoke("3", [][]byte{[]byte("delUser"), user1}); res.Status != shim.OK {
+		t.Fatalf("delUser: %s", res.Message)
+	}
+	if ids := nameIndexIds(t, stub, hmacName); len(ids) != 0 {
+		t.Fatalf("encrypted name index kept after delete: %v", ids)
+	}
+}
+
+func TestUser_delPlaintextUserWithKey(t *testing.T) {
+	stub := GetNewStub()
+	stub.MockInvoke("1", [][]byte{[]byte("addUser"), user1})
+
+	stub.TransientMap = map[string][]byte{transientKeyName: testEncryptionKey}
+	if res := stub.MockInvoke("2", [][]byte{[]byte("delUser"), user1}); res.Status != shim.OK {
+		t.Fatalf("delUser: %s", res.Message)
+	}
+	if ids := nameIndexIds(t, stub, name1); len(ids) != 0 {
+		t.Fatalf("plaintext name index kept after delete with key: %v", ids)
+	}
+}
@@ -0,0 +1,154 @@
+package chaincode
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/lzb13612/Example-Chaincode/internal/iterate"
+)
+
+// 用户数据使用普通键 user:<租户>:<编码后的id> 保存,Fabric 的区间查询不支持复合键
+const (
+	userKeyPrefix = "user:"
+	userKeyEnd    = "user;" // 紧跟在所有用户键之后的键
+	legacyUserKey = "user"  // 版本 3 之前用户数据的复合键类型
+	maxNumericId  = 99      // 按数值排序的id的最大位数
+)
+
+func init() {
+	registerMigration(2, "numeric ordered user keys", migrateUserKeys)
+}
+
+// numericId 判断id是否为规范的十进制数:不超过 maxNumericId 位且没有前导零
+func numericId(id string) bool {
+	if id == "" || len(id) > maxNumericId || (len(id) > 1 && id[0] == '0') {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '0' || id[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeId
+// @title		encodeId -> 编码用户id
+// @description	生成保持顺序的id编码:数值id编码为 n<两位长度>:<id>,先按位数再按字典序即为数值顺序;其余id编码为 s:<id>,排在所有数值id之后并按字典序排列。
+// @auth		lzb
+// @param		id		字符串	"用户id"
+// @return		encoded	字符串	"编码后的id"
+func encodeId(id string) string {
+	if numericId(id) {
+		return fmt.Sprintf("n%02d:%s", len(id), id)
+	}
+	return "s:" + id
+}
+
+// checkId 校验用户id:需为合法 UTF-8 且不含 U+0000 与 U+10FFFF(与复合键属性的限制一致)
+func checkId(id string) error {
+	if !utf8.ValidString(id) || strings.ContainsRune(id, 0) || strings.ContainsRune(id, utf8.MaxRune) {
+		return fmt.Errorf("invalid user id %q", id)
+	}
+	return nil
+}
+
+// tenantUserRange 租户内所有用户键的区间 [user:<租户>:, user:<租户>;)
+func tenantUserRange(tenant string) (startKey, endKey string) {
+	return userKeyPrefix + tenant + ":", userKeyPrefix + tenant + ";"
+}
+
+// userKey 租户内用户数据的键
+func userKey(tenant, id string) (string, error) {
+	if err := checkTenant(tenant); err != nil {
+		return "", err
+	}
+	if err := checkId(id); err != nil {
+		return "", err
+	}
+	prefix, _ := tenantUserRange(tenant)
+	return prefix + encodeId(id), nil
+}
+
+// userRange
+// @title		userRange -> 用户id区间对应的键区间
+// @description	计算租户内 [startId, endId] 对应的键区间,id 为空表示不限制该端。两端都指定时必须同为数值id或同为非数值id,
+// @description	否则 n<位数>: 与 s: 前缀的键混在一个区间里,数值id按数值、其余按字典序的含义不再成立;起始id在终止id之后时为空区间。
+// @auth		lzb
+// @param		tenant		字符串	"租户"
+// @param		startId		字符串	"起始id(包含)"
+// @param		endId		字符串	"终止id(包含)"
+// @return		startKey	字符串	"起始键(包含)"
+// @return		endKey		字符串	"终止键(不包含)"
+// @return		err			错误		"租户或id不合法,或两端类型不同"
+func userRange(tenant, startId, endId string) (startKey, endKey string, err error) {
+	if err := checkTenant(tenant); err != nil {
+		return "", "", err
+	}
+	if startId != "" && endId != "" && numericId(startId) != numericId(endId) {
+		return "", "", fmt.Errorf("start id %q and end id %q must both be numeric or both be non-numeric", startId, endId)
+	}
+	startKey, endKey = tenantUserRange(tenant)
+	if startId != "" {
+		if startKey, err = userKey(tenant, startId); err != nil {
+			return "", "", err
+		}
+	}
+	if endId != "" {
+		if endKey, err = userKey(tenant, endId); err != nil {
+			return "", "", err
+		}
+		// 紧跟在 endId 之后的键,使区间包含 endId
+		endKey += "\x00"
+	}
+	// 起止颠倒时不把倒置的区间交给状态数据库
+	if startKey > endKey {
+		endKey = startKey
+	}
+	return startKey, endKey, nil
+}
+
+// migrateUserKeys 迁移步骤 -> 将复合键 user[id] 下的用户数据移动到执行升级的组织的租户下按数值排序的普通键
+func migrateUserKeys(stub shim.ChaincodeStubInterface) error {
+	tenant, err := stubTenant(stub)
+	if err != nil {
+		return err
+	}
+	resultIterator, err := stub.GetStateByPartialCompositeKey(legacyUserKey, []string{})
+	if err != nil {
+		return fmt.Errorf("get user info by partial composite key error:%s", err)
+	}
+	// 先读出全部旧数据,再统一写入,避免边遍历边修改
+	legacy := make([]*queryresult.KV, 0)
+	err = iterate.States(resultIterator, iterate.Unlimited, func(kv *queryresult.KV) error {
+		legacy = append(legacy, kv)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("user iterator error:%s", err)
+	}
+	for _, kv := range legacy {
+		_, attributes, err := stub.SplitCompositeKey(kv.Key)
+		if err != nil || len(attributes) != 1 {
+			return fmt.Errorf("split user key %q error:%v", kv.Key, err)
+		}
+		key, err := userKey(tenant, attributes[0])
+		if err != nil {
+			return err
+		}
+		value, err := markLegacyValue(kv.Value)
+		if err != nil {
+			return fmt.Errorf("user %s error:%s", attributes[0], err)
+		}
+		if err := stub.PutState(key, value); err != nil {
+			return fmt.Errorf("put user %s state error:%s", attributes[0], err)
+		}
+		if err := stub.DelState(kv.Key); err != nil {
+			return fmt.Errorf("del legacy user %s state error:%s", attributes[0], err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/lzb13612/Example-Chaincode/internal/mockledger"
+)
+
+// putLegacyUser 以版本 3 之前的复合键写入用户,模拟旧部署的数据
+func putLegacyUser(t testing.TB, stub *shimtest.MockStub, user UserInfo) {
+	key, err := stub.CreateCompositeKey(legacyUserKey, []string{user.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	userBytes, _ := json.Marshal(user)
+	stub.MockTransactionStart("legacy")
+	defer stub.MockTransactionEnd("legacy")
+	if err := stub.PutState(key, userBytes); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncodeId_Order(t *testing.T) {
+	// 期望的顺序:数值id按数值,其余id(含前导零与超长数字)按字典序排在之后
+	ids := []string{"0", "1", "2", "9", "10", "99", "100", "200", "1000", strings.Repeat("9", 99), "", "007", strings.Repeat("1", 100), "1a", "Org1MSP::lzb", "a"}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		key, err := userKey(testTenant, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+	}
+	if !sort.StringsAreSorted(keys) {
+		t.Fatalf("keys are not in id order: %q", keys)
+	}
+	start, end := tenantUserRange(testTenant)
+	for _, key := range keys {
+		if key < start || key >= end {
+			t.Fatalf("key %q is outside the user key range", key)
+		}
+	}
+}
+
+func TestUserKey_InvalidIds(t *testing.T) {
+	for _, id := range []string{"a\x00b", string(rune(0x10FFFF)), "\xff"} {
+		if _, err := userKey(testTenant, id); err == nil {
+			t.Fatalf("expected id %q to be rejected", id)
+		}
+	}
+	for _, tenant := range []string{"", "Org1MSP:", "Org;1", "-Org1", "组织"} {
+		if _, err := userKey(tenant, "1"); err == nil {
+			t.Fatalf("expected tenant %q to be rejected", tenant)
+		}
+	}
+}
+
+// idsOf 返回分页结果中的用户id
+func idsOf(t *testing.T, payload []byte) ([]string, string) {
+	var page UserPage
+	if err := json.Unmarshal(payload, &page); err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, len(page.Users))
+	for i, user := range page.Users {
+		ids[i] = user.Id
+	}
+	return ids, page.Bookmark
+}
+
+func TestQueryUsersByIdRange(t *testing.T) {
+	ledger := NewLedger(t, "")
+	for _, id := range []string{"10", "99", "100", "150", "200", "201", "1000", "abc"} {
+		user := `{"id":"` + id + `","name":"user` + id + `","sex":"男"}`
+		if res := ledger.Invoke("addUser", []string{user}, nil); res.Status != shim.OK {
+			t.Fatal(res.Message)
+		}
+	}
+	cases := []struct {
+		name       string
+		start, end string
+		expected   string
+	}{
+		{"numeric", "100", "200", "100,150,200"},
+		{"open start", "", "10", "1,2,10"},
+		{"open end", "201", "", "201,1000,abc"},
+		{"all", "", "", "1,2,10,99,100,150,200,201,1000,abc"},
+		{"missing bounds", "101", "149", ""},
+		{"reversed", "200", "100", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// 每页两个用户,逐页读取
+			var all []string
+			bookmark := ""
+			for pages := 0; ; pages++ {
+				if pages > 10 {
+					t.Fatal("pagination does not terminate")
+				}
+				res := ledger.Invoke("queryUsersByIdRange", []string{c.start, c.end, "2", bookmark}, nil)
+				if res.Status != shim.OK {
+					t.Fatal(res.Message)
+				}
+				var ids []string
+				ids, bookmark = idsOf(t, res.Payload)
+				if len(ids) > 2 {
+					t.Fatalf("page has %d users", len(ids))
+				}
+				all = append(all, ids...)
+				if bookmark == "" {
+					break
+				}
+			}
+			if got := strings.Join(all, ","); got != c.expected {
+				t.Fatalf("expected %s, got %s", c.expected, got)
+			}
+		})
+	}
+	// 合约函数与旧版函数返回相同的结果
+	stream := ledger.Invoke("queryUsersByIdRange", []string{"100", "200", "10"}, nil)
+	decode := ledger.Invoke("QueryUsersByIdRange", []string{"100", "200", "10", ""}, nil)
+	if string(stream.Payload) != string(decode.Payload) {
+		t.Fatalf("stream payload %s differs from %s", stream.Payload, decode.Payload)
+	}
+	if res := ledger.Invoke("queryUsersByIdRange", []string{"1", "2"}, nil); res.Status == shim.OK || res.Message != "no enough args" {
+		t.Fatalf("expected argument error, got %d %s", res.Status, res.Message)
+	}
+	// 数值id与非数值id的键前缀不同,不能作为同一区间的两端
+	for _, bounds := range [][]string{{"10", "abc"}, {"abc", "10"}, {"007", "10"}} {
+		res := ledger.Invoke("queryUsersByIdRange", []string{bounds[0], bounds[1], "10"}, nil)
+		if res.Status == shim.OK || !strings.Contains(res.Message, "both be numeric") {
+			t.Fatalf("%q: expected mixed bounds error, got %d %s %s", bounds, res.Status, res.Message, res.Payload)
+		}
+	}
+}
+
+func TestMigrateUserKeys(t *testing.T) {
+	// 版本 2 的账本:用户仍以复合键保存
+	stub := NewStub("v2")
+	for _, user := range []UserInfo{{Id: "10", Name: "ten"}, {Id: "2", Name: "two"}, {Id: "abc", Name: "abc"}, {Id: "1", Name: "one"}} {
+		putLegacyUser(t, stub, user)
+	}
+	stub.MockTransactionStart("meta")
+	_ = putMeta(stub, ChaincodeMeta{Version: 2})
+	stub.MockTransactionEnd("meta")
+
+	if res := stub.MockInit("upgrade", [][]byte{[]byte("init")}); res.Status != shim.OK {
+		t.Fatalf("upgrade: %s", res.Message)
+	}
+	for key := range stub.State {
+		if strings.HasPrefix(key, "\x00"+legacyUserKey+"\x00") {
+			t.Fatalf("legacy key %q left after migration", key)
+		}
+	}
+	res := stub.MockInvoke("1", [][]byte{[]byte("queryAllUser")})
+	var users []UserInfo
+	_ = json.Unmarshal(res.Payload, &users)
+	ids := make([]string, len(users))
+	for i, user := range users {
+		ids[i] = user.Id
+	}
+	if got := strings.Join(ids, ","); got != "1,2,10,abc" {
+		t.Fatalf("expected users in numeric order, got %s", got)
+	}
+}
+
+func TestMigrateUserKeys_FromLegacyLedger(t *testing.T) {
+	// 未记录版本号的旧部署依次执行建立用户名索引与迁移用户键
+	ledger := NewLedger(t, "")
+	snap := ledger.Snapshot()
+	snap.State = map[string]mockledger.VersionedValue{
+		"\x00user\x0010\x00": {Value: []byte(`{"id":"10","name":"ten","sex":"男"}`)},
+		"\x00user\x003\x00":  {Value: []byte(`{"id":"3","name":"three","sex":"女"}`)},
+	}
+	ledger.Restore(snap)
+	if res := ledger.Invoke("init", nil, nil); res.Status != shim.OK {
+		t.Fatal(res.Message)
+	}
+	res := ledger.Invoke("queryUserByName", []string{`{"name":"ten"}`}, nil)
+	if string(res.Payload) != `[{"id":"10","name":"ten","sex":"男"}]` {
+		t.Fatalf("expected migrated user to be indexed, got %s %s", res.Payload, res.Message)
+	}
+	if kvs := ledger.Range("user:", "user;"); len(kvs) != 2 || kvs[0].Key != "user:Org1MSP:n01:3" || kvs[1].Key != "user:Org1MSP:n02:10" {
+		t.Fatalf("unexpected user keys %q", kvs)
+	}
+}
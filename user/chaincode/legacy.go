@@ -0,0 +1,573 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/lzb13612/Example-Chaincode/internal/legacy"
+)
+
+// UserId 添加用户的响应载荷
+type UserId struct {
+	Id string `json:"id"` // 用户id
+}
+
+// ChangeRequestId 提交修改申请的响应载荷
+type ChangeRequestId struct {
+	Id string `json:"id"` // 申请id
+}
+
+// legacyFunction 旧版函数 -> 接收原始字符串参数,返回值序列化为 JSON 作为响应载荷,json.RawMessage 原样返回
+type legacyFunction func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error)
+
+// legacyFunctions
+// @title		legacyFunctions -> 旧版函数表
+// @description	Fabric 1.x 版本的函数名与参数格式(UserInfo JSON)到合约函数的映射。
+// @auth		lzb
+// @return		functions	map	"函数名 -> 旧版函数"
+func (e *UserContract) legacyFunctions() map[string]legacyFunction {
+	return map[string]legacyFunction{
+		"init": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			config := ""
+			if len(args) > 0 {
+				config = args[0]
+			}
+			return nil, e.InitLedger(ctx, config)
+		},
+		"setConfig": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			if len(args) != 1 {
+				return nil, errNoEnoughArgs
+			}
+			return nil, e.SetConfig(ctx, args[0])
+		},
+		"addUser": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			if len(args) != 1 && len(args) != 2 {
+				return nil, errNoEnoughArgs
+			}
+			// 与旧版链码一致,addUser 的解析错误信息不含 user
+			var userInfo UserInfo
+			if err := json.Unmarshal([]byte(args[0]), &userInfo); err != nil {
+				return nil, fmt.Errorf("unmarshal error:%s", err)
+			}
+			// 第二个参数为可选的注册签名
+			var id string
+			var err error
+			if len(args) == 1 {
+				id, err = e.AddUser(ctx, userInfo)
+			} else {
+				var registration Registration
+				if err := json.Unmarshal([]byte(args[1]), &registration); err != nil {
+					return nil, fmt.Errorf("unmarshal registration error:%s", err)
+				}
+				id, err = e.AddSignedUser(ctx, userInfo, registration)
+			}
+			if err != nil {
+				return nil, err
+			}
+			// 返回用户id,由链码分配id时客户端从这里得到新用户的id
+			return UserId{Id: id}, nil
+		},
+		"queryOnceUser": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			userInfo, err := singleUserArg(args)
+			if err != nil {
+				return nil, err
+			}
+			return e.QueryOnceUser(ctx, userInfo.Id)
+		},
+		"queryAllUser": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			// 参数为可选的用户状态
+			if len(args) > 1 {
+				return nil, errNoEnoughArgs
+			}
+			tenant, v, err := readScope(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if v.status, err = statusArg(args); err != nil {
+				return nil, err
+			}
+			payload, err := streamAllUsers(ctx.GetStub(), tenant, v)
+			return json.RawMessage(payload), err
+		},
+		"queryUserPage": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			// 参数为每页用户数、可选的书签与用户状态
+			if len(args) < 1 || len(args) > 3 {
+				return nil, errNoEnoughArgs
+			}
+			return streamPageArgs(ctx, "", "", args[0], args[1:])
+		},
+		"queryUsersByIdRange": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			// 参数为起始id、终止id、每页用户数、可选的书签与用户状态
+			if len(args) < 3 || len(args) > 5 {
+				return nil, errNoEnoughArgs
+			}
+			return streamPageArgs(ctx, args[0], args[1], args[2], args[3:])
+		},
+		"alterUser": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			userInfo, err := singleUserArg(args)
+			if err != nil {
+				return nil, err
+			}
+			return nil, e.AlterUser(ctx, userInfo)
+		},
+		"delUser": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			userInfo, err := singleUserArg(args)
+			if err != nil {
+				return nil, err
+			}
+			return nil, e.DelUser(ctx, userInfo.Id)
+		},
+		"registerSelf": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			userInfo, err := singleUserArg(args)
+			if err != nil {
+				return nil, err
+			}
+			// 旧版参数可以携带用户所属的组织
+			return registerSelf(ctx, userInfo.Name, userInfo.Sex, userInfo.OrgId)
+		},
+		"whoAmI": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			return e.WhoAmI(ctx)
+		},
+		"queryUserByName": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			userInfo, err := singleUserArg(args)
+			if err != nil {
+				return nil, err
+			}
+			tenant, v, err := readScope(ctx)
+			if err != nil {
+				return nil, err
+			}
+			payload, err := streamUsersByName(ctx.GetStub(), tenant, v, userInfo.Name)
+			return json.RawMessage(payload), err
+		},
+		"grantRole": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			// 参数为 MSP ID、主体与角色
+			if len(args) != 3 {
+				return nil, errNoEnoughArgs
+			}
+			return nil, e.GrantRole(ctx, args[0], args[1], args[2])
+		},
+		"revokeRole": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			if len(args) != 3 {
+				return nil, errNoEnoughArgs
+			}
+			return nil, e.RevokeRole(ctx, args[0], args[1], args[2])
+		},
+		"listRoles": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			return e.ListRoles(ctx)
+		},
+		"listRoleChanges": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			return e.ListRoleChanges(ctx)
+		},
+		"activateUser": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			// 参数为包含 id 与 statusReason 的 UserInfo JSON
+			userInfo, err := singleUserArg(args)
+			if err != nil {
+				return nil, err
+			}
+			return nil, e.ActivateUser(ctx, userInfo.Id, userInfo.StatusReason)
+		},
+		"suspendUser": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			userInfo, err := singleUserArg(args)
+			if err != nil {
+				return nil, err
+			}
+			return nil, e.SuspendUser(ctx, userInfo.Id, userInfo.StatusReason)
+		},
+		"closeUser": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			userInfo, err := singleUserArg(args)
+			if err != nil {
+				return nil, err
+			}
+			return nil, e.CloseUser(ctx, userInfo.Id, userInfo.StatusReason)
+		},
+		"reopenUser": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			userInfo, err := singleUserArg(args)
+			if err != nil {
+				return nil, err
+			}
+			return nil, e.ReopenUser(ctx, userInfo.Id, userInfo.StatusReason)
+		},
+		"requestUserChange": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			// 参数为修改类型与 UserInfo JSON
+			if len(args) != 2 {
+				return nil, errNoEnoughArgs
+			}
+			userInfo, err := unmarshalUser(args[1])
+			if err != nil {
+				return nil, err
+			}
+			id, err := e.RequestUserChange(ctx, args[0], userInfo)
+			if err != nil {
+				return nil, err
+			}
+			return ChangeRequestId{Id: id}, nil
+		},
+		"approveUserChange": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			// 参数为申请id
+			if len(args) != 1 {
+				return nil, errNoEnoughArgs
+			}
+			return e.ApproveUserChange(ctx, args[0])
+		},
+		"rejectUserChange": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			// 参数为申请id与拒绝原因
+			if len(args) != 2 {
+				return nil, errNoEnoughArgs
+			}
+			return e.RejectUserChange(ctx, args[0], args[1])
+		},
+		"listUserChanges": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			// 参数为可选的申请状态
+			if len(args) > 1 {
+				return nil, errNoEnoughArgs
+			}
+			status := ""
+			if len(args) == 1 {
+				status = args[0]
+			}
+			return e.ListUserChanges(ctx, status)
+		},
+		"queryUserHistory": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			userInfo, err := singleUserArg(args)
+			if err != nil {
+				return nil, err
+			}
+			return e.QueryUserHistory(ctx, userInfo.Id)
+		},
+		"createOrg": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			// 参数为 Organization JSON
+			org, err := singleOrgArg(args)
+			if err != nil {
+				return nil, err
+			}
+			return nil, e.CreateOrg(ctx, org)
+		},
+		"queryOrg": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			org, err := singleOrgArg(args)
+			if err != nil {
+				return nil, err
+			}
+			return e.QueryOrg(ctx, org.Id)
+		},
+		"updateOrg": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			org, err := singleOrgArg(args)
+			if err != nil {
+				return nil, err
+			}
+			return nil, e.UpdateOrg(ctx, org)
+		},
+		"deleteOrg": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			org, err := singleOrgArg(args)
+			if err != nil {
+				return nil, err
+			}
+			return nil, e.DeleteOrg(ctx, org.Id)
+		},
+		"listOrgs": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			// 参数为每页组织数与可选的书签
+			pageSize, bookmark, err := pageArgs(args)
+			if err != nil {
+				return nil, err
+			}
+			return e.ListOrgs(ctx, pageSize, bookmark)
+		},
+		"queryOrgUsers": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			// 参数为组织id、每页用户数与可选的书签
+			if len(args) < 1 {
+				return nil, errNoEnoughArgs
+			}
+			pageSize, bookmark, err := pageArgs(args[1:])
+			if err != nil {
+				return nil, err
+			}
+			return e.QueryOrgUsers(ctx, args[0], pageSize, bookmark)
+		},
+		"moveUser": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			// 参数为用户id、新组织id与调动原因
+			if len(args) != 3 {
+				return nil, errNoEnoughArgs
+			}
+			return nil, e.MoveUser(ctx, args[0], args[1], args[2])
+		},
+		"assignOrg": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			// 参数为组织id、分配原因、本次最多扫描的用户数与可选的书签
+			if len(args) < 2 {
+				return nil, errNoEnoughArgs
+			}
+			limit, bookmark, err := pageArgs(args[2:])
+			if err != nil {
+				return nil, err
+			}
+			return e.AssignOrg(ctx, args[0], args[1], limit, bookmark)
+		},
+		"queryUserMoves": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			userInfo, err := singleUserArg(args)
+			if err != nil {
+				return nil, err
+			}
+			return e.QueryUserMoves(ctx, userInfo.Id)
+		},
+		"addRelation": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			// 参数为关系类型、起点用户id与终点用户id
+			if len(args) != 3 {
+				return nil, errNoEnoughArgs
+			}
+			return nil, e.AddRelation(ctx, args[0], args[1], args[2])
+		},
+		"removeRelation": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			if len(args) != 3 {
+				return nil, errNoEnoughArgs
+			}
+			return nil, e.RemoveRelation(ctx, args[0], args[1], args[2])
+		},
+		"queryRelations": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			// 参数为用户id、关系类型(为空表示全部类型)、方向与最多返回的关系数
+			if len(args) != 4 {
+				return nil, errNoEnoughArgs
+			}
+			limit, err := parsePageSize(args[3])
+			if err != nil {
+				return nil, err
+			}
+			return e.QueryRelations(ctx, args[0], args[1], args[2], limit)
+		},
+		"traverseRelations": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			// 参数为起点用户id、关系类型(为空表示全部类型)、方向、最大深度与最多返回的用户数
+			if len(args) != 5 {
+				return nil, errNoEnoughArgs
+			}
+			depth, err := parsePageSize(args[3])
+			if err != nil {
+				return nil, err
+			}
+			limit, err := parsePageSize(args[4])
+			if err != nil {
+				return nil, err
+			}
+			return e.TraverseRelations(ctx, args[0], args[1], args[2], depth, limit)
+		},
+		"createGroup": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			// 参数为 Group JSON
+			group, err := singleGroupArg(args)
+			if err != nil {
+				return nil, err
+			}
+			return nil, e.CreateGroup(ctx, group)
+		},
+		"queryGroup": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			group, err := singleGroupArg(args)
+			if err != nil {
+				return nil, err
+			}
+			return e.QueryGroup(ctx, group.Id)
+		},
+		"updateGroup": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			group, err := singleGroupArg(args)
+			if err != nil {
+				return nil, err
+			}
+			return nil, e.UpdateGroup(ctx, group)
+		},
+		"deleteGroup": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			group, err := singleGroupArg(args)
+			if err != nil {
+				return nil, err
+			}
+			return nil, e.DeleteGroup(ctx, group.Id)
+		},
+		"listGroups": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			// 参数为每页用户组数与可选的书签
+			pageSize, bookmark, err := pageArgs(args)
+			if err != nil {
+				return nil, err
+			}
+			return e.ListGroups(ctx, pageSize, bookmark)
+		},
+		"addMember": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			// 参数为组id与用户id
+			if len(args) != 2 {
+				return nil, errNoEnoughArgs
+			}
+			return nil, e.AddMember(ctx, args[0], args[1])
+		},
+		"removeMember": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			if len(args) != 2 {
+				return nil, errNoEnoughArgs
+			}
+			return nil, e.RemoveMember(ctx, args[0], args[1])
+		},
+		"queryGroupMembers": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			// 参数为组id、每页成员数与可选的书签
+			if len(args) < 1 {
+				return nil, errNoEnoughArgs
+			}
+			pageSize, bookmark, err := pageArgs(args[1:])
+			if err != nil {
+				return nil, err
+			}
+			return e.QueryGroupMembers(ctx, args[0], pageSize, bookmark)
+		},
+		"queryUserGroups": func(ctx contractapi.TransactionContextInterface, args []string) (interface{}, error) {
+			// 参数为用户id、每页用户组数与可选的书签
+			if len(args) < 1 {
+				return nil, errNoEnoughArgs
+			}
+			pageSize, bookmark, err := pageArgs(args[1:])
+			if err != nil {
+				return nil, err
+			}
+			return e.QueryUserGroups(ctx, args[0], pageSize, bookmark)
+		},
+	}
+}
+
+// legacyMessages 旧版函数成功时的响应信息,与 Fabric 1.x 链码一致
+var legacyMessages = map[string]string{
+	"init":          "Init success",
+	"addUser":       "add user success",
+	"queryOnceUser": "get once user success",
+	"queryAllUser":  "get all user info success",
+	"alterUser":     "alt user success",
+	"delUser":       "del user state success",
+}
+
+// errNoEnoughArgs 旧版函数参数数量错误
+var errNoEnoughArgs = legacy.Thresholdf("no enough args")
+
+// NewChaincode
+// @title		NewChaincode -> 创建用户链码
+// @description	创建用户合约链码,旧版函数名在合约之前按原样分派,返回 Fabric 1.x 链码的状态码与信息。
+// @auth		lzb
+// @return		cc		*legacy.Chaincode	"用户链码"
+// @return		err		错误					"合约元数据错误"
+func NewChaincode() (*legacy.Chaincode, error) {
+	contract := NewUserContract()
+	cc, err := contractapi.NewChaincode(contract)
+	if err != nil {
+		return nil, err
+	}
+	functions := make(map[string]legacy.Function)
+	for name, function := range contract.legacyFunctions() {
+		functions[name] = legacy.Function{Message: legacyMessages[name], Invoke: legacyPayload(function)}
+	}
+	return legacy.New(cc, functions), nil
+}
+
+// legacyPayload 将旧版函数的返回值序列化为 JSON 响应载荷,json.RawMessage 原样返回
+func legacyPayload(function legacyFunction) func(ctx contractapi.TransactionContextInterface, args []string) ([]byte, error) {
+	return func(ctx contractapi.TransactionContextInterface, args []string) ([]byte, error) {
+		result, err := function(ctx, args)
+		if err != nil || result == nil {
+			return nil, err
+		}
+		// 列表查询已流式编码为 JSON,直接作为载荷返回
+		if raw, ok := result.(json.RawMessage); ok {
+			return raw, nil
+		}
+		payload, err := json.Marshal(result)
+		if err != nil {
+			return nil, fmt.Errorf("marshal payload error:%s", err)
+		}
+		return payload, nil
+	}
+}
+
+// unknownTransaction
+// @title		unknownTransaction -> 未知函数
+// @description	合约与旧版函数表中都找不到请求的函数时返回错误,信息与 Fabric 1.x 链码一致。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @return		err		错误			"找不到函数"
+func (e *UserContract) unknownTransaction(ctx contractapi.TransactionContextInterface) error {
+	funcName, _ := ctx.GetStub().GetFunctionAndParameters()
+	return fmt.Errorf("not find function %s", funcName)
+}
+
+// streamPageArgs 解析分页参数并流式查询本租户id区间内的用户,optional 依次为可选的书签与用户状态
+func streamPageArgs(ctx contractapi.TransactionContextInterface, startId, endId, pageSizeArg string, optional []string) (json.RawMessage, error) {
+	tenant, v, err := readScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pageSize, err := parsePageSize(pageSizeArg)
+	if err != nil {
+		return nil, err
+	}
+	next := ""
+	if len(optional) > 0 {
+		next = optional[0]
+	}
+	if len(optional) > 1 {
+		if v.status, err = statusArg(optional[1:]); err != nil {
+			return nil, err
+		}
+	}
+	return streamUserRange(ctx.GetStub(), tenant, v, startId, endId, pageSize, next)
+}
+
+// statusArg 解析可选的用户状态参数,为空时不过滤
+func statusArg(args []string) (string, error) {
+	if len(args) == 0 || args[0] == "" {
+		return "", nil
+	}
+	return args[0], checkStatus(args[0])
+}
+
+// pageArgs 解析每页数量与可选的书签参数
+func pageArgs(args []string) (int32, string, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return 0, "", errNoEnoughArgs
+	}
+	pageSize, err := parsePageSize(args[0])
+	if err != nil {
+		return 0, "", err
+	}
+	bookmark := ""
+	if len(args) == 2 {
+		bookmark = args[1]
+	}
+	return pageSize, bookmark, nil
+}
+
+// singleGroupArg 校验参数个数并解析唯一的 Group JSON 参数
+func singleGroupArg(args []string) (Group, error) {
+	var group Group
+	if len(args) != 1 {
+		return group, errNoEnoughArgs
+	}
+	if err := json.Unmarshal([]byte(args[0]), &group); err != nil {
+		return group, fmt.Errorf("unmarshal group error:%s", err)
+	}
+	return group, nil
+}
+
+// singleOrgArg 校验参数个数并解析唯一的 Organization JSON 参数
+func singleOrgArg(args []string) (Organization, error) {
+	var org Organization
+	if len(args) != 1 {
+		return org, errNoEnoughArgs
+	}
+	if err := json.Unmarshal([]byte(args[0]), &org); err != nil {
+		return org, fmt.Errorf("unmarshal organization error:%s", err)
+	}
+	return org, nil
+}
+
+// singleUserArg 校验参数个数并解析唯一的 UserInfo JSON 参数
+func singleUserArg(args []string) (UserInfo, error) {
+	if len(args) != 1 {
+		return UserInfo{}, errNoEnoughArgs
+	}
+	return unmarshalUser(args[0])
+}
+
+// unmarshalUser 解析 UserInfo JSON 参数
+func unmarshalUser(arg string) (UserInfo, error) {
+	var userInfo UserInfo
+	if err := json.Unmarshal([]byte(arg), &userInfo); err != nil {
+		return userInfo, fmt.Errorf("unmarshal user error:%s", err)
+	}
+	return userInfo, nil
+}
@@ -0,0 +1,192 @@
+package chaincode
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 用户状态,未记录状态的用户(状态生命周期之前添加的用户)视为 active
+const (
+	StatusPending   = "pending"   // 待激活
+	StatusActive    = "active"    // 正常
+	StatusSuspended = "suspended" // 已暂停
+	StatusClosed    = "closed"    // 已关闭
+)
+
+// operatorRole 运营角色,可以激活与暂停本租户的用户
+const operatorRole = "operator"
+
+// maxStatusReason 状态变更原因的最大长度
+const maxStatusReason = 256
+
+// transition 状态变更 -> 允许的起始状态、目标状态与可以执行变更的角色
+type transition struct {
+	name  string   // 变更名称,用于错误信息
+	from  []string // 允许的起始状态
+	to    string   // 目标状态
+	roles []string // 可以执行变更的本租户角色
+}
+
+var (
+	activateTransition = transition{"activate", []string{StatusPending, StatusSuspended}, StatusActive, []string{adminRole, operatorRole}}
+	suspendTransition  = transition{"suspend", []string{StatusActive}, StatusSuspended, []string{adminRole, operatorRole}}
+	closeTransition    = transition{"close", []string{StatusPending, StatusActive, StatusSuspended}, StatusClosed, []string{adminRole}}
+	reopenTransition   = transition{"reopen", []string{StatusClosed}, StatusActive, []string{adminRole}}
+)
+
+// userStatus 用户的状态,未记录状态时为 active
+func userStatus(user *UserInfo) string {
+	if user.Status == "" {
+		return StatusActive
+	}
+	return user.Status
+}
+
+// checkStatus 校验查询条件中的用户状态
+func checkStatus(status string) error {
+	switch status {
+	case StatusPending, StatusActive, StatusSuspended, StatusClosed:
+		return nil
+	default:
+		return fmt.Errorf("invalid status %q", status)
+	}
+}
+
+// initialStatus 新用户的状态:链码配置要求激活时为 pending,否则不记录状态(即 active)
+func initialStatus(config ChaincodeConfig) string {
+	if config.RequireActivation {
+		return StatusPending
+	}
+	return ""
+}
+
+// changeStatus
+// @title		changeStatus -> 变更用户状态
+// @description	校验调用者角色与起始状态后写入新状态、原因与执行者,变更随用户记录进入历史。
+// @auth		lzb
+// @param 		ctx		交易上下文		"包含所有链码API的库"
+// @param		id		字符串			"用户id"
+// @param		reason	字符串			"变更原因"
+// @param		t		transition		"状态变更"
+// @return		err		错误				"变更失败的原因"
+func changeStatus(ctx contractapi.TransactionContextInterface, id, reason string, t transition) error {
+	stub := ctx.GetStub()
+	tenant, err := resolveTenant(ctx, true)
+	if err != nil {
+		return err
+	}
+	if reason == "" {
+		return fmt.Errorf("%s user error:reason is required", t.name)
+	}
+	if len(reason) > maxStatusReason {
+		return fmt.Errorf("%s user error:reason is longer than %d bytes", t.name, maxStatusReason)
+	}
+	allowed := false
+	for _, role := range t.roles {
+		if hasTenantRole(ctx, tenant, role) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("%s user error:permission denied", t.name)
+	}
+	user, found, err := getUser(stub, tenant, id)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("user %s does not exist", id)
+	}
+	current := userStatus(&user)
+	legal := false
+	for _, from := range t.from {
+		if current == from {
+			legal = true
+			break
+		}
+	}
+	if !legal {
+		return fmt.Errorf("%s user error:user %s is %s", t.name, id, current)
+	}
+	by, err := callerUserId(ctx)
+	if err != nil {
+		return err
+	}
+	user.Status, user.StatusReason, user.StatusBy = t.to, reason, by
+	return putUser(stub, tenant, user)
+}
+
+// checkNotClosed 已关闭的用户不能修改,需要先重新开启
+func checkNotClosed(user *UserInfo) error {
+	if userStatus(user) == StatusClosed {
+		return errors.New("user is closed")
+	}
+	return nil
+}
+
+// ActivateUser
+// @title		ActivateUser -> 激活用户
+// @description	将待激活或已暂停的用户变为正常,需要本租户的管理员或运营角色。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @param		id		字符串		"用户id"
+// @param		reason	字符串		"变更原因"
+// @return		err		错误			"变更失败的原因"
+func (e *UserContract) ActivateUser(ctx contractapi.TransactionContextInterface, id string, reason string) error {
+	return changeStatus(ctx, id, reason, activateTransition)
+}
+
+// SuspendUser
+// @title		SuspendUser -> 暂停用户
+// @description	将正常的用户暂停,需要本租户的管理员或运营角色。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @param		id		字符串		"用户id"
+// @param		reason	字符串		"变更原因"
+// @return		err		错误			"变更失败的原因"
+func (e *UserContract) SuspendUser(ctx contractapi.TransactionContextInterface, id string, reason string) error {
+	return changeStatus(ctx, id, reason, suspendTransition)
+}
+
+// CloseUser
+// @title		CloseUser -> 关闭用户
+// @description	关闭未关闭的用户,关闭后不能修改,需要本租户的管理员角色。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @param		id		字符串		"用户id"
+// @param		reason	字符串		"变更原因"
+// @return		err		错误			"变更失败的原因"
+func (e *UserContract) CloseUser(ctx contractapi.TransactionContextInterface, id string, reason string) error {
+	return changeStatus(ctx, id, reason, closeTransition)
+}
+
+// ReopenUser
+// @title		ReopenUser -> 重新开启用户
+// @description	将已关闭的用户恢复为正常,需要本租户的管理员角色。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @param		id		字符串		"用户id"
+// @param		reason	字符串		"变更原因"
+// @return		err		错误			"变更失败的原因"
+func (e *UserContract) ReopenUser(ctx contractapi.TransactionContextInterface, id string, reason string) error {
+	return changeStatus(ctx, id, reason, reopenTransition)
+}
+
+// QueryUsersByStatus
+// @title		QueryUsersByStatus -> 按状态分页查询用户
+// @description	按 QueryAllUser 的顺序分页查询本租户中处于该状态的用户;看不到状态字段的调用者查不到任何用户。
+// @auth		lzb
+// @param 		ctx			交易上下文	"包含所有链码API的库"
+// @param		status		字符串		"用户状态"
+// @param		pageSize	整型			"每页扫描的用户数,过滤后本页用户可能更少"
+// @param		bookmark	字符串		"上一页返回的书签,第一页为空"
+// @return		page		*UserPage	"本页用户与下一页书签"
+func (e *UserContract) QueryUsersByStatus(ctx contractapi.TransactionContextInterface, status string, pageSize int32, bookmark string) (*UserPage, error) {
+	if err := checkStatus(status); err != nil {
+		return nil, err
+	}
+	return queryUserRange(ctx, "", "", pageSize, bookmark, status)
+}
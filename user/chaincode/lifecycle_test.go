@@ -0,0 +1,154 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// queryStatus 查询用户的状态
+func queryStatus(t *testing.T, payload []byte) UserInfo {
+	t.Helper()
+	var user UserInfo
+	if err := json.Unmarshal(payload, &user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestLifecycle_Transitions(t *testing.T) {
+	ledger := NewLedger(t, `{"requireActivation":true}`)
+	admin := newCreator(t, "Org1MSP", "admin", map[string]string{roleAttribute: adminRole})
+	operator := newCreator(t, "Org1MSP", "bob", nil)
+	if _, err := invokeAs(ledger, admin, "", "grantRole", testTenant, "CN=bob,O=Org1MSP", operatorRole); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := invokeAs(ledger, testCreator, "", "addUser", `{"id":"3","name":"three","status":"active"}`); err != nil {
+		t.Fatal(err)
+	}
+	// 新用户为待激活,客户端不能指定状态
+	payload, _ := invokeAs(ledger, testCreator, "", "queryOnceUser", `{"id":"3"}`)
+	if user := queryStatus(t, payload); user.Status != StatusPending {
+		t.Fatalf("expected pending user, got %s", payload)
+	}
+
+	steps := []struct {
+		identity []byte
+		function string
+		reason   string
+		err      string
+		status   string
+	}{
+		{testCreator, "activateUser", "kyc passed", "permission denied", StatusPending},
+		{operator, "activateUser", "", "reason is required", StatusPending},
+		{operator, "reopenUser", "kyc passed", "permission denied", StatusPending},
+		{admin, "reopenUser", "kyc passed", "user 3 is pending", StatusPending},
+		{operator, "activateUser", "kyc passed", "", StatusActive},
+		{operator, "activateUser", "again", "user 3 is active", StatusActive},
+		{operator, "suspendUser", "fraud check", "", StatusSuspended},
+		{operator, "closeUser", "fraud", "permission denied", StatusSuspended},
+		{admin, "closeUser", "fraud", "", StatusClosed},
+		{operator, "suspendUser", "again", "user 3 is closed", StatusClosed},
+		{admin, "reopenUser", "appeal", "", StatusActive},
+	}
+	for i, step := range steps {
+		arg, _ := json.Marshal(UserInfo{Id: "3", StatusReason: step.reason})
+		_, err := invokeAs(ledger, step.identity, "", step.function, string(arg))
+		if step.err == "" && err != nil {
+			t.Fatalf("step %d %s: %v", i, step.function, err)
+		}
+		if step.err != "" && (err == nil || !strings.Contains(err.Error(), step.err)) {
+			t.Fatalf("step %d %s: expected %q, got %v", i, step.function, step.err, err)
+		}
+		payload, _ := invokeAs(ledger, testCreator, "", "queryOnceUser", `{"id":"3"}`)
+		if user := queryStatus(t, payload); user.Status != step.status {
+			t.Fatalf("step %d %s: expected %s, got %s", i, step.function, step.status, payload)
+		}
+	}
+
+	// 每次变更连同原因与执行者进入历史
+	payload, _ = invokeAs(ledger, testCreator, "", "queryUserHistory", `{"id":"3"}`)
+	var history []UserHistory
+	_ = json.Unmarshal(payload, &history)
+	reasons := make([]string, len(history))
+	for i, entry := range history {
+		reasons[i] = entry.User.Status + ":" + entry.User.StatusReason
+		if entry.User.StatusReason != "" && entry.User.StatusBy == "" {
+			t.Fatalf("status change without executor %s", payload)
+		}
+	}
+	if strings.Join(reasons, ",") != "active:appeal,closed:fraud,suspended:fraud check,active:kyc passed,pending:" {
+		t.Fatalf("unexpected history %s", strings.Join(reasons, ","))
+	}
+}
+
+func TestLifecycle_ClosedUser(t *testing.T) {
+	ledger := NewLedger(t, "")
+	admin := newCreator(t, "Org1MSP", "admin", map[string]string{roleAttribute: adminRole})
+	// 未记录状态的用户视为 active
+	if _, err := invokeAs(ledger, admin, "", "closeUser", `{"id":"1","statusReason":"left"}`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := invokeAs(ledger, admin, "", "alterUser", `{"id":"1","name":"x"}`); err == nil || !strings.Contains(err.Error(), "user is closed") {
+		t.Fatalf("expected closed user error, got %v", err)
+	}
+	if _, err := invokeAs(ledger, admin, "", "closeUser", `{"id":"9","statusReason":"left"}`); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("expected missing user, got %v", err)
+	}
+}
+
+func TestLifecycle_StatusFilter(t *testing.T) {
+	ledger := NewLedger(t, "")
+	admin := newCreator(t, "Org1MSP", "admin", map[string]string{roleAttribute: adminRole})
+	auditor := newAuditor(t, ledger)
+	for i := 3; i <= 6; i++ {
+		if _, err := invokeAs(ledger, testCreator, "", "addUser", benchUser(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range []string{"2", "4", "5"} {
+		if _, err := invokeAs(ledger, admin, "", "suspendUser", `{"id":"`+id+`","statusReason":"review"}`); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, c := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"queryAllUser"}, "1,2,3,4,5,6"},
+		{[]string{"queryAllUser", StatusActive}, "1,3,6"},
+		{[]string{"queryAllUser", StatusSuspended}, "2,4,5"},
+		{[]string{"queryAllUser", StatusClosed}, ""},
+	} {
+		payload, err := invokeAs(ledger, testCreator, "", c.args[0], c.args[1:]...)
+		if err != nil || userIds(t, payload) != c.want {
+			t.Fatalf("%q: expected %s, got %s %v", c.args, c.want, payload, err)
+		}
+	}
+	// 分页按扫描的用户数计算,过滤后的页与合约函数一致
+	legacy, err := invokeAs(ledger, testCreator, "", "queryUserPage", "3", "", StatusSuspended)
+	if err != nil {
+		t.Fatal(err)
+	}
+	method, err := invokeAs(ledger, testCreator, "", "QueryUsersByStatus", StatusSuspended, "3", "")
+	if err != nil || string(legacy) != string(method) {
+		t.Fatalf("legacy page %s differs from %s %v", legacy, method, err)
+	}
+	var page UserPage
+	_ = json.Unmarshal(legacy, &page)
+	if page.Count != 1 || page.Users[0].Id != "2" || page.Bookmark == "" {
+		t.Fatalf("unexpected page %s", legacy)
+	}
+	if payload, _ := invokeAs(ledger, testCreator, "", "queryUsersByIdRange", "4", "", "10", "", StatusSuspended); !strings.Contains(string(payload), `"count":2`) {
+		t.Fatalf("unexpected range page %s", payload)
+	}
+
+	if _, err := invokeAs(ledger, testCreator, "", "queryAllUser", "deleted"); err == nil || !strings.Contains(err.Error(), "invalid status") {
+		t.Fatalf("expected invalid status, got %v", err)
+	}
+	// 看不到状态字段的调用者不能按状态过滤
+	if payload, _ := invokeAs(ledger, auditor, testTenant, "queryAllUser", StatusSuspended); string(payload) != `[]` {
+		t.Fatalf("auditor filtered by hidden status: %s", payload)
+	}
+}
@@ -0,0 +1,203 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/lzb13612/Example-Chaincode/internal/iterate"
+)
+
+// chaincodeVersion 当前代码所对应的账本数据版本,每次数据格式变化时递增
+const chaincodeVersion = 5
+
+// legacyVersion 未记录版本号的旧部署,其账本数据按版本 1 处理
+const legacyVersion = 1
+
+// ChaincodeMeta 链码元数据 -> 记录账本中数据的版本与部署链码的组织
+type ChaincodeMeta struct {
+	Version  int    `json:"version"`            // 账本数据版本
+	Deployer string `json:"deployer,omitempty"` // 部署链码的组织(MSP ID),其管理员可以修改链码配置
+}
+
+// migration 数据迁移步骤 -> 将账本数据从 From 版本升级到 From+1 版本
+type migration struct {
+	From int                                          // 起始版本
+	Name string                                       // 迁移名称
+	Run  func(stub shim.ChaincodeStubInterface) error // 迁移方法
+}
+
+// migrations 已注册的迁移步骤
+var migrations []migration
+
+// registerMigration
+// @title		registerMigration -> 注册迁移步骤
+// @description	注册一个从 from 版本升级到 from+1 版本的迁移步骤,一般在 init() 中调用。
+// @description	一次升级的所有步骤在同一交易内执行,读不到之前步骤的写入,因此每个步骤都直接写入当前版本的数据格式。
+// @auth		lzb
+// @param		from	整型		"起始版本"
+// @param		name	字符串	"迁移名称"
+// @param		run		函数		"迁移方法"
+func registerMigration(from int, name string, run func(stub shim.ChaincodeStubInterface) error) {
+	migrations = append(migrations, migration{From: from, Name: name, Run: run})
+}
+
+// getMeta 读取链码元数据,不存在时 found 为 false
+func getMeta(stub shim.ChaincodeStubInterface) (meta ChaincodeMeta, found bool, err error) {
+	key, err := stub.CreateCompositeKey("meta", []string{"version"})
+	if err != nil {
+		return meta, false, fmt.Errorf("create meta key error:%s", err)
+	}
+	metaByte, err := stub.GetState(key)
+	if err != nil {
+		return meta, false, fmt.Errorf("get meta state error:%s", err)
+	}
+	if len(metaByte) == 0 {
+		return meta, false, nil
+	}
+	if err := json.Unmarshal(metaByte, &meta); err != nil {
+		return meta, false, fmt.Errorf("unmarshal meta error:%s", err)
+	}
+	return meta, true, nil
+}
+
+// putMeta 写入链码元数据
+func putMeta(stub shim.ChaincodeStubInterface, meta ChaincodeMeta) error {
+	key, err := stub.CreateCompositeKey("meta", []string{"version"})
+	if err != nil {
+		return fmt.Errorf("create meta key error:%s", err)
+	}
+	metaByte, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("marshal meta error:%s", err)
+	}
+	if err := stub.PutState(key, metaByte); err != nil {
+		return fmt.Errorf("put meta state error:%s", err)
+	}
+	return nil
+}
+
+// hasUsers 判断未记录版本号的旧部署中是否已存在用户数据,旧部署以复合键 user[id] 保存用户
+func hasUsers(stub shim.ChaincodeStubInterface) (bool, error) {
+	resultIterator, err := stub.GetStateByPartialCompositeKey(legacyUserKey, []string{})
+	if err != nil {
+		return false, fmt.Errorf("get user info by partial composite key error:%s", err)
+	}
+	found := false
+	err = iterate.States(resultIterator, 1, func(kv *queryresult.KV) error {
+		found = true
+		return iterate.ErrStop
+	})
+	return found, err
+}
+
+// upgradeLedger
+// @title		upgradeLedger -> 升级账本数据
+// @description	读取账本中记录的数据版本:全新账本写入种子用户与默认配置(开启 requireOrg);已有数据则依次执行 stored..target 之间的迁移步骤,最后记录新的版本号。
+// @description	全新部署时记录执行初始化的组织为部署组织;未记录部署组织的旧账本由执行升级的组织补记,与迁移到租户命名空间的规则一致。
+// @auth		lzb
+// @param 		stub	shim库	"包含所有链码API的库"
+// @param		target	整型		"目标版本"
+// @param		steps	迁移组	"可用的迁移步骤"
+// @return		version	整型		"升级前的数据版本,全新账本为 0"
+// @return		err		错误		"升级失败的原因"
+func upgradeLedger(stub shim.ChaincodeStubInterface, target int, steps []migration) (version int, err error) {
+	meta, found, err := getMeta(stub)
+	if err != nil {
+		return 0, err
+	}
+	if meta.Deployer == "" {
+		if meta.Deployer, err = stubTenant(stub); err != nil {
+			return meta.Version, err
+		}
+	}
+	if !found {
+		exist, err := hasUsers(stub)
+		if err != nil {
+			return 0, err
+		}
+		if !exist {
+			// 全新账本,种子数据已是当前格式,无需迁移;写入全新账本的默认配置
+			if err := seedLedger(stub); err != nil {
+				return 0, err
+			}
+			if err := putConfig(stub, ChaincodeConfig{RequireOrg: true}); err != nil {
+				return 0, err
+			}
+			return 0, putMeta(stub, ChaincodeMeta{Version: target, Deployer: meta.Deployer})
+		}
+		// 未记录版本号的旧部署
+		meta.Version = legacyVersion
+	}
+	if meta.Version > target {
+		return meta.Version, fmt.Errorf("ledger version %d is newer than chaincode version %d", meta.Version, target)
+	}
+	for current := meta.Version; current < target; current++ {
+		for _, step := range steps {
+			if step.From != current {
+				continue
+			}
+			if err := step.Run(stub); err != nil {
+				return meta.Version, fmt.Errorf("migration %s from version %d error:%s", step.Name, current, err)
+			}
+		}
+	}
+	return meta.Version, putMeta(stub, ChaincodeMeta{Version: target, Deployer: meta.Deployer})
+}
+
+// ChaincodeConfig 链码配置 -> 通过 Init 参数设置,未设置的项保持默认值
+type ChaincodeConfig struct {
+	RequireSignature bool `json:"requireSignature"` // 注册用户时必须提供用户本人的签名
+	UniqueNames      bool `json:"uniqueNames"`      // 用户名不允许重复
+
+	IdGenerator string `json:"idGenerator,omitempty" metadata:",optional"` // 链码分配用户id的方式(txid 或 counter),为空时由客户端提供
+	IdShards    int    `json:"idShards,omitempty" metadata:",optional"`    // counter 方式的分片数,为 0 时使用默认值
+
+	ReadPolicy *ReadPolicy `json:"readPolicy,omitempty" metadata:",optional"` // 查询用户时的可见范围与字段,为空时使用默认策略
+
+	RequireActivation bool `json:"requireActivation,omitempty" metadata:",optional"` // 新用户为 pending 状态,需要激活
+
+	Approval *ApprovalConfig `json:"approval,omitempty" metadata:",optional"` // 需要审批的用户修改,为空时直接执行
+
+	RequireOrg bool `json:"requireOrg,omitempty" metadata:",optional"` // 用户必须属于已登记的组织;全新账本默认开启,升级的旧账本默认关闭,开启后用 AssignOrg 为已有用户分配组织
+}
+
+// getConfig 读取链码配置,不存在时返回默认配置
+func getConfig(stub shim.ChaincodeStubInterface) (ChaincodeConfig, error) {
+	var config ChaincodeConfig
+	key, err := stub.CreateCompositeKey("meta", []string{"config"})
+	if err != nil {
+		return config, fmt.Errorf("create config key error:%s", err)
+	}
+	configByte, err := stub.GetState(key)
+	if err != nil {
+		return config, fmt.Errorf("get config state error:%s", err)
+	}
+	if len(configByte) == 0 {
+		return config, nil
+	}
+	if err := json.Unmarshal(configByte, &config); err != nil {
+		return config, fmt.Errorf("unmarshal config error:%s", err)
+	}
+	return config, nil
+}
+
+// putConfig 写入链码配置,配置计数器方式时固定分片数
+func putConfig(stub shim.ChaincodeStubInterface, config ChaincodeConfig) error {
+	if err := fixIdShards(stub, config); err != nil {
+		return fmt.Errorf("config error:%s", err)
+	}
+	key, err := stub.CreateCompositeKey("meta", []string{"config"})
+	if err != nil {
+		return fmt.Errorf("create config key error:%s", err)
+	}
+	configByte, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("marshal config error:%s", err)
+	}
+	if err := stub.PutState(key, configByte); err != nil {
+		return fmt.Errorf("put config state error:%s", err)
+	}
+	return nil
+}
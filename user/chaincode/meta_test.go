@@ -0,0 +1,112 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+func TestUser_InitRecordsVersion(t *testing.T) {
+	stub := GetNewStub()
+	stub.MockTransactionStart("meta")
+	meta, found, err := getMeta(stub)
+	stub.MockTransactionEnd("meta")
+	if err != nil || !found {
+		t.Fatalf("meta not recorded: found=%v err=%v", found, err)
+	}
+	if meta.Version != chaincodeVersion || meta.Deployer != testTenant {
+		t.Fatalf("meta = %+v, want version %d deployed by %s", meta, chaincodeVersion, testTenant)
+	}
+}
+
+func TestUser_SetConfig(t *testing.T) {
+	ledger := NewLedger(t, `{"admin":"CN=root,O=Org1MSP"}`)
+	root := newCreator(t, "Org1MSP", "root", nil)
+	// 部署后任何人都不能通过 Init 覆盖配置
+	for _, identity := range [][]byte{testCreator, root} {
+		if _, err := invokeAs(ledger, identity, "", "init", `{"uniqueNames":true}`); err == nil || !strings.Contains(err.Error(), "first deployment") {
+			t.Fatalf("expected first deployment only, got %v", err)
+		}
+	}
+	// 只有部署组织的管理员可以修改配置,其他组织自行签发的管理员证书不行
+	for _, identity := range [][]byte{testCreator, newCreator(t, "Org2MSP", "admin", map[string]string{roleAttribute: adminRole})} {
+		if _, err := invokeAs(ledger, identity, "", "setConfig", `{"uniqueNames":true}`); err == nil || !strings.Contains(err.Error(), "permission denied") {
+			t.Fatalf("expected permission denied, got %v", err)
+		}
+	}
+	if _, err := invokeAs(ledger, root, "", "setConfig", `{"admin":"CN=mallory,O=Org1MSP"}`); err == nil || !strings.Contains(err.Error(), "grantRole") {
+		t.Fatalf("expected admin rejected, got %v", err)
+	}
+	if _, err := invokeAs(ledger, root, "", "setConfig", `{"uniqueNames":true}`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := invokeAs(ledger, testCreator, "", "addUser", `{"id":"3","name":"lzb1","sex":"男"}`); err == nil || !strings.Contains(err.Error(), "name") {
+		t.Fatalf("expected unique names, got %v", err)
+	}
+}
+
+func TestUser_InitKeepsExistingUsers(t *testing.T) {
+	stub := GetNewStub()
+	newUserInfo, _ := json.Marshal(UserInfoTest{Id: id_1, Name: "changed", Sex: sex_1})
+	res := stub.MockInvoke("1", [][]byte{[]byte("alterUser"), newUserInfo})
+	if res.Status != shim.OK {
+		t.Fatalf("alterUser: %s", res.Message)
+	}
+
+	res = stub.MockInit("upgrade", [][]byte{[]byte("init")})
+	if res.Status != shim.OK {
+		t.Fatalf("re-init: %s", res.Message)
+	}
+
+	res = stub.MockInvoke("2", [][]byte{[]byte("queryOnceUser"), user_1})
+	var got UserInfoTest
+	_ = json.Unmarshal(res.Payload, &got)
+	if got.Name != "changed" {
+		t.Fatalf("re-init overwrote user %s: %+v", id_1, got)
+	}
+}
+
+func TestUser_InitLegacyLedger(t *testing.T) {
+	stub := NewStub("legacy")
+	stub.MockTransactionStart("legacy")
+	putLegacyUser(t, stub, UserInfo{Id: id1, Name: name1, Sex: sex1})
+	stub.MockTransactionEnd("legacy")
+
+	res := stub.MockInit("init", [][]byte{[]byte("init")})
+	if res.Status != shim.OK {
+		t.Fatalf("init: %s", res.Message)
+	}
+	res = stub.MockInvoke("1", [][]byte{[]byte("queryOnceUser"), user_1})
+	if res.Status == shim.OK {
+		t.Fatalf("seed user written into legacy ledger: %s", res.Payload)
+	}
+}
+
+func TestUpgradeLedger_RunsMigrations(t *testing.T) {
+	stub := GetNewStub()
+	var ran []int
+	steps := []migration{
+		{From: 2, Name: "second", Run: func(stub shim.ChaincodeStubInterface) error { ran = append(ran, 2); return nil }},
+		{From: 1, Name: "first", Run: func(stub shim.ChaincodeStubInterface) error { ran = append(ran, 1); return nil }},
+		{From: 3, Name: "future", Run: func(stub shim.ChaincodeStubInterface) error { ran = append(ran, 3); return nil }},
+	}
+
+	stub.MockTransactionStart("upgrade")
+	defer stub.MockTransactionEnd("upgrade")
+	_ = putMeta(stub, ChaincodeMeta{Version: 1})
+	if _, err := upgradeLedger(stub, 3, steps); err != nil {
+		t.Fatalf("upgrade: %s", err)
+	}
+	if len(ran) != 2 || ran[0] != 1 || ran[1] != 2 {
+		t.Fatalf("migrations ran = %v, want [1 2]", ran)
+	}
+	meta, _, _ := getMeta(stub)
+	if meta.Version != 3 {
+		t.Fatalf("version = %d, want 3", meta.Version)
+	}
+	if _, err := upgradeLedger(stub, 2, steps); err == nil {
+		t.Fatal("downgrade should fail")
+	}
+}
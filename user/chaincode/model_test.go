@@ -0,0 +1,357 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/lzb13612/Example-Chaincode/internal/mockledger"
+)
+
+// 模型测试使用的用户名索引键区间
+var (
+	nameKeyStart = "\x00" + nameIndex + "\x00"
+	nameKeyEnd   = nameKeyStart + string(utf8.MaxRune)
+)
+
+// opKind 操作类型
+type opKind int
+
+const (
+	opAdd opKind = iota
+	opAlter
+	opDel
+	opQueryOnce
+	opQueryAll
+	opQueryByName
+	opKinds
+)
+
+// operation 一次随机生成的操作
+type operation struct {
+	kind opKind
+	user UserInfo
+}
+
+// String 便于在失败信息中复现操作序列
+func (op operation) String() string {
+	names := []string{"add", "alter", "del", "queryOnce", "queryAll", "queryByName"}
+	return fmt.Sprintf("%s(%q, %q, %q)", names[op.kind], op.user.Id, op.user.Name, op.user.Sex)
+}
+
+// model 用户链码的参考模型 -> 只保存 id 到用户的映射
+type model struct {
+	users map[string]UserInfo
+}
+
+// newModel 创建包含种子用户的模型
+func newModel() *model {
+	m := &model{users: make(map[string]UserInfo)}
+	for _, user := range seedUsers {
+		m.users[user.Id] = user
+	}
+	return m
+}
+
+// validAttribute 复合键属性必须是合法 UTF-8,且不能包含 U+0000 与 U+10FFFF
+func validAttribute(value string) bool {
+	return utf8.ValidString(value) && !strings.ContainsRune(value, 0) && !strings.ContainsRune(value, utf8.MaxRune)
+}
+
+// apply 在模型上执行操作,返回操作是否应当成功
+func (m *model) apply(op operation) bool {
+	user := op.user
+	old, exists := m.users[user.Id]
+	// 按 id 读写的操作需要先用 id 创建复合键
+	if op.kind != opQueryAll && op.kind != opQueryByName && !validAttribute(user.Id) {
+		return false
+	}
+	switch op.kind {
+	case opAdd:
+		if exists || user.Id == "" || strings.Contains(user.Id, "::") || (user.Name != "" && !validAttribute(user.Name)) {
+			return false
+		}
+		m.users[user.Id] = UserInfo{Id: user.Id, Name: user.Name, Sex: user.Sex}
+	case opAlter:
+		if !exists || (user.Name != old.Name && user.Name != "" && !validAttribute(user.Name)) {
+			return false
+		}
+		m.users[user.Id] = UserInfo{Id: user.Id, Name: user.Name, Sex: user.Sex}
+	case opDel:
+		delete(m.users, user.Id)
+	case opQueryOnce:
+		return exists
+	case opQueryByName:
+		return user.Name == "" || validAttribute(user.Name)
+	}
+	return true
+}
+
+// sorted 按 queryAllUser 约定的顺序返回模型中的用户
+func (m *model) sorted() []UserInfo {
+	users := make([]UserInfo, 0, len(m.users))
+	for _, user := range m.users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return idLess(users[i].Id, users[j].Id) })
+	return users
+}
+
+// canonicalNumber 不超过 99 位且没有前导零的十进制数
+var canonicalNumber = regexp.MustCompile(`^(0|[1-9][0-9]{0,98})$`)
+
+// idLess 数值id按数值排在前面,其余id按字典序排在之后
+func idLess(a, b string) bool {
+	numA, numB := canonicalNumber.MatchString(a), canonicalNumber.MatchString(b)
+	switch {
+	case numA && numB:
+		return len(a) < len(b) || (len(a) == len(b) && a < b)
+	case numA != numB:
+		return numA
+	default:
+		return a < b
+	}
+}
+
+// harness 同时驱动链码与参考模型,并在每一步之后检查不变量
+type harness struct {
+	t        *testing.T
+	ledger   *mockledger.Ledger
+	model    *model
+	versions map[string]mockledger.Version // 每个键最近一次看到的版本
+	history  []operation
+}
+
+// newHarness 创建新的账本与模型
+func newHarness(t *testing.T) *harness {
+	h := &harness{t: t, ledger: NewLedger(t, ""), model: newModel(), versions: make(map[string]mockledger.Version)}
+	h.checkInvariants()
+	return h
+}
+
+// fatalf 报告失败并附上复现用的操作序列
+func (h *harness) fatalf(format string, args ...interface{}) {
+	h.t.Helper()
+	h.t.Fatalf("%s\noperations:\n%v", fmt.Sprintf(format, args...), h.history)
+}
+
+// run 执行一次操作,比较链码与模型的结果,并检查不变量
+func (h *harness) run(op operation) {
+	h.t.Helper()
+	h.history = append(h.history, op)
+	// 参数与客户端一样经过 JSON 序列化,非法 UTF-8 会被替换为 U+FFFD
+	arg, _ := json.Marshal(op.user)
+	_ = json.Unmarshal(arg, &op.user)
+
+	functions := map[opKind]string{
+		opAdd: "addUser", opAlter: "alterUser", opDel: "delUser",
+		opQueryOnce: "queryOnceUser", opQueryAll: "queryAllUser", opQueryByName: "queryUserByName",
+	}
+	args := []string{string(arg)}
+	if op.kind == opQueryAll {
+		args = nil
+	}
+	res := h.ledger.Invoke(functions[op.kind], args, nil)
+	expected := h.model.apply(op)
+	if (res.Status == shim.OK) != expected {
+		h.fatalf("%v: expected success %v, got status %d (%s)", op, expected, res.Status, res.Message)
+	}
+	if res.Status == shim.OK {
+		h.checkPayload(op, res.Payload)
+	}
+	h.checkInvariants()
+}
+
+// checkPayload 比较查询结果与模型
+func (h *harness) checkPayload(op operation, payload []byte) {
+	h.t.Helper()
+	switch op.kind {
+	case opAdd:
+		var added UserId
+		if err := json.Unmarshal(payload, &added); err != nil || added.Id != op.user.Id {
+			h.fatalf("%v: expected id %q, got %s", op, op.user.Id, payload)
+		}
+	case opQueryOnce:
+		var user UserInfo
+		if err := json.Unmarshal(payload, &user); err != nil || user != h.model.users[op.user.Id] {
+			h.fatalf("%v: expected %+v, got %s", op, h.model.users[op.user.Id], payload)
+		}
+	case opQueryByName:
+		var users []UserInfo
+		if err := json.Unmarshal(payload, &users); err != nil {
+			h.fatalf("%v: unmarshal %s error:%s", op, payload, err)
+		}
+		// 空用户名不建立索引,按空用户名查询总是返回空列表
+		count := 0
+		for _, user := range h.model.users {
+			if user.Name == op.user.Name && user.Name != "" {
+				count++
+			}
+		}
+		if len(users) != count {
+			h.fatalf("%v: expected %d users, got %s", op, count, payload)
+		}
+		for _, user := range users {
+			if user.Name != op.user.Name || user != h.model.users[user.Id] {
+				h.fatalf("%v: unexpected user %+v", op, user)
+			}
+		}
+	}
+}
+
+// checkInvariants 检查账本不变量
+func (h *harness) checkInvariants() {
+	h.t.Helper()
+	// queryAllUser 与模型中的用户完全一致
+	res := h.ledger.Invoke("queryAllUser", nil, nil)
+	var users []UserInfo
+	if res.Status != shim.OK || json.Unmarshal(res.Payload, &users) != nil {
+		h.fatalf("queryAllUser failed: %s %s", res.Payload, res.Message)
+	}
+	expected := h.model.sorted()
+	if len(users) != len(expected) {
+		h.fatalf("queryAllUser returned %d users, model has %d", len(users), len(expected))
+	}
+	for i := range users {
+		if users[i] != expected[i] {
+			h.fatalf("queryAllUser[%d] = %+v, model has %+v", i, users[i], expected[i])
+		}
+	}
+
+	// 每个用户名索引都指向存在且同名的用户,每个有用户名的用户恰好有一个索引
+	indexed := make(map[string]int)
+	for _, kv := range h.ledger.Range(nameKeyStart, nameKeyEnd) {
+		parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(kv.Key, nameKeyStart), "\x00"), "\x00")
+		if len(parts) != 3 || parts[0] != testTenant {
+			h.fatalf("malformed name index key %q", kv.Key)
+		}
+		user, ok := h.model.users[parts[2]]
+		if !ok || user.Name != parts[1] {
+			h.fatalf("name index %q points to missing or renamed user %+v", kv.Key, user)
+		}
+		indexed[parts[2]]++
+	}
+	for id, user := range h.model.users {
+		if want := map[bool]int{true: 0, false: 1}[user.Name == ""]; indexed[id] != want {
+			h.fatalf("user %q has %d name index entries, expected %d", id, indexed[id], want)
+		}
+	}
+
+	// 版本只增不减,值发生变化时版本必须增加
+	seen := make(map[string]mockledger.Version)
+	for _, kv := range h.ledger.Range("", "") {
+		seen[kv.Key] = kv.Version
+		if previous, ok := h.versions[kv.Key]; ok && versionLess(kv.Version, previous) {
+			h.fatalf("version of %q went backwards: %+v -> %+v", kv.Key, previous, kv.Version)
+		}
+	}
+	for key, version := range h.versions {
+		// 删除后重新写入的键,版本也必须大于删除前
+		if _, ok := seen[key]; !ok {
+			seen[key] = version
+		}
+	}
+	h.versions = seen
+}
+
+// versionLess 比较版本先后
+func versionLess(a, b mockledger.Version) bool {
+	return a.BlockNum < b.BlockNum || (a.BlockNum == b.BlockNum && a.TxNum < b.TxNum)
+}
+
+// 随机生成时使用的 id、用户名与性别,包含非法 UTF-8、复合键分隔符与身份绑定前缀
+var (
+	fuzzIds   = []string{"1", "2", "3", "4", "", "a\x00b", "\xff", "Org1MSP::x", string(utf8.MaxRune), "用户"}
+	fuzzNames = []string{"lzb1", "lzb2", "x", "", "a\x00b", "\xfe\xff", string(utf8.MaxRune), "名字", "~"}
+	fuzzSexes = []string{"男", "女", "", "\xff"}
+)
+
+// randomOperation 从候选值中随机生成一次操作
+func randomOperation(r *rand.Rand) operation {
+	return operation{
+		kind: opKind(r.Intn(int(opKinds))),
+		user: UserInfo{
+			Id:   fuzzIds[r.Intn(len(fuzzIds))],
+			Name: fuzzNames[r.Intn(len(fuzzNames))],
+			Sex:  fuzzSexes[r.Intn(len(fuzzSexes))],
+		},
+	}
+}
+
+func TestModel_RandomSequences(t *testing.T) {
+	steps := 200
+	if testing.Short() {
+		steps = 50
+	}
+	for seed := int64(1); seed <= 10; seed++ {
+		t.Run(fmt.Sprintf("seed_%d", seed), func(t *testing.T) {
+			r := rand.New(rand.NewSource(seed))
+			h := newHarness(t)
+			for i := 0; i < steps; i++ {
+				h.run(randomOperation(r))
+			}
+		})
+	}
+}
+
+// FuzzUserOperations 将输入字节解码为操作序列:每个操作占 4 个字节(类型、id、用户名、性别),
+// 候选值之外再加入模糊测试生成的 id 与用户名
+func FuzzUserOperations(f *testing.F) {
+	f.Add([]byte{0, 2, 2, 0, 1, 2, 3, 1, 3, 2, 0, 0, 4, 0, 0, 0}, "3", "x")
+	f.Add([]byte{0, 10, 10, 0, 1, 10, 11, 0, 5, 0, 10, 0}, "a\x00b", "\xff")
+	f.Add([]byte{0, 10, 11, 2, 0, 10, 11, 2, 2, 10, 0, 0}, "Org1MSP::x", "n\x00")
+	f.Add([]byte{0, 10, 11, 0, 1, 10, 0, 0, 5, 0, 11, 0}, "id~name", string(utf8.MaxRune))
+	f.Fuzz(func(t *testing.T, data []byte, id, name string) {
+		ids := append(append([]string(nil), fuzzIds...), id)
+		names := append(append([]string(nil), fuzzNames...), name)
+		h := newHarness(t)
+		for i := 0; i+4 <= len(data) && i < 4*50; i += 4 {
+			h.run(operation{
+				kind: opKind(int(data[i]) % int(opKinds)),
+				user: UserInfo{
+					Id:   ids[int(data[i+1])%len(ids)],
+					Name: names[int(data[i+2])%len(names)],
+					Sex:  fuzzSexes[int(data[i+3])%len(fuzzSexes)],
+				},
+			})
+		}
+	})
+}
+
+// FuzzAddUserJSON 以任意字符串作为 addUser 的 JSON 参数:链码不能崩溃,成功时写入的用户可以原样查询
+func FuzzAddUserJSON(f *testing.F) {
+	f.Add(`{"id":"3","name":"lzb3","sex":"男"}`)
+	f.Add(`{"id":"3","name":"lzb3"`)
+	f.Add(`{"id":"a\u0000b","name":"x"}`)
+	f.Add(`{"id":"3","name":"􏿿"}`)
+	f.Add("{\"id\":\"3\",\"name\":\"\xff\"}")
+	f.Add(`{"id":"1","name":"dup"}`)
+	f.Add(`[]`)
+	f.Add(`{"id":3}`)
+	f.Fuzz(func(t *testing.T, arg string) {
+		h := newHarness(t)
+		res := h.ledger.Invoke("addUser", []string{arg}, nil)
+		if res.Status != shim.OK {
+			h.checkInvariants()
+			return
+		}
+		var user UserInfo
+		if err := json.Unmarshal([]byte(arg), &user); err != nil {
+			t.Fatalf("addUser accepted malformed JSON %q", arg)
+		}
+		h.model.users[user.Id] = UserInfo{Id: user.Id, Name: user.Name, Sex: user.Sex}
+		h.checkInvariants()
+		query, _ := json.Marshal(UserInfo{Id: user.Id})
+		res = h.ledger.Invoke("queryOnceUser", []string{string(query)}, nil)
+		var stored UserInfo
+		if res.Status != shim.OK || json.Unmarshal(res.Payload, &stored) != nil || stored != h.model.users[user.Id] {
+			t.Fatalf("queryOnceUser after addUser %q returned %s %s", arg, res.Payload, res.Message)
+		}
+	})
+}
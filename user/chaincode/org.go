@@ -0,0 +1,539 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/lzb13612/Example-Chaincode/internal/iterate"
+)
+
+// 组织的复合键类型
+const (
+	orgKey       = "org"      // 组织 -> org[租户, 组织id]
+	orgUserIndex = "org~user" // 组织的用户 -> org~user[租户, 组织id, 用户id]
+	orgMoveKey   = "orgmove"  // 用户调动记录 -> orgmove[租户, 用户id, 交易时间(纳秒), 交易ID]
+)
+
+// Organization 组织
+type Organization struct {
+	Id      string `json:"id"`                                     // 组织id
+	Name    string `json:"name"`                                   // 组织名称
+	MspId   string `json:"mspId"`                                  // 组织的 MSP ID
+	Contact string `json:"contact,omitempty" metadata:",optional"` // 联系方式
+}
+
+// OrgPage 组织分页查询结果
+type OrgPage struct {
+	Orgs     []*Organization `json:"orgs"`     // 本页组织
+	Bookmark string          `json:"bookmark"` // 下一页的书签,为空表示没有更多数据
+	Count    int32           `json:"count"`    // 本页组织数
+}
+
+// OrgMove 用户调动记录
+type OrgMove struct {
+	UserId string `json:"userId"`                              // 用户id
+	From   string `json:"from,omitempty" metadata:",optional"` // 原组织id,为空表示调动前不属于任何组织
+	To     string `json:"to"`                                  // 新组织id
+	Reason string `json:"reason"`                              // 调动原因
+	By     string `json:"by"`                                  // 执行调动者的用户id
+	At     string `json:"at"`                                  // 交易时间(RFC 3339)
+	TxId   string `json:"txId"`                                // 交易ID
+}
+
+// checkOrganization 校验组织
+func checkOrganization(org Organization) error {
+	if !validEntityId(org.Id) {
+		return fmt.Errorf("invalid organization id %q", org.Id)
+	}
+	if org.Name == "" {
+		return errors.New("organization name is required")
+	}
+	if err := checkTenant(org.MspId); err != nil {
+		return fmt.Errorf("organization msp id error:%s", err)
+	}
+	return nil
+}
+
+// getOrg 读取租户内的组织,不存在时 found 为 false
+func getOrg(stub shim.ChaincodeStubInterface, tenant, id string) (org Organization, found bool, err error) {
+	key, err := stub.CreateCompositeKey(orgKey, []string{tenant, id})
+	if err != nil {
+		return org, false, fmt.Errorf("create organization key error:%s", err)
+	}
+	orgBytes, err := stub.GetState(key)
+	if err != nil {
+		return org, false, fmt.Errorf("get organization state error:%s", err)
+	}
+	if len(orgBytes) == 0 {
+		return org, false, nil
+	}
+	if err := json.Unmarshal(orgBytes, &org); err != nil {
+		return org, false, fmt.Errorf("unmarshal organization error:%s", err)
+	}
+	return org, true, nil
+}
+
+// putOrg 写入租户内的组织
+func putOrg(stub shim.ChaincodeStubInterface, tenant string, org Organization) error {
+	key, err := stub.CreateCompositeKey(orgKey, []string{tenant, org.Id})
+	if err != nil {
+		return fmt.Errorf("create organization key error:%s", err)
+	}
+	orgBytes, err := json.Marshal(org)
+	if err != nil {
+		return fmt.Errorf("marshal organization error:%s", err)
+	}
+	if err := stub.PutState(key, orgBytes); err != nil {
+		return fmt.Errorf("put organization state error:%s", err)
+	}
+	return nil
+}
+
+// checkOrgRef
+// @title		checkOrgRef -> 校验用户引用的组织
+// @description	链码配置 requireOrg 时必须指定组织,指定的组织必须已登记。
+// @description	全新账本默认开启 requireOrg;升级的旧账本中用户都没有组织,默认要求组织会让已有用户无法修改,因此保持关闭,由部署组织的管理员通过 SetConfig 开启。
+// @description	开启后只约束之后的写入,已有的无组织用户由租户管理员通过 AssignOrg 批量或 MoveUser 逐个分配组织;分配前修改这些用户必须同时指定组织。
+// @auth		lzb
+// @param 		stub	shim库			"包含所有链码API的库"
+// @param		tenant	字符串			"租户"
+// @param		config	ChaincodeConfig	"链码配置"
+// @param		orgId	字符串			"用户引用的组织id"
+// @return		err		错误				"未指定组织或组织不存在"
+func checkOrgRef(stub shim.ChaincodeStubInterface, tenant string, config ChaincodeConfig, orgId string) error {
+	if orgId == "" {
+		if config.RequireOrg {
+			return errors.New("organization is required")
+		}
+		return nil
+	}
+	_, found, err := getOrg(stub, tenant, orgId)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("organization %s does not exist", orgId)
+	}
+	return nil
+}
+
+// setOrgIndex 将用户在 org~user 索引中从原组织移到新组织,组织id为空表示没有对应的索引
+func setOrgIndex(stub shim.ChaincodeStubInterface, tenant, userId, from, to string) error {
+	if from == to {
+		return nil
+	}
+	if from != "" {
+		key, err := stub.CreateCompositeKey(orgUserIndex, []string{tenant, from, userId})
+		if err != nil {
+			return fmt.Errorf("create organization index key error:%s", err)
+		}
+		if err := stub.DelState(key); err != nil {
+			return fmt.Errorf("del organization index error:%s", err)
+		}
+	}
+	if to != "" {
+		key, err := stub.CreateCompositeKey(orgUserIndex, []string{tenant, to, userId})
+		if err != nil {
+			return fmt.Errorf("create organization index key error:%s", err)
+		}
+		// 空值等同于删除,因此写入一个空字符
+		if err := stub.PutState(key, []byte{0x00}); err != nil {
+			return fmt.Errorf("put organization index error:%s", err)
+		}
+	}
+	return nil
+}
+
+// CreateOrg
+// @title		CreateOrg -> 登记组织
+// @description	在本租户中登记组织,需要本租户的管理员角色。
+// @auth		lzb
+// @param 		ctx		交易上下文		"包含所有链码API的库"
+// @param		org		Organization	"组织"
+// @return		err		错误				"登记失败的原因"
+func (e *UserContract) CreateOrg(ctx contractapi.TransactionContextInterface, org Organization) error {
+	tenant, err := adminScope(ctx)
+	if err != nil {
+		return fmt.Errorf("create organization error:%s", err)
+	}
+	if err := checkOrganization(org); err != nil {
+		return fmt.Errorf("create organization error:%s", err)
+	}
+	_, found, err := getOrg(ctx.GetStub(), tenant, org.Id)
+	if err != nil {
+		return err
+	}
+	if found {
+		return errors.New("organization exist")
+	}
+	return putOrg(ctx.GetStub(), tenant, org)
+}
+
+// QueryOrg
+// @title		QueryOrg -> 查询组织
+// @description	根据组织id查询本租户的组织。
+// @auth		lzb
+// @param 		ctx		交易上下文		"包含所有链码API的库"
+// @param		id		字符串			"组织id"
+// @return		org		*Organization	"组织"
+func (e *UserContract) QueryOrg(ctx contractapi.TransactionContextInterface, id string) (*Organization, error) {
+	tenant, err := resolveTenant(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	org, found, err := getOrg(ctx.GetStub(), tenant, id)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("organization %s does not exist", id)
+	}
+	return &org, nil
+}
+
+// UpdateOrg
+// @title		UpdateOrg -> 修改组织
+// @description	修改组织的名称、MSP ID 与联系方式,需要本租户的管理员角色;组织不存在时返回错误。
+// @auth		lzb
+// @param 		ctx		交易上下文		"包含所有链码API的库"
+// @param		org		Organization	"新的组织信息"
+// @return		err		错误				"修改失败的原因"
+func (e *UserContract) UpdateOrg(ctx contractapi.TransactionContextInterface, org Organization) error {
+	tenant, err := adminScope(ctx)
+	if err != nil {
+		return fmt.Errorf("update organization error:%s", err)
+	}
+	if err := checkOrganization(org); err != nil {
+		return fmt.Errorf("update organization error:%s", err)
+	}
+	_, found, err := getOrg(ctx.GetStub(), tenant, org.Id)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("organization %s does not exist", org.Id)
+	}
+	return putOrg(ctx.GetStub(), tenant, org)
+}
+
+// DeleteOrg
+// @title		DeleteOrg -> 删除组织
+// @description	删除没有用户的组织,需要本租户的管理员角色;组织不存在时返回错误,组织中还有用户时返回错误,需要先调走或删除这些用户。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @param		id		字符串		"组织id"
+// @return		err		错误			"删除失败的原因"
+func (e *UserContract) DeleteOrg(ctx contractapi.TransactionContextInterface, id string) error {
+	stub := ctx.GetStub()
+	tenant, err := adminScope(ctx)
+	if err != nil {
+		return fmt.Errorf("delete organization error:%s", err)
+	}
+	_, found, err := getOrg(stub, tenant, id)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("delete organization error:organization %s does not exist", id)
+	}
+	// 分页查询之后不能写入,这里只检查是否存在第一条索引
+	resultIterator, err := stub.GetStateByPartialCompositeKey(orgUserIndex, []string{tenant, id})
+	if err != nil {
+		return fmt.Errorf("get organization users by partial composite key error:%s", err)
+	}
+	hasUsers := resultIterator.HasNext()
+	if err := resultIterator.Close(); err != nil {
+		return fmt.Errorf("close organization users iterator error:%s", err)
+	}
+	if hasUsers {
+		return fmt.Errorf("delete organization error:organization %s still has users", id)
+	}
+	key, err := stub.CreateCompositeKey(orgKey, []string{tenant, id})
+	if err != nil {
+		return fmt.Errorf("create organization key error:%s", err)
+	}
+	if err := stub.DelState(key); err != nil {
+		return fmt.Errorf("del organization error:%s", err)
+	}
+	return nil
+}
+
+// ListOrgs
+// @title		ListOrgs -> 分页查询组织
+// @description	按组织id分页查询本租户的组织。
+// @auth		lzb
+// @param 		ctx			交易上下文	"包含所有链码API的库"
+// @param		pageSize	整型			"每页组织数"
+// @param		bookmark	字符串		"上一页返回的书签,第一页为空"
+// @return		page		*OrgPage	"本页组织与下一页书签"
+func (e *UserContract) ListOrgs(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*OrgPage, error) {
+	stub := ctx.GetStub()
+	tenant, err := resolveTenant(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("page size %d must be positive", pageSize)
+	}
+	prefix, err := stub.CreateCompositeKey(orgKey, []string{tenant})
+	if err != nil {
+		return nil, fmt.Errorf("create organization key error:%s", err)
+	}
+	if bookmark != "" && !strings.HasPrefix(bookmark, prefix) {
+		return nil, fmt.Errorf("bookmark %q is outside the queried range", bookmark)
+	}
+	resultIterator, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination(orgKey, []string{tenant}, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("get organizations by partial composite key error:%s", err)
+	}
+	orgs, err := iterate.Collect[Organization](resultIterator, int(pageSize))
+	if err != nil {
+		return nil, fmt.Errorf("list organizations error:%s", err)
+	}
+	return &OrgPage{Orgs: orgs, Bookmark: metadata.GetBookmark(), Count: int32(len(orgs))}, nil
+}
+
+// QueryOrgUsers
+// @title		QueryOrgUsers -> 分页查询组织的用户
+// @description	按用户id分页查询属于该组织的用户,用户按读取策略处理;看不到组织字段的调用者不能借此得知用户所属的组织,查不到任何用户。
+// @auth		lzb
+// @param 		ctx			交易上下文	"包含所有链码API的库"
+// @param		orgId		字符串		"组织id"
+// @param		pageSize	整型			"每页扫描的用户数,过滤后本页用户可能更少"
+// @param		bookmark	字符串		"上一页返回的书签,第一页为空"
+// @return		page		*UserPage	"本页用户与下一页书签"
+func (e *UserContract) QueryOrgUsers(ctx contractapi.TransactionContextInterface, orgId string, pageSize int32, bookmark string) (*UserPage, error) {
+	stub := ctx.GetStub()
+	tenant, v, err := readScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	userIds, next, err := indexPage(stub, orgUserIndex, tenant, orgId, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	encKey, err := getEncryptionKey(stub)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]*UserInfo, 0, len(userIds))
+	for _, userId := range userIds {
+		user, found, err := getUser(stub, tenant, userId)
+		if err != nil {
+			return nil, err
+		}
+		if !found || !containsField(v.fields(tenant, &user), fieldOrg) {
+			continue
+		}
+		if encKey != nil {
+			if err := decryptUser(encKey, &user); err != nil {
+				return nil, fmt.Errorf("decrypt user error:%s", err)
+			}
+		}
+		if v.redact(tenant, &user) {
+			users = append(users, &user)
+		}
+	}
+	return &UserPage{Users: users, Bookmark: next, Count: int32(len(users))}, nil
+}
+
+// MoveUser
+// @title		MoveUser -> 调动用户
+// @description	将用户调到本租户的另一个组织,并记录调动原因、执行者与交易时间,需要本租户的管理员角色;已关闭的用户不能调动。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @param		id		字符串		"用户id"
+// @param		orgId	字符串		"新组织id"
+// @param		reason	字符串		"调动原因"
+// @return		err		错误			"调动失败的原因"
+func (e *UserContract) MoveUser(ctx contractapi.TransactionContextInterface, id string, orgId string, reason string) error {
+	stub := ctx.GetStub()
+	tenant, err := adminScope(ctx)
+	if err != nil {
+		return fmt.Errorf("move user error:%s", err)
+	}
+	if reason == "" {
+		return errors.New("move user error:reason is required")
+	}
+	if len(reason) > maxStatusReason {
+		return fmt.Errorf("move user error:reason is longer than %d bytes", maxStatusReason)
+	}
+	user, found, err := getUser(stub, tenant, id)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("user %s does not exist", id)
+	}
+	if err := checkNotClosed(&user); err != nil {
+		return fmt.Errorf("move user error:%s", err)
+	}
+	if orgId == "" {
+		return errors.New("move user error:organization is required")
+	}
+	if user.OrgId == orgId {
+		return fmt.Errorf("move user error:user %s already belongs to %s", id, orgId)
+	}
+	if _, found, err := getOrg(stub, tenant, orgId); err != nil {
+		return err
+	} else if !found {
+		return fmt.Errorf("move user error:organization %s does not exist", orgId)
+	}
+	by, err := callerUserId(ctx)
+	if err != nil {
+		return err
+	}
+	if err := setOrgIndex(stub, tenant, id, user.OrgId, orgId); err != nil {
+		return err
+	}
+	move := OrgMove{UserId: id, From: user.OrgId, To: orgId, Reason: reason, By: by, TxId: stub.GetTxID()}
+	// 组织id不属于加密字段,直接修改账本中的记录
+	user.OrgId = orgId
+	if err := putUser(stub, tenant, user); err != nil {
+		return err
+	}
+	return putOrgMove(stub, tenant, move)
+}
+
+// OrgAssignment 批量分配组织的结果
+type OrgAssignment struct {
+	Users    []string `json:"users"`    // 本次分配到组织的用户id
+	Bookmark string   `json:"bookmark"` // 继续分配时传入的书签,为空表示已扫描完本租户的用户
+}
+
+// AssignOrg
+// @title		AssignOrg -> 为无组织的用户分配组织
+// @description	开启 requireOrg 前已有用户的迁移方式:按用户id顺序扫描本租户最多 limit 个用户,将其中不属于任何组织且未关闭的用户分配到组织,
+// @description	每个用户与 MoveUser 一样记录调动;需要本租户的管理员角色。扫描未结束时返回书签,以书签再次调用继续分配。
+// @auth		lzb
+// @param 		ctx			交易上下文		"包含所有链码API的库"
+// @param		orgId		字符串			"组织id"
+// @param		reason		字符串			"分配原因"
+// @param		limit		整型				"本次最多扫描的用户数"
+// @param		bookmark	字符串			"上次返回的书签,第一次为空"
+// @return		result		*OrgAssignment	"分配的用户与书签"
+func (e *UserContract) AssignOrg(ctx contractapi.TransactionContextInterface, orgId string, reason string, limit int32, bookmark string) (*OrgAssignment, error) {
+	stub := ctx.GetStub()
+	tenant, err := adminScope(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("assign organization error:%s", err)
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("assign organization error:limit %d must be positive", limit)
+	}
+	if reason == "" {
+		return nil, errors.New("assign organization error:reason is required")
+	}
+	if len(reason) > maxStatusReason {
+		return nil, fmt.Errorf("assign organization error:reason is longer than %d bytes", maxStatusReason)
+	}
+	if _, found, err := getOrg(stub, tenant, orgId); err != nil {
+		return nil, err
+	} else if !found {
+		return nil, fmt.Errorf("assign organization error:organization %s does not exist", orgId)
+	}
+	startKey, endKey := tenantUserRange(tenant)
+	if bookmark != "" {
+		if bookmark < startKey || bookmark >= endKey {
+			return nil, fmt.Errorf("bookmark %q is outside the queried range", bookmark)
+		}
+		startKey = bookmark
+	}
+	resultIterator, err := stub.GetStateByRange(startKey, endKey)
+	if err != nil {
+		return nil, fmt.Errorf("get user info by range error:%s", err)
+	}
+	// 先读出需要分配的用户,再统一写入,避免边遍历边修改
+	result := &OrgAssignment{Users: make([]string, 0)}
+	users := make([]*UserInfo, 0)
+	scanned := int32(0)
+	err = iterate.Decode(resultIterator, iterate.Unlimited, func(key string, user *UserInfo) error {
+		if scanned == limit {
+			result.Bookmark = key
+			return iterate.ErrStop
+		}
+		scanned++
+		if user.OrgId == "" && checkNotClosed(user) == nil {
+			users = append(users, user)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("user iterator error:%s", err)
+	}
+	by, err := callerUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		if err := setOrgIndex(stub, tenant, user.Id, "", orgId); err != nil {
+			return nil, err
+		}
+		// 组织id不属于加密字段,直接修改账本中的记录
+		user.OrgId = orgId
+		if err := putUser(stub, tenant, *user); err != nil {
+			return nil, err
+		}
+		move := OrgMove{UserId: user.Id, To: orgId, Reason: reason, By: by, TxId: stub.GetTxID()}
+		if err := putOrgMove(stub, tenant, move); err != nil {
+			return nil, err
+		}
+		result.Users = append(result.Users, user.Id)
+	}
+	return result, nil
+}
+
+// putOrgMove 写入调动记录,同一用户的记录按交易时间排序
+func putOrgMove(stub shim.ChaincodeStubInterface, tenant string, move OrgMove) error {
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("get tx timestamp error:%s", err)
+	}
+	if move.At, err = formatTimestamp(ts); err != nil {
+		return fmt.Errorf("tx timestamp error:%s", err)
+	}
+	key, err := stub.CreateCompositeKey(orgMoveKey, []string{tenant, move.UserId, fmt.Sprintf("%020d", ts.GetSeconds()*int64(time.Second)+int64(ts.GetNanos())), move.TxId})
+	if err != nil {
+		return fmt.Errorf("create organization move key error:%s", err)
+	}
+	moveBytes, err := json.Marshal(move)
+	if err != nil {
+		return fmt.Errorf("marshal organization move error:%s", err)
+	}
+	if err := stub.PutState(key, moveBytes); err != nil {
+		return fmt.Errorf("put organization move state error:%s", err)
+	}
+	return nil
+}
+
+// QueryUserMoves
+// @title		QueryUserMoves -> 查询用户的调动记录
+// @description	按交易时间从早到晚返回用户在本租户内的全部调动记录;删除用户不会删除调动记录。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @param		id		字符串		"用户id"
+// @return		moves	[]*OrgMove	"调动记录"
+func (e *UserContract) QueryUserMoves(ctx contractapi.TransactionContextInterface, id string) ([]*OrgMove, error) {
+	tenant, v, err := readScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// 调动记录包含用户所属的组织,只对能看到组织字段的调用者可见
+	if !containsField(v.tenantFields(tenant), fieldOrg) {
+		return nil, fmt.Errorf("user %s does not exist", id)
+	}
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(orgMoveKey, []string{tenant, id})
+	if err != nil {
+		return nil, fmt.Errorf("get organization moves by partial composite key error:%s", err)
+	}
+	moves, err := iterate.Collect[OrgMove](resultIterator, maxQueryResults)
+	if err != nil {
+		return nil, fmt.Errorf("organization move iterator error:%s", err)
+	}
+	return moves, nil
+}
@@ -0,0 +1,264 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/lzb13612/Example-Chaincode/internal/mockledger"
+)
+
+// orgLedger 创建要求用户属于组织的账本,登记组织 hq 与 branch,返回本租户管理员
+func orgLedger(t *testing.T) (*mockledger.Ledger, []byte) {
+	t.Helper()
+	ledger := NewLedger(t, `{"requireOrg":true}`)
+	admin := newCreator(t, "Org1MSP", "admin", map[string]string{roleAttribute: adminRole})
+	for _, org := range []string{`{"id":"hq","name":"总部","mspId":"Org1MSP","contact":"hq@example.com"}`, `{"id":"branch","name":"分部","mspId":"Org1MSP"}`} {
+		if _, err := invokeAs(ledger, admin, "", "createOrg", org); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ledger, admin
+}
+
+// orgUserIds 查询组织的用户id
+func orgUserIds(t *testing.T, ledger *mockledger.Ledger, identity []byte, tenant, orgId string) string {
+	t.Helper()
+	payload, err := invokeAs(ledger, identity, tenant, "queryOrgUsers", orgId, "10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var page UserPage
+	if err := json.Unmarshal(payload, &page); err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, len(page.Users))
+	for i, user := range page.Users {
+		ids[i] = user.Id
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestOrg_UserReference(t *testing.T) {
+	ledger, admin := orgLedger(t)
+	for _, c := range []struct {
+		identity []byte
+		function string
+		arg      string
+		err      string
+	}{
+		{testCreator, "createOrg", `{"id":"x","name":"x","mspId":"Org1MSP"}`, "permission denied"},
+		{admin, "createOrg", `{"id":"hq","name":"x","mspId":"Org1MSP"}`, "organization exist"},
+		{admin, "createOrg", `{"id":"x","name":"x","mspId":"bad msp"}`, "msp id error"},
+		{testCreator, "addUser", `{"id":"3","name":"three"}`, "organization is required"},
+		{testCreator, "addUser", `{"id":"3","name":"three","orgId":"x"}`, "organization x does not exist"},
+		{testCreator, "alterUser", `{"id":"1","name":"lzb1"}`, "organization is required"},
+		{testCreator, "registerSelf", `{"name":"self"}`, "organization is required"},
+	} {
+		if _, err := invokeAs(ledger, c.identity, "", c.function, c.arg); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Fatalf("%s %s: expected %q, got %v", c.function, c.arg, c.err, err)
+		}
+	}
+	for _, c := range [][]string{
+		{"addUser", `{"id":"3","name":"three","orgId":"hq"}`},
+		{"alterUser", `{"id":"1","name":"lzb1","orgId":"hq"}`},
+		// 未指定组织时保留原组织
+		{"alterUser", `{"id":"1","name":"renamed"}`},
+		{"registerSelf", `{"name":"self","orgId":"branch"}`},
+	} {
+		if _, err := invokeAs(ledger, testCreator, "", c[0], c[1]); err != nil {
+			t.Fatalf("%s %s: %v", c[0], c[1], err)
+		}
+	}
+	if _, err := invokeAs(ledger, testCreator, "", "alterUser", `{"id":"1","name":"renamed","orgId":"branch"}`); err == nil || !strings.Contains(err.Error(), "changed by moveUser") {
+		t.Fatalf("expected move required, got %v", err)
+	}
+	if ids := orgUserIds(t, ledger, testCreator, "", "hq"); ids != "1,3" {
+		t.Fatalf("unexpected hq users %s", ids)
+	}
+	if ids := orgUserIds(t, ledger, testCreator, "", "branch"); !strings.HasPrefix(ids, testTenant+"::") {
+		t.Fatalf("unexpected branch users %s", ids)
+	}
+}
+
+func TestOrg_MoveAndDelete(t *testing.T) {
+	ledger, admin := orgLedger(t)
+	for _, user := range []string{`{"id":"3","name":"three","orgId":"hq"}`, `{"id":"4","name":"four","orgId":"hq"}`} {
+		if _, err := invokeAs(ledger, testCreator, "", "addUser", user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := invokeAs(ledger, admin, "", "deleteOrg", `{"id":"hq"}`); err == nil || !strings.Contains(err.Error(), "still has users") {
+		t.Fatalf("expected org in use, got %v", err)
+	}
+	for _, c := range []struct {
+		identity []byte
+		args     []string
+		err      string
+	}{
+		{testCreator, []string{"3", "branch", "reorg"}, "permission denied"},
+		{admin, []string{"3", "branch", ""}, "reason is required"},
+		{admin, []string{"3", "x", "reorg"}, "organization x does not exist"},
+		{admin, []string{"3", "hq", "reorg"}, "already belongs to hq"},
+		{admin, []string{"9", "hq", "reorg"}, "user 9 does not exist"},
+	} {
+		if _, err := invokeAs(ledger, c.identity, "", "moveUser", c.args...); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Fatalf("%q: expected %q, got %v", c.args, c.err, err)
+		}
+	}
+	if _, err := invokeAs(ledger, admin, "", "moveUser", "3", "branch", "reorg"); err != nil {
+		t.Fatal(err)
+	}
+	if hq, branch := orgUserIds(t, ledger, testCreator, "", "hq"), orgUserIds(t, ledger, testCreator, "", "branch"); hq != "4" || branch != "3" {
+		t.Fatalf("unexpected org users hq=%s branch=%s", hq, branch)
+	}
+	payload, err := invokeAs(ledger, testCreator, "", "queryUserMoves", `{"id":"3"}`)
+	var moves []OrgMove
+	if err != nil || json.Unmarshal(payload, &moves) != nil || len(moves) != 1 || moves[0].From != "hq" || moves[0].To != "branch" || moves[0].Reason != "reorg" || moves[0].By == "" || moves[0].At == "" {
+		t.Fatalf("unexpected moves %s %v", payload, err)
+	}
+
+	// 删除最后一个用户后可以删除组织
+	if _, err := invokeAs(ledger, testCreator, "", "delUser", `{"id":"4"}`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := invokeAs(ledger, admin, "", "deleteOrg", `{"id":"hq"}`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := invokeAs(ledger, testCreator, "", "queryOrg", `{"id":"hq"}`); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("expected deleted org, got %v", err)
+	}
+	if _, err := invokeAs(ledger, admin, "", "deleteOrg", `{"id":"hq"}`); err == nil || !strings.Contains(err.Error(), "organization hq does not exist") {
+		t.Fatalf("expected deleted org, got %v", err)
+	}
+	payload, _ = invokeAs(ledger, testCreator, "", "listOrgs", "10")
+	if !strings.Contains(string(payload), `"id":"branch"`) || !strings.Contains(string(payload), `"count":1`) {
+		t.Fatalf("unexpected orgs %s", payload)
+	}
+}
+
+func TestOrg_HiddenFromOtherTenants(t *testing.T) {
+	ledger, _ := orgLedger(t)
+	if _, err := invokeAs(ledger, testCreator, "", "addUser", `{"id":"3","name":"three","orgId":"hq"}`); err != nil {
+		t.Fatal(err)
+	}
+	// 默认读取策略下其他组织的审计员看不到组织字段,不能借组织查询得知用户所属的组织
+	auditor := newAuditor(t, ledger)
+	if ids := orgUserIds(t, ledger, auditor, testTenant, "hq"); ids != "" {
+		t.Fatalf("auditor listed org users %s", ids)
+	}
+	if _, err := invokeAs(ledger, auditor, testTenant, "queryUserMoves", `{"id":"3"}`); err == nil {
+		t.Fatal("expected hidden moves")
+	}
+	if payload, _ := invokeAs(ledger, auditor, testTenant, "queryOnceUser", `{"id":"3"}`); strings.Contains(string(payload), "hq") {
+		t.Fatalf("auditor saw org %s", payload)
+	}
+}
+
+func TestOrg_RequireOrgDefault(t *testing.T) {
+	// 全新账本默认要求组织,配置中未指定 requireOrg 时同样开启
+	for _, initArgs := range [][][]byte{
+		{[]byte("init")},
+		{[]byte("init"), []byte(`{"uniqueNames":true}`)},
+	} {
+		stub := NewStub("fresh")
+		if res := stub.MockInit("init", initArgs); res.Status != shim.OK {
+			t.Fatalf("init: %s", res.Message)
+		}
+		if res := stub.MockInvoke("1", [][]byte{[]byte("addUser"), user1}); res.Status == shim.OK || !strings.Contains(res.Message, "organization is required") {
+			t.Fatalf("expected organization is required, got %d %s", res.Status, res.Message)
+		}
+	}
+
+	// 修改配置时未指定 requireOrg 保持当前设置,关闭必须显式指定
+	stub := NewStub("fresh")
+	stub.MockInit("init", [][]byte{[]byte("init")})
+	stub.Creator = newCreator(t, "Org1MSP", "admin", map[string]string{roleAttribute: adminRole})
+	for _, c := range []struct {
+		config string
+		status int32
+	}{
+		{`{"uniqueNames":true}`, shim.ERROR},
+		{`{"requireOrg":false}`, shim.OK},
+	} {
+		if res := stub.MockInvoke("config", [][]byte{[]byte("setConfig"), []byte(c.config)}); res.Status != shim.OK {
+			t.Fatalf("setConfig: %s", res.Message)
+		}
+		if res := stub.MockInvoke("add", [][]byte{[]byte("addUser"), user1}); res.Status != c.status {
+			t.Fatalf("%s: addUser status %d %s", c.config, res.Status, res.Message)
+		}
+	}
+
+	// 升级的旧账本保持关闭
+	stub = NewStub("legacy")
+	stub.MockTransactionStart("legacy")
+	putLegacyUser(t, stub, UserInfo{Id: id2, Name: name2, Sex: sex2})
+	stub.MockTransactionEnd("legacy")
+	if res := stub.MockInit("upgrade", [][]byte{[]byte("init")}); res.Status != shim.OK {
+		t.Fatalf("upgrade: %s", res.Message)
+	}
+	if res := stub.MockInvoke("1", [][]byte{[]byte("addUser"), user1}); res.Status != shim.OK {
+		t.Fatalf("addUser on upgraded ledger: %s", res.Message)
+	}
+}
+
+func TestOrg_AssignExistingUsers(t *testing.T) {
+	// 开启 requireOrg 前已有的用户 1、2 没有组织
+	ledger, admin := orgLedger(t)
+	if _, err := invokeAs(ledger, testCreator, "", "addUser", `{"id":"3","name":"three","orgId":"branch"}`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := invokeAs(ledger, admin, "", "closeUser", `{"id":"2","statusReason":"left"}`); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		identity []byte
+		args     []string
+		err      string
+	}{
+		{testCreator, []string{"hq", "migrate", "10"}, "permission denied"},
+		{admin, []string{"hq", "", "10"}, "reason is required"},
+		{admin, []string{"x", "migrate", "10"}, "organization x does not exist"},
+		{admin, []string{"hq", "migrate", "0"}, "must be positive"},
+		{admin, []string{"hq", "migrate", "10", "org:x"}, "outside the queried range"},
+	} {
+		if _, err := invokeAs(ledger, c.identity, "", "assignOrg", c.args...); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Fatalf("%q: expected %q, got %v", c.args, c.err, err)
+		}
+	}
+
+	// 每次最多扫描一个用户,按书签继续;已有组织与已关闭的用户不会被分配
+	var assigned []string
+	bookmark := ""
+	for calls := 0; ; calls++ {
+		if calls > 3 {
+			t.Fatal("assignOrg did not finish")
+		}
+		payload, err := invokeAs(ledger, admin, "", "assignOrg", "hq", "migrate", "1", bookmark)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result OrgAssignment
+		_ = json.Unmarshal(payload, &result)
+		assigned = append(assigned, result.Users...)
+		if bookmark = result.Bookmark; bookmark == "" {
+			break
+		}
+	}
+	if got := strings.Join(assigned, ","); got != "1" {
+		t.Fatalf("assigned users = %s, want 1", got)
+	}
+	if hq := orgUserIds(t, ledger, testCreator, "", "hq"); hq != "1" {
+		t.Fatalf("unexpected hq users %s", hq)
+	}
+	payload, err := invokeAs(ledger, testCreator, "", "queryUserMoves", `{"id":"1"}`)
+	var moves []OrgMove
+	if err != nil || json.Unmarshal(payload, &moves) != nil || len(moves) != 1 || moves[0].From != "" || moves[0].To != "hq" || moves[0].Reason != "migrate" {
+		t.Fatalf("unexpected moves %s %v", payload, err)
+	}
+	// 分配后修改用户不需要再指定组织
+	if _, err := invokeAs(ledger, testCreator, "", "alterUser", `{"id":"1","name":"renamed"}`); err != nil {
+		t.Fatal(err)
+	}
+}
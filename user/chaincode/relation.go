@@ -0,0 +1,474 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/lzb13612/Example-Chaincode/internal/iterate"
+)
+
+// 用户关系的复合键类型,关系是有类型的有向边,正向与反向各存一份,按起点或终点查询都是前缀查询
+const (
+	relationKey      = "rel"     // 关系 -> rel[租户, 类型, 起点用户id, 终点用户id]
+	relationRevIndex = "relrev"  // 反向索引 -> relrev[租户, 类型, 终点用户id, 起点用户id]
+	relationTypeKey  = "reltype" // 租户内出现过的关系类型 -> reltype[租户, 类型],删除用户时按类型清理关系
+)
+
+// 查询关系的方向
+const (
+	DirectionOut  = "out"  // 从该用户出发的关系
+	DirectionIn   = "in"   // 指向该用户的关系
+	DirectionBoth = "both" // 两个方向
+)
+
+// 关系查询的上限
+const (
+	maxRelationDepth   = 5   // 遍历的最大深度
+	maxRelationResults = 500 // 一次查询返回的最多关系或用户数
+)
+
+// relationPattern 关系类型只能包含小写字母、数字、'_'、'-',以字母开头,如 manager-of
+var relationPattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// Relation 用户之间的关系
+type Relation struct {
+	Type      string `json:"type"`      // 关系类型
+	From      string `json:"from"`      // 起点用户id
+	To        string `json:"to"`        // 终点用户id
+	CreatedBy string `json:"createdBy"` // 添加者的用户id
+	CreatedAt string `json:"createdAt"` // 添加的交易时间(RFC 3339)
+}
+
+// RelationResult 直接关系查询结果
+type RelationResult struct {
+	Relations []*Relation `json:"relations"` // 关系
+	Truncated bool        `json:"truncated"` // 是否因达到上限而没有返回全部关系
+}
+
+// TraversalNode 遍历到的用户
+type TraversalNode struct {
+	Id       string    `json:"id"`       // 用户id
+	Depth    int       `json:"depth"`    // 与起点用户的距离
+	Relation *Relation `json:"relation"` // 首次到达该用户经过的关系
+}
+
+// Traversal 关系遍历结果
+type Traversal struct {
+	Nodes     []*TraversalNode `json:"nodes"`     // 按距离从近到远排列的用户,不含起点
+	Truncated bool             `json:"truncated"` // 是否因达到上限而没有返回全部用户
+}
+
+// checkDirection 校验查询方向
+func checkDirection(direction string) error {
+	switch direction {
+	case DirectionOut, DirectionIn, DirectionBoth:
+		return nil
+	default:
+		return fmt.Errorf("invalid direction %q", direction)
+	}
+}
+
+// checkLimit 校验结果上限
+func checkLimit(limit int32) error {
+	if limit <= 0 || limit > maxRelationResults {
+		return fmt.Errorf("limit %d must be between 1 and %d", limit, maxRelationResults)
+	}
+	return nil
+}
+
+// neighbour 关系另一端的用户
+func (r *Relation) neighbour(id string) string {
+	if r.From == id {
+		return r.To
+	}
+	return r.From
+}
+
+// relationTypes 查询涉及的关系类型,relType 为空时为租户内出现过的全部类型
+func relationTypes(stub shim.ChaincodeStubInterface, tenant, relType string) ([]string, error) {
+	if relType != "" {
+		if !relationPattern.MatchString(relType) {
+			return nil, fmt.Errorf("invalid relation type %q", relType)
+		}
+		return []string{relType}, nil
+	}
+	resultIterator, err := stub.GetStateByPartialCompositeKey(relationTypeKey, []string{tenant})
+	if err != nil {
+		return nil, fmt.Errorf("get relation types by partial composite key error:%s", err)
+	}
+	types := make([]string, 0)
+	err = iterate.States(resultIterator, maxQueryResults, func(kv *queryresult.KV) error {
+		_, attributes, err := stub.SplitCompositeKey(kv.Key)
+		if err != nil {
+			return fmt.Errorf("split relation type key error:%s", err)
+		}
+		types = append(types, attributes[1])
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("relation type iterator error:%s", err)
+	}
+	return types, nil
+}
+
+// eachRelation
+// @title		eachRelation -> 遍历用户的直接关系
+// @description	按类型依次读取从该用户出发(正向键)与指向该用户(反向索引)的关系,visit 返回 iterate.ErrStop 时结束全部遍历。
+// @description	每个类型的每个方向最多读取 maxQueryResults 个关系,超过时返回 *iterate.LimitError。
+// @auth		lzb
+// @param 		stub		shim库		"包含所有链码API的库"
+// @param		tenant		字符串		"租户"
+// @param		id			字符串		"用户id"
+// @param		types		字符串组		"关系类型"
+// @param		direction	字符串		"查询方向"
+// @param		visit		函数			"处理方法"
+// @return		err			错误			"查询失败的原因"
+func eachRelation(stub shim.ChaincodeStubInterface, tenant, id string, types []string, direction string, visit func(relation *Relation) error) error {
+	stopped := false
+	for _, relType := range types {
+		for _, objectType := range []string{relationKey, relationRevIndex} {
+			if (objectType == relationKey && direction == DirectionIn) || (objectType == relationRevIndex && direction == DirectionOut) {
+				continue
+			}
+			resultIterator, err := stub.GetStateByPartialCompositeKey(objectType, []string{tenant, relType, id})
+			if err != nil {
+				return fmt.Errorf("get relations by partial composite key error:%s", err)
+			}
+			err = iterate.Decode(resultIterator, maxQueryResults, func(key string, relation *Relation) error {
+				err := visit(relation)
+				if errors.Is(err, iterate.ErrStop) {
+					stopped = true
+				}
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("relation iterator error:%s", err)
+			}
+			if stopped {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// putRelation 写入关系的正向键与反向索引
+func putRelation(stub shim.ChaincodeStubInterface, tenant string, relation Relation) error {
+	relationBytes, err := json.Marshal(relation)
+	if err != nil {
+		return fmt.Errorf("marshal relation error:%s", err)
+	}
+	for objectType, attributes := range map[string][]string{
+		relationKey:      {tenant, relation.Type, relation.From, relation.To},
+		relationRevIndex: {tenant, relation.Type, relation.To, relation.From},
+	} {
+		key, err := stub.CreateCompositeKey(objectType, attributes)
+		if err != nil {
+			return fmt.Errorf("create %s key error:%s", objectType, err)
+		}
+		if err := stub.PutState(key, relationBytes); err != nil {
+			return fmt.Errorf("put %s error:%s", objectType, err)
+		}
+	}
+	return nil
+}
+
+// delRelation 删除关系的正向键与反向索引,removed 记录本交易删除的关系,用于 releaseRelationTypes
+func delRelation(stub shim.ChaincodeStubInterface, tenant, relType, from, to string, removed map[string]map[string]bool) error {
+	for objectType, attributes := range map[string][]string{
+		relationKey:      {tenant, relType, from, to},
+		relationRevIndex: {tenant, relType, to, from},
+	} {
+		key, err := stub.CreateCompositeKey(objectType, attributes)
+		if err != nil {
+			return fmt.Errorf("create %s key error:%s", objectType, err)
+		}
+		if err := stub.DelState(key); err != nil {
+			return fmt.Errorf("del %s error:%s", objectType, err)
+		}
+		if objectType == relationKey {
+			if removed[relType] == nil {
+				removed[relType] = make(map[string]bool)
+			}
+			removed[relType][key] = true
+		}
+	}
+	return nil
+}
+
+// releaseRelationTypes
+// @title		releaseRelationTypes -> 删除不再使用的关系类型
+// @description	本交易删除了某类型的最后一个关系时删除该类型的登记。查询读取的是已提交的状态,仍包含本交易删除的关系,
+// @description	因此跳过这些关系;最多读取删除数加一个关系即可判断是否还有其他关系。
+// @auth		lzb
+// @param 		stub	shim库	"包含所有链码API的库"
+// @param		tenant	字符串	"租户"
+// @param		removed	映射		"关系类型 -> 本交易删除的关系正向键"
+// @return		err		错误		"删除失败的原因"
+func releaseRelationTypes(stub shim.ChaincodeStubInterface, tenant string, removed map[string]map[string]bool) error {
+	for relType, keys := range removed {
+		resultIterator, err := stub.GetStateByPartialCompositeKey(relationKey, []string{tenant, relType})
+		if err != nil {
+			return fmt.Errorf("get relations by partial composite key error:%s", err)
+		}
+		inUse := false
+		err = iterate.States(resultIterator, len(keys)+1, func(kv *queryresult.KV) error {
+			if keys[kv.Key] {
+				return nil
+			}
+			inUse = true
+			return iterate.ErrStop
+		})
+		if err != nil {
+			return fmt.Errorf("relation iterator error:%s", err)
+		}
+		if inUse {
+			continue
+		}
+		typeKey, err := stub.CreateCompositeKey(relationTypeKey, []string{tenant, relType})
+		if err != nil {
+			return fmt.Errorf("create relation type key error:%s", err)
+		}
+		if err := stub.DelState(typeKey); err != nil {
+			return fmt.Errorf("del relation type error:%s", err)
+		}
+	}
+	return nil
+}
+
+// removeUserRelations 删除用户时移除从该用户出发与指向该用户的全部关系,并删除因此不再使用的关系类型
+func removeUserRelations(stub shim.ChaincodeStubInterface, tenant, userId string) error {
+	types, err := relationTypes(stub, tenant, "")
+	if err != nil {
+		return err
+	}
+	relations := make([]*Relation, 0)
+	err = eachRelation(stub, tenant, userId, types, DirectionBoth, func(relation *Relation) error {
+		relations = append(relations, relation)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	removed := make(map[string]map[string]bool)
+	for _, relation := range relations {
+		if err := delRelation(stub, tenant, relation.Type, relation.From, relation.To, removed); err != nil {
+			return err
+		}
+	}
+	return releaseRelationTypes(stub, tenant, removed)
+}
+
+// AddRelation
+// @title		AddRelation -> 添加用户关系
+// @description	添加从 from 指向 to 的某类型关系(如 manager-of),两个用户都必须存在,需要本租户的管理员角色。
+// @auth		lzb
+// @param 		ctx			交易上下文	"包含所有链码API的库"
+// @param		relType		字符串		"关系类型"
+// @param		from		字符串		"起点用户id"
+// @param		to			字符串		"终点用户id"
+// @return		err			错误			"添加失败的原因"
+func (e *UserContract) AddRelation(ctx contractapi.TransactionContextInterface, relType string, from string, to string) error {
+	stub := ctx.GetStub()
+	tenant, err := adminScope(ctx)
+	if err != nil {
+		return fmt.Errorf("add relation error:%s", err)
+	}
+	if !relationPattern.MatchString(relType) {
+		return fmt.Errorf("add relation error:invalid relation type %q", relType)
+	}
+	if from == to {
+		return errors.New("add relation error:user cannot relate to itself")
+	}
+	for _, id := range []string{from, to} {
+		if _, found, err := getUser(stub, tenant, id); err != nil {
+			return err
+		} else if !found {
+			return fmt.Errorf("user %s does not exist", id)
+		}
+	}
+	key, err := stub.CreateCompositeKey(relationKey, []string{tenant, relType, from, to})
+	if err != nil {
+		return fmt.Errorf("create relation key error:%s", err)
+	}
+	existing, err := stub.GetState(key)
+	if err != nil {
+		return fmt.Errorf("get relation state error:%s", err)
+	}
+	if len(existing) != 0 {
+		return errors.New("relation exist")
+	}
+	by, err := callerUserId(ctx)
+	if err != nil {
+		return err
+	}
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("get tx timestamp error:%s", err)
+	}
+	at, err := formatTimestamp(ts)
+	if err != nil {
+		return fmt.Errorf("tx timestamp error:%s", err)
+	}
+	typeKey, err := stub.CreateCompositeKey(relationTypeKey, []string{tenant, relType})
+	if err != nil {
+		return fmt.Errorf("create relation type key error:%s", err)
+	}
+	// 空值等同于删除,因此写入一个空字符
+	if err := stub.PutState(typeKey, []byte{0x00}); err != nil {
+		return fmt.Errorf("put relation type error:%s", err)
+	}
+	return putRelation(stub, tenant, Relation{Type: relType, From: from, To: to, CreatedBy: by, CreatedAt: at})
+}
+
+// RemoveRelation
+// @title		RemoveRelation -> 删除用户关系
+// @description	删除从 from 指向 to 的某类型关系,需要本租户的管理员角色;关系不存在时不做处理。删除该类型的最后一个关系时一并删除类型登记。
+// @auth		lzb
+// @param 		ctx			交易上下文	"包含所有链码API的库"
+// @param		relType		字符串		"关系类型"
+// @param		from		字符串		"起点用户id"
+// @param		to			字符串		"终点用户id"
+// @return		err			错误			"删除失败的原因"
+func (e *UserContract) RemoveRelation(ctx contractapi.TransactionContextInterface, relType string, from string, to string) error {
+	tenant, err := adminScope(ctx)
+	if err != nil {
+		return fmt.Errorf("remove relation error:%s", err)
+	}
+	removed := make(map[string]map[string]bool)
+	if err := delRelation(ctx.GetStub(), tenant, relType, from, to, removed); err != nil {
+		return err
+	}
+	return releaseRelationTypes(ctx.GetStub(), tenant, removed)
+}
+
+// visibleUser 判断调用者能否看到用户,不存在或不可见的用户都返回 false
+func visibleUser(stub shim.ChaincodeStubInterface, tenant string, v *viewer, id string) (bool, error) {
+	user, found, err := getUser(stub, tenant, id)
+	if err != nil || !found {
+		return false, err
+	}
+	return v.fields(tenant, &user) != nil, nil
+}
+
+// relationScope 校验关系查询的参数并确定租户、读取者与关系类型,起点用户不可见时视为不存在
+func relationScope(ctx contractapi.TransactionContextInterface, id, relType, direction string, limit int32) (string, *viewer, []string, error) {
+	if err := checkDirection(direction); err != nil {
+		return "", nil, nil, err
+	}
+	if err := checkLimit(limit); err != nil {
+		return "", nil, nil, err
+	}
+	tenant, v, err := readScope(ctx)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	types, err := relationTypes(ctx.GetStub(), tenant, relType)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	visible, err := visibleUser(ctx.GetStub(), tenant, v, id)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if !visible {
+		return "", nil, nil, fmt.Errorf("user %s does not exist", id)
+	}
+	return tenant, v, types, nil
+}
+
+// QueryRelations
+// @title		QueryRelations -> 查询直接关系
+// @description	查询用户某一方向的直接关系,relType 为空时查询全部类型;另一端用户对调用者不可见的关系不返回。
+// @auth		lzb
+// @param 		ctx			交易上下文		"包含所有链码API的库"
+// @param		id			字符串			"用户id"
+// @param		relType		字符串			"关系类型,为空表示全部类型"
+// @param		direction	字符串			"查询方向:out、in 或 both"
+// @param		limit		整型				"最多返回的关系数"
+// @return		result		*RelationResult	"关系与是否被截断"
+func (e *UserContract) QueryRelations(ctx contractapi.TransactionContextInterface, id string, relType string, direction string, limit int32) (*RelationResult, error) {
+	stub := ctx.GetStub()
+	tenant, v, types, err := relationScope(ctx, id, relType, direction, limit)
+	if err != nil {
+		return nil, err
+	}
+	result := &RelationResult{Relations: make([]*Relation, 0)}
+	err = eachRelation(stub, tenant, id, types, direction, func(relation *Relation) error {
+		visible, err := visibleUser(stub, tenant, v, relation.neighbour(id))
+		if err != nil || !visible {
+			return err
+		}
+		if len(result.Relations) == int(limit) {
+			result.Truncated = true
+			return iterate.ErrStop
+		}
+		result.Relations = append(result.Relations, relation)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// TraverseRelations
+// @title		TraverseRelations -> 遍历关系
+// @description	从用户出发按广度优先沿某一方向的关系遍历,每个用户只返回一次(最近的距离与首次经过的关系);不可见的用户既不返回也不继续展开。深度不超过 maxRelationDepth,达到 limit 个用户时停止并标记截断。
+// @auth		lzb
+// @param 		ctx			交易上下文	"包含所有链码API的库"
+// @param		id			字符串		"起点用户id"
+// @param		relType		字符串		"关系类型,为空表示全部类型"
+// @param		direction	字符串		"遍历方向:out、in 或 both"
+// @param		depth		整型			"最大深度"
+// @param		limit		整型			"最多返回的用户数"
+// @return		traversal	*Traversal	"遍历到的用户与是否被截断"
+func (e *UserContract) TraverseRelations(ctx contractapi.TransactionContextInterface, id string, relType string, direction string, depth int32, limit int32) (*Traversal, error) {
+	stub := ctx.GetStub()
+	if depth <= 0 || depth > maxRelationDepth {
+		return nil, fmt.Errorf("depth %d must be between 1 and %d", depth, maxRelationDepth)
+	}
+	tenant, v, types, err := relationScope(ctx, id, relType, direction, limit)
+	if err != nil {
+		return nil, err
+	}
+	traversal := &Traversal{Nodes: make([]*TraversalNode, 0)}
+	visited := map[string]bool{id: true}
+	frontier := []string{id}
+	for level := 1; level <= int(depth) && len(frontier) != 0 && !traversal.Truncated; level++ {
+		next := make([]string, 0)
+		for _, current := range frontier {
+			err := eachRelation(stub, tenant, current, types, direction, func(relation *Relation) error {
+				neighbour := relation.neighbour(current)
+				if visited[neighbour] {
+					return nil
+				}
+				visible, err := visibleUser(stub, tenant, v, neighbour)
+				if err != nil || !visible {
+					return err
+				}
+				if len(traversal.Nodes) == int(limit) {
+					traversal.Truncated = true
+					return iterate.ErrStop
+				}
+				visited[neighbour] = true
+				traversal.Nodes = append(traversal.Nodes, &TraversalNode{Id: neighbour, Depth: level, Relation: relation})
+				next = append(next, neighbour)
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			if traversal.Truncated {
+				break
+			}
+		}
+		frontier = next
+	}
+	return traversal, nil
+}
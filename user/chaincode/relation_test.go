@@ -0,0 +1,183 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lzb13612/Example-Chaincode/internal/mockledger"
+)
+
+// relationLedger 创建包含用户 1-6 的账本,添加关系 manager-of 1->2->3->4->5->1 与 referred-by 6->1,返回本租户管理员
+func relationLedger(t *testing.T) (*mockledger.Ledger, []byte) {
+	t.Helper()
+	ledger := NewLedger(t, "")
+	admin := newCreator(t, "Org1MSP", "admin", map[string]string{roleAttribute: adminRole})
+	for i := 3; i <= 6; i++ {
+		if _, err := invokeAs(ledger, testCreator, "", "addUser", benchUser(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, edge := range [][]string{{"manager-of", "1", "2"}, {"manager-of", "2", "3"}, {"manager-of", "3", "4"}, {"manager-of", "4", "5"}, {"manager-of", "5", "1"}, {"referred-by", "6", "1"}} {
+		if _, err := invokeAs(ledger, admin, "", "addRelation", edge...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ledger, admin
+}
+
+// relationTypeKeys 账本中登记的关系类型
+func relationTypeKeys(ledger *mockledger.Ledger) string {
+	types := make([]string, 0)
+	for _, kv := range ledger.Range("", "") {
+		if key := mockledger.DecodeKey(kv.Key); strings.HasPrefix(key, relationTypeKey+"[") {
+			types = append(types, key)
+		}
+	}
+	return strings.Join(types, ",")
+}
+
+// relationEdges 查询直接关系,返回 "类型:起点->终点" 列表与是否截断
+func relationEdges(t *testing.T, ledger *mockledger.Ledger, args ...string) (string, bool) {
+	t.Helper()
+	payload, err := invokeAs(ledger, testCreator, "", "queryRelations", args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result RelationResult
+	if err := json.Unmarshal(payload, &result); err != nil {
+		t.Fatal(err)
+	}
+	edges := make([]string, len(result.Relations))
+	for i, relation := range result.Relations {
+		edges[i] = fmt.Sprintf("%s:%s->%s", relation.Type, relation.From, relation.To)
+	}
+	return strings.Join(edges, ","), result.Truncated
+}
+
+func TestRelation_AddAndQuery(t *testing.T) {
+	ledger, admin := relationLedger(t)
+	for _, c := range []struct {
+		identity []byte
+		args     []string
+		err      string
+	}{
+		{testCreator, []string{"guardian-of", "1", "3"}, "permission denied"},
+		{admin, []string{"Guardian", "1", "3"}, "invalid relation type"},
+		{admin, []string{"guardian-of", "1", "1"}, "cannot relate to itself"},
+		{admin, []string{"guardian-of", "1", "9"}, "user 9 does not exist"},
+		{admin, []string{"manager-of", "1", "2"}, "relation exist"},
+	} {
+		if _, err := invokeAs(ledger, c.identity, "", "addRelation", c.args...); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Fatalf("%q: expected %q, got %v", c.args, c.err, err)
+		}
+	}
+
+	for _, c := range []struct {
+		args      []string
+		want      string
+		truncated bool
+	}{
+		{[]string{"1", "", DirectionOut, "10"}, "manager-of:1->2", false},
+		{[]string{"1", "", DirectionIn, "10"}, "manager-of:5->1,referred-by:6->1", false},
+		{[]string{"1", "manager-of", DirectionBoth, "10"}, "manager-of:1->2,manager-of:5->1", false},
+		{[]string{"1", "", DirectionBoth, "2"}, "manager-of:1->2,manager-of:5->1", true},
+	} {
+		if edges, truncated := relationEdges(t, ledger, c.args...); edges != c.want || truncated != c.truncated {
+			t.Fatalf("%q: expected %s %v, got %s %v", c.args, c.want, c.truncated, edges, truncated)
+		}
+	}
+	for _, args := range [][]string{{"1", "", "up", "10"}, {"1", "", DirectionOut, "0"}, {"1", "", DirectionOut, "501"}, {"9", "", DirectionOut, "10"}} {
+		if _, err := invokeAs(ledger, testCreator, "", "queryRelations", args...); err == nil {
+			t.Fatalf("%q: expected error", args)
+		}
+	}
+
+	if _, err := invokeAs(ledger, admin, "", "removeRelation", "referred-by", "6", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if edges, _ := relationEdges(t, ledger, "6", "", DirectionBoth, "10"); edges != "" {
+		t.Fatalf("relation not removed: %s", edges)
+	}
+	// 删除某类型的最后一个关系时一并删除类型登记,该类型还有其他关系时保留
+	if types := relationTypeKeys(ledger); types != "reltype[Org1MSP, manager-of]" {
+		t.Fatalf("unexpected relation types %s", types)
+	}
+	if _, err := invokeAs(ledger, admin, "", "removeRelation", "manager-of", "1", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if types := relationTypeKeys(ledger); types != "reltype[Org1MSP, manager-of]" {
+		t.Fatalf("relation type removed while still in use: %s", types)
+	}
+}
+
+func TestRelation_Traverse(t *testing.T) {
+	ledger, _ := relationLedger(t)
+	for _, c := range []struct {
+		args      []string
+		want      string
+		truncated bool
+	}{
+		{[]string{"1", "manager-of", DirectionOut, "2", "10"}, "2@1,3@2", false},
+		// 环上的起点不会再次返回
+		{[]string{"1", "manager-of", DirectionOut, "5", "10"}, "2@1,3@2,4@3,5@4", false},
+		{[]string{"3", "", DirectionIn, "5", "10"}, "2@1,1@2,5@3,6@3,4@4", false},
+		{[]string{"1", "", DirectionBoth, "5", "3"}, "2@1,5@1,6@1", true},
+	} {
+		payload, err := invokeAs(ledger, testCreator, "", "traverseRelations", c.args...)
+		var traversal Traversal
+		if err != nil || json.Unmarshal(payload, &traversal) != nil {
+			t.Fatalf("%q: %s %v", c.args, payload, err)
+		}
+		nodes := make([]string, len(traversal.Nodes))
+		for i, node := range traversal.Nodes {
+			nodes[i] = fmt.Sprintf("%s@%d", node.Id, node.Depth)
+			if node.Relation == nil || (node.Relation.From != node.Id && node.Relation.To != node.Id) {
+				t.Fatalf("%q: node %s without its relation", c.args, node.Id)
+			}
+		}
+		if got := strings.Join(nodes, ","); got != c.want || traversal.Truncated != c.truncated {
+			t.Fatalf("%q: expected %s %v, got %s %v", c.args, c.want, c.truncated, got, traversal.Truncated)
+		}
+	}
+	for _, depth := range []string{"0", "6"} {
+		if _, err := invokeAs(ledger, testCreator, "", "traverseRelations", "1", "", DirectionOut, depth, "10"); err == nil || !strings.Contains(err.Error(), "depth") {
+			t.Fatalf("depth %s: expected depth error, got %v", depth, err)
+		}
+	}
+}
+
+func TestRelation_DeleteUser(t *testing.T) {
+	ledger, _ := relationLedger(t)
+	if _, err := invokeAs(ledger, testCreator, "", "delUser", `{"id":"1"}`); err != nil {
+		t.Fatal(err)
+	}
+	// 从用户 1 出发与指向用户 1 的关系连同反向索引一起删除
+	keys := make([]string, 0)
+	for _, kv := range ledger.Range("", "") {
+		key := mockledger.DecodeKey(kv.Key)
+		if strings.HasPrefix(key, relationKey+"[") || strings.HasPrefix(key, relationRevIndex+"[") {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) != 6 {
+		t.Fatalf("expected 3 relations left, got %q", keys)
+	}
+	// referred-by 只有 6->1,随用户 1 一起删除
+	if types := relationTypeKeys(ledger); types != "reltype[Org1MSP, manager-of]" {
+		t.Fatalf("unexpected relation types %s", types)
+	}
+	if edges, _ := relationEdges(t, ledger, "2", "", DirectionBoth, "10"); edges != "manager-of:2->3" {
+		t.Fatalf("unexpected relations of 2: %s", edges)
+	}
+	// 删除用户 4 时一次删除 manager-of 剩下的两个关系
+	for _, id := range []string{"2", "4"} {
+		if _, err := invokeAs(ledger, testCreator, "", "delUser", `{"id":"`+id+`"}`); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if types := relationTypeKeys(ledger); types != "" {
+		t.Fatalf("unused relation types kept: %s", types)
+	}
+}
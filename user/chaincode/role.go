@@ -0,0 +1,371 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/lzb13612/Example-Chaincode/internal/iterate"
+)
+
+// 角色登记的复合键类型
+const (
+	roleGrantKey = "role"      // 当前授予的角色 -> role[MSP ID, 主体, 角色]
+	roleAuditKey = "roleaudit" // 角色变更记录 -> roleaudit[MSP ID, 交易时间(纳秒), 交易ID]
+)
+
+// 角色变更类型
+const (
+	roleGrant  = "grant"
+	roleRevoke = "revoke"
+)
+
+// rolePattern 角色名只能包含小写字母、数字、'_'、'-',以字母开头
+var rolePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// RoleGrant 授予某个身份的角色
+type RoleGrant struct {
+	MspId     string `json:"mspId"`     // 身份所属的 MSP ID
+	Principal string `json:"principal"` // 证书 subject(如 CN=alice,O=Org1)或由证书派生的用户id
+	Role      string `json:"role"`      // 角色
+	GrantedBy string `json:"grantedBy"` // 授予者的用户id
+	GrantedAt string `json:"grantedAt"` // 授予的交易时间(RFC 3339)
+}
+
+// RoleChange 角色变更记录
+type RoleChange struct {
+	Action    string `json:"action"`    // grant 或 revoke
+	MspId     string `json:"mspId"`     // 身份所属的 MSP ID
+	Principal string `json:"principal"` // 证书 subject 或用户id
+	Role      string `json:"role"`      // 角色
+	By        string `json:"by"`        // 执行变更者的用户id
+	At        string `json:"at"`        // 交易时间(RFC 3339)
+	TxId      string `json:"txId"`      // 交易ID
+}
+
+// formatTimestamp 将交易时间格式化为 RFC 3339
+func formatTimestamp(ts *timestamp.Timestamp) (string, error) {
+	t, err := ptypes.Timestamp(ts)
+	if err != nil {
+		return "", err
+	}
+	return t.UTC().Format(time.RFC3339Nano), nil
+}
+
+// txTime 读取交易时间,所有背书节点对同一交易得到相同的结果
+func txTime(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("get tx timestamp error:%s", err)
+	}
+	t, err := ptypes.Timestamp(ts)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("tx timestamp error:%s", err)
+	}
+	return t.UTC(), nil
+}
+
+// checkRoleGrant 校验角色登记的 MSP ID、主体与角色名
+func checkRoleGrant(mspId, principal, role string) error {
+	if err := checkTenant(mspId); err != nil {
+		return err
+	}
+	if principal == "" {
+		return errors.New("principal is required")
+	}
+	// 用户id以所属 MSP ID 开头,登记到其他 MSP 永远不会生效
+	if prefix, _, ok := strings.Cut(principal, "::"); ok && prefix != mspId {
+		return fmt.Errorf("user id %s does not belong to %s", principal, mspId)
+	}
+	if !rolePattern.MatchString(role) {
+		return fmt.Errorf("invalid role %q", role)
+	}
+	return nil
+}
+
+// callerPrincipals 调用者可被登记的主体:由证书派生的用户id与证书 subject
+func callerPrincipals(ctx contractapi.TransactionContextInterface) ([]string, error) {
+	userId, err := callerUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := ctx.GetClientIdentity().GetX509Certificate()
+	if err != nil {
+		return nil, fmt.Errorf("get certificate error:%s", err)
+	}
+	if cert == nil {
+		return []string{userId}, nil
+	}
+	return []string{userId, cert.Subject.String()}, nil
+}
+
+// hasRole
+// @title		hasRole -> 判断调用者是否具有角色
+// @description	调用者在链上登记中被授予该角色时返回 true;读取登记失败时视为没有该角色。
+// @description	证书的角色属性只对部署组织的身份生效,部署组织的 CA 作为通道级的信任根,用于尚未登记角色时引导;这类角色不受 RevokeRole 影响,需由 CA 吊销证书。
+// @description	其他组织证书中的角色属性被忽略,角色只能在链上登记与撤销。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @param		role	字符串		"角色"
+// @return		ok		布尔			"是否具有角色"
+func hasRole(ctx contractapi.TransactionContextInterface, role string) bool {
+	mspId, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return false
+	}
+	if ctx.GetClientIdentity().AssertAttributeValue(roleAttribute, role) == nil {
+		if meta, found, err := getMeta(ctx.GetStub()); err == nil && found && meta.Deployer == mspId {
+			return true
+		}
+	}
+	principals, err := callerPrincipals(ctx)
+	if err != nil {
+		return false
+	}
+	for _, principal := range principals {
+		if _, found, err := getRoleGrant(ctx.GetStub(), mspId, principal, role); err == nil && found {
+			return true
+		}
+	}
+	return false
+}
+
+// getRoleGrant 读取角色登记,不存在时 found 为 false
+func getRoleGrant(stub shim.ChaincodeStubInterface, mspId, principal, role string) (grant RoleGrant, found bool, err error) {
+	key, err := stub.CreateCompositeKey(roleGrantKey, []string{mspId, principal, role})
+	if err != nil {
+		return grant, false, fmt.Errorf("create role key error:%s", err)
+	}
+	grantBytes, err := stub.GetState(key)
+	if err != nil {
+		return grant, false, fmt.Errorf("get role state error:%s", err)
+	}
+	if len(grantBytes) == 0 {
+		return grant, false, nil
+	}
+	if err := json.Unmarshal(grantBytes, &grant); err != nil {
+		return grant, false, fmt.Errorf("unmarshal role error:%s", err)
+	}
+	return grant, true, nil
+}
+
+// listRoleGrants 列出 MSP 内的角色登记,role 不为空时只返回该角色
+func listRoleGrants(stub shim.ChaincodeStubInterface, mspId, role string) ([]*RoleGrant, error) {
+	resultIterator, err := stub.GetStateByPartialCompositeKey(roleGrantKey, []string{mspId})
+	if err != nil {
+		return nil, fmt.Errorf("get roles by partial composite key error:%s", err)
+	}
+	grants := make([]*RoleGrant, 0)
+	err = iterate.Decode(resultIterator, maxQueryResults, func(key string, grant *RoleGrant) error {
+		if role == "" || grant.Role == role {
+			grants = append(grants, grant)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("role iterator error:%s", err)
+	}
+	return grants, nil
+}
+
+// changeRole
+// @title		changeRole -> 授予或撤销角色
+// @description	在同一交易内修改角色登记并写入变更记录,记录执行者与交易时间。
+// @auth		lzb
+// @param 		stub		shim库	"包含所有链码API的库"
+// @param		action		字符串	"grant 或 revoke"
+// @param		mspId		字符串	"身份所属的 MSP ID"
+// @param		principal	字符串	"证书 subject 或用户id"
+// @param		role		字符串	"角色"
+// @param		by			字符串	"执行者的用户id"
+// @return		err			错误		"修改失败的原因"
+func changeRole(stub shim.ChaincodeStubInterface, action, mspId, principal, role, by string) error {
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("get tx timestamp error:%s", err)
+	}
+	at, err := formatTimestamp(ts)
+	if err != nil {
+		return fmt.Errorf("tx timestamp error:%s", err)
+	}
+	key, err := stub.CreateCompositeKey(roleGrantKey, []string{mspId, principal, role})
+	if err != nil {
+		return fmt.Errorf("create role key error:%s", err)
+	}
+	if action == roleGrant {
+		grantBytes, err := json.Marshal(RoleGrant{MspId: mspId, Principal: principal, Role: role, GrantedBy: by, GrantedAt: at})
+		if err != nil {
+			return fmt.Errorf("marshal role error:%s", err)
+		}
+		if err := stub.PutState(key, grantBytes); err != nil {
+			return fmt.Errorf("put role state error:%s", err)
+		}
+	} else if err := stub.DelState(key); err != nil {
+		return fmt.Errorf("del role state error:%s", err)
+	}
+	// 变更记录按交易时间排序,同一时间的交易再按交易ID区分
+	auditKey, err := stub.CreateCompositeKey(roleAuditKey, []string{mspId, fmt.Sprintf("%020d", ts.GetSeconds()*int64(time.Second)+int64(ts.GetNanos())), stub.GetTxID()})
+	if err != nil {
+		return fmt.Errorf("create role audit key error:%s", err)
+	}
+	changeBytes, err := json.Marshal(RoleChange{Action: action, MspId: mspId, Principal: principal, Role: role, By: by, At: at, TxId: stub.GetTxID()})
+	if err != nil {
+		return fmt.Errorf("marshal role change error:%s", err)
+	}
+	if err := stub.PutState(auditKey, changeBytes); err != nil {
+		return fmt.Errorf("put role audit state error:%s", err)
+	}
+	return nil
+}
+
+// bootstrapAdmin
+// @title		bootstrapAdmin -> 登记首个管理员
+// @description	由全新部署时 Init 参数中的 admin 指定部署链码的组织的首个管理员,之后的管理员只能由已有管理员通过 GrantRole 登记。
+// @auth		lzb
+// @param 		ctx			交易上下文	"包含所有链码API的库"
+// @param		principal	字符串		"证书 subject 或用户id"
+// @return		err			错误			"登记失败的原因"
+func bootstrapAdmin(ctx contractapi.TransactionContextInterface, principal string) error {
+	stub := ctx.GetStub()
+	mspId, err := stubTenant(stub)
+	if err != nil {
+		return err
+	}
+	if err := checkRoleGrant(mspId, principal, adminRole); err != nil {
+		return err
+	}
+	by, err := callerUserId(ctx)
+	if err != nil {
+		return err
+	}
+	return changeRole(stub, roleGrant, mspId, principal, adminRole, by)
+}
+
+// GrantRole
+// @title		GrantRole -> 授予角色
+// @description	将角色授予 MSP 内的身份,只有该 MSP 的管理员可以调用;尚未登记管理员的 MSP 由部署组织的管理员登记首个管理员。
+// @description	审计员可以读取所有租户,只能由部署组织的管理员授予。身份由证书 subject 或由证书派生的用户id表示。
+// @auth		lzb
+// @param 		ctx			交易上下文	"包含所有链码API的库"
+// @param		mspId		字符串		"身份所属的 MSP ID"
+// @param		principal	字符串		"证书 subject 或用户id"
+// @param		role		字符串		"角色"
+// @return		err			错误			"授予失败的原因"
+func (e *UserContract) GrantRole(ctx contractapi.TransactionContextInterface, mspId string, principal string, role string) error {
+	if err := checkRoleGrant(mspId, principal, role); err != nil {
+		return fmt.Errorf("grant role error:%s", err)
+	}
+	if role == auditorRole {
+		if !isDeployerAdmin(ctx) {
+			return errors.New("grant role error:permission denied")
+		}
+	} else if !isTenantAdmin(ctx, mspId) {
+		// 尚未登记管理员的组织,由部署组织的管理员登记其首个管理员
+		admins, err := listRoleGrants(ctx.GetStub(), mspId, adminRole)
+		if err != nil {
+			return err
+		}
+		if role != adminRole || len(admins) != 0 || !isDeployerAdmin(ctx) {
+			return errors.New("grant role error:permission denied")
+		}
+	}
+	_, found, err := getRoleGrant(ctx.GetStub(), mspId, principal, role)
+	if err != nil {
+		return err
+	}
+	if found {
+		return fmt.Errorf("role %s already granted to %s in %s", role, principal, mspId)
+	}
+	by, err := callerUserId(ctx)
+	if err != nil {
+		return err
+	}
+	return changeRole(ctx.GetStub(), roleGrant, mspId, principal, role, by)
+}
+
+// RevokeRole
+// @title		RevokeRole -> 撤销角色
+// @description	撤销 MSP 内身份的角色,只有该 MSP 的管理员可以调用,审计员由部署组织的管理员撤销;不能撤销最后一个登记的管理员,避免该组织无人管理。
+// @auth		lzb
+// @param 		ctx			交易上下文	"包含所有链码API的库"
+// @param		mspId		字符串		"身份所属的 MSP ID"
+// @param		principal	字符串		"证书 subject 或用户id"
+// @param		role		字符串		"角色"
+// @return		err			错误			"撤销失败的原因"
+func (e *UserContract) RevokeRole(ctx contractapi.TransactionContextInterface, mspId string, principal string, role string) error {
+	stub := ctx.GetStub()
+	if err := checkRoleGrant(mspId, principal, role); err != nil {
+		return fmt.Errorf("revoke role error:%s", err)
+	}
+	if role == auditorRole {
+		if !isDeployerAdmin(ctx) {
+			return errors.New("revoke role error:permission denied")
+		}
+	} else if !isTenantAdmin(ctx, mspId) {
+		return errors.New("revoke role error:permission denied")
+	}
+	_, found, err := getRoleGrant(stub, mspId, principal, role)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("role %s is not granted to %s in %s", role, principal, mspId)
+	}
+	if role == adminRole {
+		admins, err := listRoleGrants(stub, mspId, adminRole)
+		if err != nil {
+			return err
+		}
+		if len(admins) == 1 {
+			return fmt.Errorf("cannot revoke the last admin of %s", mspId)
+		}
+	}
+	by, err := callerUserId(ctx)
+	if err != nil {
+		return err
+	}
+	return changeRole(stub, roleRevoke, mspId, principal, role, by)
+}
+
+// ListRoles
+// @title		ListRoles -> 查询角色登记
+// @description	返回本租户(MSP)当前登记的全部角色,跨租户审计角色可通过 transient 指定其他租户。
+// @auth		lzb
+// @param 		ctx		交易上下文		"包含所有链码API的库"
+// @return		grants	[]*RoleGrant	"角色登记"
+func (e *UserContract) ListRoles(ctx contractapi.TransactionContextInterface) ([]*RoleGrant, error) {
+	tenant, err := resolveTenant(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	return listRoleGrants(ctx.GetStub(), tenant, "")
+}
+
+// ListRoleChanges
+// @title		ListRoleChanges -> 查询角色变更记录
+// @description	按交易时间从早到晚返回本租户(MSP)的角色授予与撤销记录。
+// @auth		lzb
+// @param 		ctx		交易上下文		"包含所有链码API的库"
+// @return		changes	[]*RoleChange	"变更记录"
+func (e *UserContract) ListRoleChanges(ctx contractapi.TransactionContextInterface) ([]*RoleChange, error) {
+	tenant, err := resolveTenant(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	resultIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(roleAuditKey, []string{tenant})
+	if err != nil {
+		return nil, fmt.Errorf("get role changes by partial composite key error:%s", err)
+	}
+	changes, err := iterate.Collect[RoleChange](resultIterator, maxQueryResults)
+	if err != nil {
+		return nil, fmt.Errorf("role change iterator error:%s", err)
+	}
+	return changes, nil
+}
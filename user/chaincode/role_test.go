@@ -0,0 +1,180 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lzb13612/Example-Chaincode/internal/mockledger"
+)
+
+// newAuditor 创建 Org2MSP 的身份,并由部署组织的管理员在链上授予审计员角色
+func newAuditor(t *testing.T, ledger *mockledger.Ledger) []byte {
+	admin := newCreator(t, testTenant, "admin", map[string]string{roleAttribute: adminRole})
+	if _, err := invokeAs(ledger, admin, "", "grantRole", "Org2MSP", "CN=auditor,O=Org2MSP", auditorRole); err != nil {
+		t.Fatal(err)
+	}
+	return newCreator(t, "Org2MSP", "auditor", nil)
+}
+
+func TestRole_BootstrapAndGrant(t *testing.T) {
+	ledger := NewLedger(t, `{"admin":"CN=root,O=Org1MSP"}`)
+	root := newCreator(t, "Org1MSP", "root", nil)
+	alice := newCreator(t, "Org1MSP", "alice", nil)
+	bob := newCreator(t, "Org1MSP", "bob", nil)
+	payload, err := invokeAs(ledger, bob, "", "registerSelf", `{"name":"bob"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bobUser UserInfo
+	_ = json.Unmarshal(payload, &bobUser)
+	payload, _ = invokeAs(ledger, alice, "", "registerSelf", `{"name":"alice"}`)
+	var aliceUser UserInfo
+	_ = json.Unmarshal(payload, &aliceUser)
+	altered, _ := json.Marshal(UserInfo{Id: bobUser.Id, Name: "robert"})
+
+	// 普通用户不能修改他人,也不能授予角色
+	if _, err := invokeAs(ledger, alice, "", "alterUser", string(altered)); err == nil {
+		t.Fatal("alice altered bob without admin role")
+	}
+	if _, err := invokeAs(ledger, alice, "", "grantRole", testTenant, aliceUser.Id, adminRole); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+	// Init 登记的管理员按证书 subject 匹配,可以授予角色;被授予的用户按用户id匹配
+	if _, err := invokeAs(ledger, root, "", "grantRole", testTenant, aliceUser.Id, adminRole); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := invokeAs(ledger, root, "", "grantRole", testTenant, aliceUser.Id, adminRole); err == nil || !strings.Contains(err.Error(), "already granted") {
+		t.Fatalf("expected already granted, got %v", err)
+	}
+	if _, err := invokeAs(ledger, alice, "", "alterUser", string(altered)); err != nil {
+		t.Fatalf("alice alterUser as admin: %v", err)
+	}
+
+	payload, _ = invokeAs(ledger, bob, "", "listRoles")
+	var grants []RoleGrant
+	_ = json.Unmarshal(payload, &grants)
+	if len(grants) != 2 {
+		t.Fatalf("unexpected roles %s", payload)
+	}
+	for _, grant := range grants {
+		if grant.MspId != testTenant || grant.Role != adminRole || grant.GrantedBy == "" || grant.GrantedAt == "" {
+			t.Fatalf("unexpected role %+v", grant)
+		}
+	}
+
+	// 撤销后失去权限,最后一个管理员不能撤销
+	if _, err := invokeAs(ledger, alice, "", "revokeRole", testTenant, aliceUser.Id, adminRole); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := invokeAs(ledger, alice, "", "grantRole", testTenant, aliceUser.Id, adminRole); err == nil {
+		t.Fatal("alice granted a role after revocation")
+	}
+	if _, err := invokeAs(ledger, root, "", "revokeRole", testTenant, "CN=root,O=Org1MSP", adminRole); err == nil || !strings.Contains(err.Error(), "last admin") {
+		t.Fatalf("expected last admin error, got %v", err)
+	}
+
+	// 每次变更都有记录,按时间从早到晚排列
+	payload, _ = invokeAs(ledger, bob, "", "listRoleChanges")
+	var changes []RoleChange
+	_ = json.Unmarshal(payload, &changes)
+	if len(changes) != 3 || changes[0].Action != roleGrant || changes[1].Action != roleGrant || changes[2].Action != roleRevoke || changes[2].By != aliceUser.Id {
+		t.Fatalf("unexpected role changes %s", payload)
+	}
+	for _, change := range changes {
+		if change.At == "" || change.TxId == "" {
+			t.Fatalf("role change without time %+v", change)
+		}
+	}
+}
+
+func TestRole_Bootstrap(t *testing.T) {
+	ledger := NewLedger(t, `{"admin":"CN=root,O=Org1MSP"}`)
+	// 部署后不能再通过 Init 登记管理员,其他组织的成员也不能借 Init 为自己的组织登记管理员
+	for _, identity := range [][]byte{testCreator, newCreator(t, "Org2MSP", "mallory", nil)} {
+		if _, err := invokeAs(ledger, identity, "", "init", `{"admin":"CN=mallory,O=Org2MSP"}`); err == nil || !strings.Contains(err.Error(), "first deployment") {
+			t.Fatalf("expected first deployment only, got %v", err)
+		}
+	}
+	// 管理员不属于链码配置
+	payload, _ := invokeAs(ledger, testCreator, "", "listRoles")
+	if strings.Count(string(payload), `"principal"`) != 1 {
+		t.Fatalf("unexpected roles %s", payload)
+	}
+
+	// 尚未登记管理员的组织由部署组织的管理员登记首个管理员,之后只能由该组织的管理员授予
+	root := newCreator(t, "Org1MSP", "root", nil)
+	org2Admin := newCreator(t, "Org2MSP", "admin2", nil)
+	if _, err := invokeAs(ledger, org2Admin, "", "grantRole", "Org2MSP", "CN=admin2,O=Org2MSP", adminRole); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+	if _, err := invokeAs(ledger, root, "", "grantRole", "Org2MSP", "CN=admin2,O=Org2MSP", adminRole); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := invokeAs(ledger, root, "", "grantRole", "Org2MSP", "CN=other,O=Org2MSP", adminRole); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+	if _, err := invokeAs(ledger, org2Admin, "", "grantRole", "Org2MSP", "CN=other,O=Org2MSP", adminRole); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRole_Auditor(t *testing.T) {
+	ledger := NewLedger(t, `{"admin":"CN=root,O=Org1MSP"}`)
+	root := newCreator(t, "Org1MSP", "root", nil)
+	org2Admin := newCreator(t, "Org2MSP", "admin", nil)
+	carol := newCreator(t, "Org2MSP", "carol", nil)
+	if _, err := invokeAs(ledger, root, "", "grantRole", "Org2MSP", "CN=admin,O=Org2MSP", adminRole); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := invokeAs(ledger, carol, testTenant, "queryAllUser"); err == nil {
+		t.Fatal("carol read Org1MSP without auditor role")
+	}
+	// 其他组织证书中的角色属性不生效
+	forged := newCreator(t, "Org2MSP", "forged", map[string]string{roleAttribute: auditorRole})
+	if _, err := invokeAs(ledger, forged, testTenant, "queryAllUser"); err == nil {
+		t.Fatal("Org2MSP certificate attribute granted the auditor role")
+	}
+	// 审计员可以读取所有租户,组织管理员不能授予,也不能授予自己
+	for _, principal := range []string{"CN=carol,O=Org2MSP", "CN=admin,O=Org2MSP"} {
+		if _, err := invokeAs(ledger, org2Admin, "", "grantRole", "Org2MSP", principal, auditorRole); err == nil || !strings.Contains(err.Error(), "permission denied") {
+			t.Fatalf("expected permission denied, got %v", err)
+		}
+	}
+	if _, err := invokeAs(ledger, org2Admin, testTenant, "queryAllUser"); err == nil {
+		t.Fatal("org2 admin read Org1MSP without auditor role")
+	}
+	if _, err := invokeAs(ledger, root, "", "grantRole", "Org2MSP", "CN=carol,O=Org2MSP", auditorRole); err != nil {
+		t.Fatal(err)
+	}
+	if payload, err := invokeAs(ledger, carol, testTenant, "queryAllUser"); err != nil || userIds(t, payload) != "1,2" {
+		t.Fatalf("auditor carol: %s %v", payload, err)
+	}
+	if _, err := invokeAs(ledger, org2Admin, "", "revokeRole", "Org2MSP", "CN=carol,O=Org2MSP", auditorRole); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+	if _, err := invokeAs(ledger, root, "", "revokeRole", "Org2MSP", "CN=carol,O=Org2MSP", auditorRole); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := invokeAs(ledger, carol, testTenant, "queryAllUser"); err == nil {
+		t.Fatal("carol read Org1MSP after revocation")
+	}
+}
+
+func TestRole_InvalidGrant(t *testing.T) {
+	ledger := NewLedger(t, "")
+	admin := newCreator(t, "Org1MSP", "admin", map[string]string{roleAttribute: adminRole})
+	for _, args := range [][]string{
+		{testTenant, "Org2MSP::abc", adminRole},
+		{testTenant, "", adminRole},
+		{testTenant, "CN=x", "Admin"},
+		{"Org1MSP:x", "CN=x", adminRole},
+	} {
+		if _, err := invokeAs(ledger, admin, "", "grantRole", args...); err == nil || !strings.Contains(err.Error(), "grant role error") {
+			t.Fatalf("%q: expected grant role error, got %v", args, err)
+		}
+	}
+	if _, err := invokeAs(ledger, admin, "", "revokeRole", testTenant, "CN=x", auditorRole); err == nil || !strings.Contains(err.Error(), "not granted") {
+		t.Fatalf("expected not granted, got %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package chaincode
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// registrationNonce 已使用的注册 nonce 的复合键类型 -> nonce[租户, nonce]
+const registrationNonce = "nonce"
+
+// Registration 用户注册签名 -> 由用户私钥对注册内容签名,证明密钥持有者同意该记录
+type Registration struct {
+	Nonce     string `json:"nonce"`     // 一次性随机数,防止重放
+	Signature string `json:"signature"` // base64 编码的签名
+}
+
+// registrationPayload
+// @title		registrationPayload -> 规范化签名内容
+// @description	签名内容为 {"user":<UserInfo>,"nonce":"..."} 的 JSON,user 字段按 UserInfo 的声明顺序序列化。
+// @auth		lzb
+// @param		user	UserInfo	"注册的用户"
+// @param		nonce	字符串		"一次性随机数"
+// @return		payload	字符组		"待签名的内容"
+func registrationPayload(user UserInfo, nonce string) ([]byte, error) {
+	return json.Marshal(struct {
+		User  UserInfo `json:"user"`
+		Nonce string   `json:"nonce"`
+	}{user, nonce})
+}
+
+// verifySignature
+// @title		verifySignature -> 验证签名
+// @description	使用 PEM 格式的 PKIX 公钥验证签名,支持 ECDSA(SHA-256, ASN.1 编码)与 Ed25519。
+// @auth		lzb
+// @param		publicKey	字符串	"PEM 格式公钥"
+// @param		payload		字符组	"签名内容"
+// @param		signature	字符组	"签名"
+// @return		err			错误		"验证失败的原因"
+func verifySignature(publicKey string, payload, signature []byte) error {
+	block, _ := pem.Decode([]byte(publicKey))
+	if block == nil {
+		return errors.New("public key is not PEM encoded")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("parse public key error:%s", err)
+	}
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(payload)
+		if !ecdsa.VerifyASN1(key, digest[:], signature) {
+			return errors.New("invalid ecdsa signature")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, payload, signature) {
+			return errors.New("invalid ed25519 signature")
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+	return nil
+}
+
+// verifyRegistration
+// @title		verifyRegistration -> 验证用户注册签名
+// @description	用户带有公钥或链码配置要求签名时,验证注册签名并记录已使用的 nonce,nonce 在租户内不能重复使用。
+// @auth		lzb
+// @param 		stub			shim库			"包含所有链码API的库"
+// @param		tenant			字符串			"租户"
+// @param		user			UserInfo		"注册的用户"
+// @param		registration	*Registration	"注册签名,未提供时为 nil"
+// @return		err				错误				"验证失败的原因"
+func verifyRegistration(stub shim.ChaincodeStubInterface, tenant string, user UserInfo, registration *Registration) error {
+	config, err := getConfig(stub)
+	if err != nil {
+		return err
+	}
+	if user.PublicKey == "" {
+		if config.RequireSignature {
+			return errors.New("public key is required")
+		}
+		return nil
+	}
+	if registration == nil {
+		return errors.New("registration signature is required")
+	}
+	if registration.Nonce == "" {
+		return errors.New("registration nonce is required")
+	}
+	nonceKey, err := stub.CreateCompositeKey(registrationNonce, []string{tenant, registration.Nonce})
+	if err != nil {
+		return fmt.Errorf("create nonce key error:%s", err)
+	}
+	used, err := stub.GetState(nonceKey)
+	if err != nil {
+		return fmt.Errorf("get nonce state error:%s", err)
+	}
+	if len(used) != 0 {
+		return fmt.Errorf("nonce %s already used", registration.Nonce)
+	}
+	signature, err := base64.StdEncoding.DecodeString(registration.Signature)
+	if err != nil {
+		return fmt.Errorf("decode signature error:%s", err)
+	}
+	payload, err := registrationPayload(user, registration.Nonce)
+	if err != nil {
+		return fmt.Errorf("marshal registration payload error:%s", err)
+	}
+	if err := verifySignature(user.PublicKey, payload, signature); err != nil {
+		return err
+	}
+	if err := stub.PutState(nonceKey, []byte(stub.GetTxID())); err != nil {
+		return fmt.Errorf("put nonce state error:%s", err)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package chaincode
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// signedRegistration 生成带公钥的用户及其注册签名参数
+func signedRegistration(t *testing.T, signer crypto.Signer, user UserInfo, nonce string) ([]byte, []byte) {
+	der, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.PublicKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	payload, _ := registrationPayload(user, nonce)
+
+	var signature []byte
+	switch signer.(type) {
+	case ed25519.PrivateKey:
+		signature, err = signer.Sign(rand.Reader, payload, crypto.Hash(0))
+	default:
+		digest := sha256.Sum256(payload)
+		signature, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	userByte, _ := json.Marshal(user)
+	registrationByte, _ := json.Marshal(Registration{
+		Nonce:     nonce,
+		Signature: base64.StdEncoding.EncodeToString(signature),
+	})
+	return userByte, registrationByte
+}
+
+func TestUser_addUserSigned(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	for name, signer := range map[string]crypto.Signer{"ecdsa": ecKey, "ed25519": edKey} {
+		t.Run(name, func(t *testing.T) {
+			stub := GetNewStub()
+			userByte, registrationByte := signedRegistration(t, signer, UserInfo{Id: id1, Name: name1, Sex: sex1}, "nonce-1")
+			res := stub.MockInvoke("1", [][]byte{[]byte("addUser"), userByte, registrationByte})
+			if res.Status != shim.OK {
+				t.Fatalf("addUser: %s", res.Message)
+			}
+			res = stub.MockInvoke("2", [][]byte{[]byte("queryOnceUser"), userByte})
+			var got UserInfo
+			_ = json.Unmarshal(res.Payload, &got)
+			if got.PublicKey == "" {
+				t.Fatal("public key not stored")
+			}
+		})
+	}
+}
+
+func TestUser_addUserSignatureRejected(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	stub := GetNewStub()
+
+	userByte, registrationByte := signedRegistration(t, ecKey, UserInfo{Id: id1, Name: name1, Sex: sex1}, "nonce-1")
+	res := stub.MockInvoke("1", [][]byte{[]byte("addUser"), userByte})
+	if res.Status == shim.OK {
+		t.Fatal("user with public key registered without signature")
+	}
+
+	// 篡改用户名后签名失效
+	var tampered UserInfo
+	_ = json.Unmarshal(userByte, &tampered)
+	tampered.Name = name2
+	tamperedByte, _ := json.Marshal(tampered)
+	res = stub.MockInvoke("2", [][]byte{[]byte("addUser"), tamperedByte, registrationByte})
+	if res.Status == shim.OK {
+		t.Fatal("tampered registration accepted")
+	}
+
+	// 他人密钥签名无效
+	_, foreignSignature := signedRegistration(t, otherKey, UserInfo{Id: id1, Name: name1, Sex: sex1}, "nonce-1")
+	res = stub.MockInvoke("3", [][]byte{[]byte("addUser"), userByte, foreignSignature})
+	if res.Status == shim.OK {
+		t.Fatal("registration signed by another key accepted")
+	}
+
+	res = stub.MockInvoke("4", [][]byte{[]byte("addUser"), userByte, registrationByte})
+	if res.Status != shim.OK {
+		t.Fatalf("addUser: %s", res.Message)
+	}
+	// 删除后重放同一签名
+	stub.MockInvoke("5", [][]byte{[]byte("delUser"), userByte})
+	res = stub.MockInvoke("6", [][]byte{[]byte("addUser"), userByte, registrationByte})
+	if res.Status == shim.OK {
+		t.Fatal("replayed registration accepted")
+	}
+}
+
+func TestUser_requireSignatureConfig(t *testing.T) {
+	stub := NewStub("ex01")
+	res := stub.MockInit("init", [][]byte{[]byte("init"), []byte(testConfig(t, `{"requireSignature":true}`))})
+	if res.Status != shim.OK {
+		t.Fatalf("init: %s", res.Message)
+	}
+	res = stub.MockInvoke("1", [][]byte{[]byte("addUser"), user1})
+	if res.Status == shim.OK {
+		t.Fatal("unsigned registration accepted while signatures are required")
+	}
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	userByte, registrationByte := signedRegistration(t, ecKey, UserInfo{Id: id1, Name: name1, Sex: sex1}, "nonce-1")
+	res = stub.MockInvoke("2", [][]byte{[]byte("addUser"), userByte, registrationByte})
+	if res.Status != shim.OK {
+		t.Fatalf("addUser: %s", res.Message)
+	}
+}
+
+func TestUser_addUserSignedPending(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	stub := NewStub("ex01")
+	if res := stub.MockInit("init", [][]byte{[]byte("init"), []byte(testConfig(t, `{"requireSignature":true,"requireActivation":true}`))}); res.Status != shim.OK {
+		t.Fatalf("init: %s", res.Message)
+	}
+	// 签名针对客户端提交的用户,链码写入的 pending 状态不影响验证
+	userByte, registrationByte := signedRegistration(t, ecKey, UserInfo{Id: id1, Name: name1, Sex: sex1}, "nonce-1")
+	if res := stub.MockInvoke("1", [][]byte{[]byte("addUser"), userByte, registrationByte}); res.Status != shim.OK {
+		t.Fatalf("addUser: %s", res.Message)
+	}
+	res := stub.MockInvoke("2", [][]byte{[]byte("queryOnceUser"), userByte})
+	var got UserInfo
+	if err := json.Unmarshal(res.Payload, &got); err != nil || got.Status != StatusPending {
+		t.Fatalf("expected pending user, got %s %s", res.Payload, res.Message)
+	}
+}
@@ -0,0 +1,199 @@
+package chaincode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/lzb13612/Example-Chaincode/internal/iterate"
+)
+
+// maxQueryResults 非分页查询最多读取的结果数,超过时返回 *iterate.LimitError,数据更多时应使用分页查询
+const maxQueryResults = 10000
+
+// UserPage 分页查询结果
+type UserPage struct {
+	Users    []*UserInfo `json:"users"`    // 本页用户
+	Bookmark string      `json:"bookmark"` // 下一页的书签,为空表示没有更多数据
+	Count    int32       `json:"count"`    // 本页用户数
+}
+
+// userListEncoder
+// @title		userListEncoder -> 用户列表流式编码器
+// @description	逐条将用户写入 JSON 数组,账本中的用户数据本身就是 UserInfo 的 JSON,直接压缩拷贝而不经过反序列化与再序列化,也不在内存中构造 []*UserInfo。
+// @auth		lzb
+type userListEncoder struct {
+	buf   bytes.Buffer
+	count int32
+}
+
+// newUserListEncoder 创建编码器,写入 prefix 与数组起始符
+func newUserListEncoder(prefix string) *userListEncoder {
+	enc := new(userListEncoder)
+	enc.buf.WriteString(prefix)
+	enc.buf.WriteByte('[')
+	return enc
+}
+
+// next 写入元素之间的分隔符
+func (enc *userListEncoder) next() {
+	if enc.count > 0 {
+		enc.buf.WriteByte(',')
+	}
+	enc.count++
+}
+
+// writeRaw 写入账本中存储的用户 JSON,非 JSON 对象时返回错误
+func (enc *userListEncoder) writeRaw(value []byte) error {
+	value = bytes.TrimSpace(value)
+	if len(value) == 0 || value[0] != '{' {
+		return fmt.Errorf("unmarshal user info error:%q is not a user object", value)
+	}
+	mark := enc.buf.Len()
+	enc.next()
+	if err := json.Compact(&enc.buf, value); err != nil {
+		enc.buf.Truncate(mark)
+		enc.count--
+		return fmt.Errorf("unmarshal user info error:%s", err)
+	}
+	return nil
+}
+
+// writeKV 写入状态迭代器读到的用户
+func (enc *userListEncoder) writeKV(kv *queryresult.KV) error {
+	return enc.writeRaw(kv.Value)
+}
+
+// writeUser 序列化并写入一个用户,用于需要解密等处理后的用户
+func (enc *userListEncoder) writeUser(user *UserInfo) error {
+	userBytes, err := json.Marshal(user)
+	if err != nil {
+		return fmt.Errorf("marshal user error:%s", err)
+	}
+	enc.next()
+	enc.buf.Write(userBytes)
+	return nil
+}
+
+// bytes 写入数组结束符与 suffix 并返回编码结果
+func (enc *userListEncoder) bytes(suffix string) []byte {
+	enc.buf.WriteByte(']')
+	enc.buf.WriteString(suffix)
+	return enc.buf.Bytes()
+}
+
+// streamAllUsers
+// @title		streamAllUsers -> 流式查询所有用户
+// @description	与 QueryAllUser 返回相同的 JSON,但逐条写入,不构造用户列表。
+// @auth		lzb
+// @param 		stub	shim库	"包含所有链码API的库"
+// @param		tenant	字符串	"租户"
+// @param		v		*viewer	"读取者"
+// @return		payload	字符组	"用户列表 JSON"
+func streamAllUsers(stub shim.ChaincodeStubInterface, tenant string, v *viewer) ([]byte, error) {
+	startKey, endKey := tenantUserRange(tenant)
+	resultIterator, err := stub.GetStateByRange(startKey, endKey)
+	if err != nil {
+		return nil, fmt.Errorf("get user info by range error:%s", err)
+	}
+	enc := newUserListEncoder("")
+	if err := iterate.States(resultIterator, maxQueryResults, v.writer(tenant, enc)); err != nil {
+		return nil, fmt.Errorf("query all user error:%s", err)
+	}
+	return enc.bytes(""), nil
+}
+
+// parsePageSize 解析旧版函数中字符串形式的分页大小
+func parsePageSize(arg string) (int32, error) {
+	pageSize, err := strconv.ParseInt(arg, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("page size error:%s", err)
+	}
+	return int32(pageSize), nil
+}
+
+// userRangeIterator 按用户id区间分页查询租户内的用户,书签必须落在该区间内,防止借书签读取其他租户
+func userRangeIterator(stub shim.ChaincodeStubInterface, tenant, startId, endId string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, string, error) {
+	if pageSize <= 0 {
+		return nil, "", fmt.Errorf("page size %d must be positive", pageSize)
+	}
+	startKey, endKey, err := userRange(tenant, startId, endId)
+	if err != nil {
+		return nil, "", err
+	}
+	if bookmark != "" && (bookmark < startKey || bookmark >= endKey) {
+		return nil, "", fmt.Errorf("bookmark %q is outside the queried range", bookmark)
+	}
+	resultIterator, metadata, err := stub.GetStateByRangeWithPagination(startKey, endKey, pageSize, bookmark)
+	if err != nil {
+		return nil, "", fmt.Errorf("get user info by range error:%s", err)
+	}
+	return resultIterator, metadata.GetBookmark(), nil
+}
+
+// QueryUserPage
+// @title		QueryUserPage -> 分页查询用户
+// @description	按 QueryAllUser 的顺序分页查询用户,返回的书签用于查询下一页。
+// @auth		lzb
+// @param 		ctx			交易上下文	"包含所有链码API的库"
+// @param		pageSize	整型			"每页用户数"
+// @param		bookmark	字符串		"上一页返回的书签,第一页为空"
+// @return		page		*UserPage	"本页用户与下一页书签"
+func (e *UserContract) QueryUserPage(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*UserPage, error) {
+	return e.QueryUsersByIdRange(ctx, "", "", pageSize, bookmark)
+}
+
+// QueryUsersByIdRange
+// @title		QueryUsersByIdRange -> 按id区间分页查询用户
+// @description	查询本租户中id在 [startId, endId] 之间的用户,数值id按数值比较,例如 100 到 200;id 为空表示不限制该端。按读取策略过滤后本页用户可能少于 pageSize。
+// @auth		lzb
+// @param 		ctx			交易上下文	"包含所有链码API的库"
+// @param		startId		字符串		"起始id(包含)"
+// @param		endId		字符串		"终止id(包含)"
+// @param		pageSize	整型			"每页用户数"
+// @param		bookmark	字符串		"上一页返回的书签,第一页为空"
+// @return		page		*UserPage	"本页用户与下一页书签"
+func (e *UserContract) QueryUsersByIdRange(ctx contractapi.TransactionContextInterface, startId string, endId string, pageSize int32, bookmark string) (*UserPage, error) {
+	return queryUserRange(ctx, startId, endId, pageSize, bookmark, "")
+}
+
+// queryUserRange 按id区间分页查询本租户的用户,status 不为空时只返回该状态的用户
+func queryUserRange(ctx contractapi.TransactionContextInterface, startId, endId string, pageSize int32, bookmark, status string) (*UserPage, error) {
+	tenant, v, err := readScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v.status = status
+	resultIterator, next, err := userRangeIterator(ctx.GetStub(), tenant, startId, endId, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	userInfos, err := iterate.Collect[UserInfo](resultIterator, int(pageSize))
+	if err != nil {
+		return nil, fmt.Errorf("query user page error:%s", err)
+	}
+	userInfos = v.redactAll(tenant, userInfos)
+	return &UserPage{Users: userInfos, Bookmark: next, Count: int32(len(userInfos))}, nil
+}
+
+// streamUserRange 与 QueryUsersByIdRange 返回相同的 JSON,用户列表逐条写入
+func streamUserRange(stub shim.ChaincodeStubInterface, tenant string, v *viewer, startId, endId string, pageSize int32, bookmark string) ([]byte, error) {
+	resultIterator, next, err := userRangeIterator(stub, tenant, startId, endId, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	bookmarkBytes, err := json.Marshal(next)
+	if err != nil {
+		resultIterator.Close()
+		return nil, fmt.Errorf("marshal bookmark error:%s", err)
+	}
+	enc := newUserListEncoder(`{"users":`)
+	if err := iterate.States(resultIterator, int(pageSize), v.writer(tenant, enc)); err != nil {
+		return nil, fmt.Errorf("query user page error:%s", err)
+	}
+	return enc.bytes(`,"bookmark":` + string(bookmarkBytes) + `,"count":` + strconv.Itoa(int(enc.count)) + "}"), nil
+}
@@ -0,0 +1,128 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+func TestStream_MatchesContractFunctions(t *testing.T) {
+	ledger := NewLedger(t, "")
+	for i := 3; i <= 12; i++ {
+		if res := ledger.Invoke("addUser", []string{benchUser(i)}, nil); res.Status != shim.OK {
+			t.Fatal(res.Message)
+		}
+	}
+	cases := []struct {
+		name           string
+		legacy, method string
+		legacyArgs     []string
+		methodArgs     []string
+	}{
+		{"all", "queryAllUser", "QueryAllUser", nil, nil},
+		{"byName", "queryUserByName", "QueryUserByName", []string{`{"name":"user5"}`}, []string{"user5"}},
+		{"byMissingName", "queryUserByName", "QueryUserByName", []string{`{"name":"nobody"}`}, []string{"nobody"}},
+		{"page", "queryUserPage", "QueryUserPage", []string{"4"}, []string{"4", ""}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			stream := ledger.Invoke(c.legacy, c.legacyArgs, nil)
+			decode := ledger.Invoke(c.method, c.methodArgs, nil)
+			if stream.Status != shim.OK || decode.Status != shim.OK {
+				t.Fatalf("unexpected failure: %s / %s", stream.Message, decode.Message)
+			}
+			if string(stream.Payload) != string(decode.Payload) {
+				t.Fatalf("stream payload %s differs from %s", stream.Payload, decode.Payload)
+			}
+		})
+	}
+}
+
+func TestStream_UserPages(t *testing.T) {
+	ledger := NewLedger(t, "")
+	for i := 3; i <= 10; i++ {
+		if res := ledger.Invoke("addUser", []string{benchUser(i)}, nil); res.Status != shim.OK {
+			t.Fatal(res.Message)
+		}
+	}
+	// 逐页读取,拼接后与 queryAllUser 一致
+	var all []*UserInfo
+	args := []string{"3"}
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatal("pagination does not terminate")
+		}
+		res := ledger.Invoke("queryUserPage", args, nil)
+		if res.Status != shim.OK {
+			t.Fatal(res.Message)
+		}
+		var page UserPage
+		if err := json.Unmarshal(res.Payload, &page); err != nil {
+			t.Fatal(err)
+		}
+		if int(page.Count) != len(page.Users) || page.Count > 3 {
+			t.Fatalf("unexpected page %s", res.Payload)
+		}
+		all = append(all, page.Users...)
+		if page.Bookmark == "" {
+			break
+		}
+		args = []string{"3", page.Bookmark}
+	}
+	var expected []*UserInfo
+	if err := json.Unmarshal(ledger.Invoke("queryAllUser", nil, nil).Payload, &expected); err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 10 || len(all) != len(expected) {
+		t.Fatalf("expected 10 users, got %d", len(all))
+	}
+	for i := range all {
+		if *all[i] != *expected[i] {
+			t.Fatalf("page user %d is %+v, expected %+v", i, *all[i], *expected[i])
+		}
+	}
+}
+
+func TestStream_InvalidArgs(t *testing.T) {
+	ledger := NewLedger(t, "")
+	cases := []struct {
+		name    string
+		args    []string
+		message string
+	}{
+		{"noArgs", nil, "no enough args"},
+		{"notNumber", []string{"ten"}, "page size error"},
+		{"zero", []string{"0"}, "page size 0 must be positive"},
+		{"negative", []string{"-1"}, "page size -1 must be positive"},
+		{"foreignBookmark", []string{"1", "\x00meta\x00version\x00"}, "bookmark"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res := ledger.Invoke("queryUserPage", c.args, nil)
+			if res.Status == shim.OK || !strings.Contains(res.Message, c.message) {
+				t.Fatalf("expected error containing %q, got %d %s", c.message, res.Status, res.Message)
+			}
+		})
+	}
+}
+
+func TestUserListEncoder(t *testing.T) {
+	enc := newUserListEncoder("")
+	if err := enc.writeRaw([]byte(" {\"id\": \"1\",\n \"name\": \"lzb1\"} ")); err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []string{"", "null", "[1]", `{"id":`} {
+		if err := enc.writeRaw([]byte(value)); err == nil {
+			t.Fatalf("expected %q to be rejected", value)
+		}
+	}
+	if err := enc.writeUser(&UserInfo{Id: "2", Name: "<lzb2>"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"id":"1","name":"lzb1"},{"id":"2","name":"\u003clzb2\u003e","sex":""}]`
+	if got := string(enc.bytes("")); got != expected {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+}
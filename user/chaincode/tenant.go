@@ -0,0 +1,202 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/lzb13612/Example-Chaincode/internal/iterate"
+)
+
+// 用户、用户名索引、注册 nonce 与id计数器都按租户保存;链码版本与配置属于整个部署,不区分租户
+
+// transientTenantName 通过 transient 显式指定租户时使用的字段名,未指定时使用调用者的 MSP ID
+const transientTenantName = "tenant"
+
+// tenantPattern 租户名只能包含字母、数字、'.'、'-'、'_',保证 user:<租户>: 前缀之间互不包含
+var tenantPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+func init() {
+	registerMigration(3, "tenant namespaces", migrateTenants)
+}
+
+// checkTenant 校验租户名
+func checkTenant(tenant string) error {
+	if !tenantPattern.MatchString(tenant) {
+		return fmt.Errorf("invalid tenant %q", tenant)
+	}
+	return nil
+}
+
+// stubTenant 交易提交者 MSP ID 对应的租户,用于初始化与迁移
+func stubTenant(stub shim.ChaincodeStubInterface) (string, error) {
+	mspId, err := cid.GetMSPID(stub)
+	if err != nil {
+		return "", fmt.Errorf("get msp id error:%s", err)
+	}
+	return mspId, checkTenant(mspId)
+}
+
+// resolveTenant
+// @title		resolveTenant -> 确定本次调用的租户
+// @description	默认使用调用者的 MSP ID;transient 中的 tenant 字段可显式指定租户。访问其他租户需要跨租户审计角色,且只能查询不能修改。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @param		write	布尔			"本次调用是否修改数据"
+// @return		tenant	字符串		"租户名"
+// @return		err		错误			"无权访问该租户等错误"
+func resolveTenant(ctx contractapi.TransactionContextInterface, write bool) (string, error) {
+	own, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("get msp id error:%s", err)
+	}
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return "", fmt.Errorf("get transient error:%s", err)
+	}
+	tenant := own
+	if explicit, ok := transient[transientTenantName]; ok && len(explicit) != 0 {
+		tenant = string(explicit)
+	}
+	if err := checkTenant(tenant); err != nil {
+		return "", err
+	}
+	if tenant == own {
+		return tenant, nil
+	}
+	if write {
+		return "", fmt.Errorf("permission denied: tenant %s is read only for %s", tenant, own)
+	}
+	if !isAuditor(ctx) {
+		return "", fmt.Errorf("permission denied: tenant %s", tenant)
+	}
+	return tenant, nil
+}
+
+// isTenantAdmin 判断调用者是否为该租户的管理员:具有管理员角色且属于该租户
+func isTenantAdmin(ctx contractapi.TransactionContextInterface, tenant string) bool {
+	return hasTenantRole(ctx, tenant, adminRole)
+}
+
+// isDeployerAdmin 判断调用者是否为部署链码的组织的管理员
+func isDeployerAdmin(ctx contractapi.TransactionContextInterface) bool {
+	meta, found, err := getMeta(ctx.GetStub())
+	return err == nil && found && meta.Deployer != "" && isTenantAdmin(ctx, meta.Deployer)
+}
+
+// adminScope 确定写入的租户并要求调用者为该租户的管理员,用于修改用户组、组织等租户级数据
+func adminScope(ctx contractapi.TransactionContextInterface) (string, error) {
+	tenant, err := resolveTenant(ctx, true)
+	if err != nil {
+		return "", err
+	}
+	if !isTenantAdmin(ctx, tenant) {
+		return "", errors.New("permission denied")
+	}
+	return tenant, nil
+}
+
+// hasTenantRole 判断调用者是否属于该租户且具有角色
+func hasTenantRole(ctx contractapi.TransactionContextInterface, tenant, role string) bool {
+	mspId, err := ctx.GetClientIdentity().GetMSPID()
+	return err == nil && mspId == tenant && hasRole(ctx, role)
+}
+
+// migrateTenants
+// @title		migrateTenants -> 迁移到租户命名空间
+// @description	迁移步骤:将已提交的版本 3 数据中不区分租户的用户、用户名索引、注册 nonce 与id计数器移动到执行升级的组织(MSP ID)的租户下。
+// @auth		lzb
+// @param 		stub	shim库	"包含所有链码API的库"
+// @return		err		错误		"迁移失败的原因"
+func migrateTenants(stub shim.ChaincodeStubInterface) error {
+	tenant, err := stubTenant(stub)
+	if err != nil {
+		return err
+	}
+	// 版本 3 的用户键为 user:<编码后的id>,已提交的数据中还没有任何租户键
+	resultIterator, err := stub.GetStateByRange(userKeyPrefix, userKeyEnd)
+	if err != nil {
+		return fmt.Errorf("get user info by range error:%s", err)
+	}
+	users := make([]*queryresult.KV, 0)
+	err = iterate.States(resultIterator, iterate.Unlimited, func(kv *queryresult.KV) error {
+		users = append(users, kv)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("user iterator error:%s", err)
+	}
+	for _, kv := range users {
+		var user UserInfo
+		if err := json.Unmarshal(kv.Value, &user); err != nil {
+			return fmt.Errorf("unmarshal user of key %q error:%s", kv.Key, err)
+		}
+		key, err := userKey(tenant, user.Id)
+		if err != nil {
+			return err
+		}
+		// 之前的迁移步骤已直接写入租户键(支持读取本交易写入的环境中会遍历到)
+		if key == kv.Key {
+			continue
+		}
+		value, err := markLegacyValue(kv.Value)
+		if err != nil {
+			return fmt.Errorf("user %s error:%s", user.Id, err)
+		}
+		if err := stub.PutState(key, value); err != nil {
+			return fmt.Errorf("put user %s state error:%s", user.Id, err)
+		}
+		if err := stub.DelState(kv.Key); err != nil {
+			return fmt.Errorf("del unscoped user %s state error:%s", user.Id, err)
+		}
+	}
+	if err := moveCompositeKeys(stub, legacyNameIndex, nameIndex, 2, tenant); err != nil {
+		return err
+	}
+	if err := moveCompositeKeys(stub, registrationNonce, registrationNonce, 1, tenant); err != nil {
+		return err
+	}
+	return moveCompositeKeys(stub, idCounterKey, idCounterKey, 1, tenant)
+}
+
+// moveCompositeKeys 将恰有 arity 个属性的复合键 from[属性...] 移动到 to[租户, 属性...],值保持不变
+func moveCompositeKeys(stub shim.ChaincodeStubInterface, from, to string, arity int, tenant string) error {
+	resultIterator, err := stub.GetStateByPartialCompositeKey(from, []string{})
+	if err != nil {
+		return fmt.Errorf("get %s by partial composite key error:%s", from, err)
+	}
+	// 先读出全部旧数据,再统一写入,避免边遍历边修改
+	entries := make([]*queryresult.KV, 0)
+	err = iterate.States(resultIterator, iterate.Unlimited, func(kv *queryresult.KV) error {
+		entries = append(entries, kv)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s iterator error:%s", from, err)
+	}
+	for _, kv := range entries {
+		_, attributes, err := stub.SplitCompositeKey(kv.Key)
+		if err != nil {
+			return fmt.Errorf("split %s key error:%s", from, err)
+		}
+		if len(attributes) != arity {
+			continue
+		}
+		key, err := stub.CreateCompositeKey(to, append([]string{tenant}, attributes...))
+		if err != nil {
+			return fmt.Errorf("create %s key error:%s", to, err)
+		}
+		if err := stub.DelState(kv.Key); err != nil {
+			return fmt.Errorf("del %s state error:%s", from, err)
+		}
+		if err := stub.PutState(key, kv.Value); err != nil {
+			return fmt.Errorf("put %s state error:%s", to, err)
+		}
+	}
+	return nil
+}
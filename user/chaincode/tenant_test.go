@@ -0,0 +1,195 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/lzb13612/Example-Chaincode/internal/mockledger"
+)
+
+// invokeAs 以指定身份与租户调用链码,tenant 为空时不显式指定租户
+func invokeAs(ledger *mockledger.Ledger, identity []byte, tenant string, function string, args ...string) ([]byte, error) {
+	var transient map[string][]byte
+	if tenant != "" {
+		transient = map[string][]byte{transientTenantName: []byte(tenant)}
+	}
+	res := ledger.Execute(mockledger.Proposal{Function: function, Args: args, Transient: transient, Creator: identity}).Response
+	if res.Status != shim.OK {
+		return nil, errors.New(res.Message)
+	}
+	return res.Payload, nil
+}
+
+// userIds 解析用户列表中的id
+func userIds(t *testing.T, payload []byte) string {
+	t.Helper()
+	var users []UserInfo
+	if err := json.Unmarshal(payload, &users); err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, len(users))
+	for i, user := range users {
+		ids[i] = user.Id
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestTenant_Isolation(t *testing.T) {
+	ledger := NewLedger(t, "")
+	org2 := newCreator(t, "Org2MSP", "bob", nil)
+
+	// Org2 看不到 Org1 的种子用户,可以使用相同的id和用户名
+	payload, err := invokeAs(ledger, org2, "", "queryAllUser")
+	if err != nil || userIds(t, payload) != "" {
+		t.Fatalf("expected no users for Org2MSP, got %s %v", payload, err)
+	}
+	if _, err := invokeAs(ledger, org2, "", "addUser", `{"id":"1","name":"lzb1","sex":"女"}`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := invokeAs(ledger, org2, "", "queryOnceUser", `{"id":"2"}`); err == nil {
+		t.Fatal("Org2MSP read a user of Org1MSP")
+	}
+	payload, _ = invokeAs(ledger, testCreator, "", "queryOnceUser", `{"id":"1"}`)
+	if string(payload) != `{"id":"1","name":"lzb1","sex":"男"}` {
+		t.Fatalf("Org1MSP user changed: %s", payload)
+	}
+	payload, _ = invokeAs(ledger, testCreator, "", "queryUserByName", `{"name":"lzb1"}`)
+	if userIds(t, payload) != "1" {
+		t.Fatalf("name index crossed tenants: %s", payload)
+	}
+	if _, err := invokeAs(ledger, org2, "", "delUser", `{"id":"2"}`); err != nil {
+		t.Fatal(err)
+	}
+	if payload, _ = invokeAs(ledger, testCreator, "", "queryAllUser"); userIds(t, payload) != "1,2" {
+		t.Fatalf("delUser crossed tenants: %s", payload)
+	}
+}
+
+func TestTenant_ExplicitTenant(t *testing.T) {
+	ledger := NewLedger(t, "")
+	org2 := newCreator(t, "Org2MSP", "bob", nil)
+	org2Admin := newCreator(t, "Org2MSP", "admin", map[string]string{roleAttribute: adminRole})
+	auditor := newAuditor(t, ledger)
+
+	// 显式指定自己的租户等同于不指定
+	if payload, err := invokeAs(ledger, testCreator, testTenant, "queryAllUser"); err != nil || userIds(t, payload) != "1,2" {
+		t.Fatalf("explicit own tenant: %s %v", payload, err)
+	}
+	// 其他租户的管理员与普通用户都不能读取
+	for _, identity := range [][]byte{org2, org2Admin} {
+		if _, err := invokeAs(ledger, identity, testTenant, "queryAllUser"); err == nil || !strings.Contains(err.Error(), "permission denied") {
+			t.Fatalf("expected permission denied, got %v", err)
+		}
+	}
+	// 审计角色可以查询其他租户,但不能修改
+	for _, args := range [][]string{
+		{"queryAllUser"},
+		{"queryOnceUser", `{"id":"1"}`},
+		{"queryUserByName", `{"name":"lzb2"}`},
+		{"queryUsersByIdRange", "", "", "10"},
+		{"QueryUserPage", "10", ""},
+	} {
+		if _, err := invokeAs(ledger, auditor, testTenant, args[0], args[1:]...); err != nil {
+			t.Fatalf("auditor %s: %v", args[0], err)
+		}
+	}
+	for _, args := range [][]string{
+		{"addUser", `{"id":"9","name":"x"}`},
+		{"alterUser", `{"id":"1","name":"x"}`},
+		{"delUser", `{"id":"1"}`},
+		{"registerSelf", `{"name":"x"}`},
+	} {
+		if _, err := invokeAs(ledger, auditor, testTenant, args[0], args[1:]...); err == nil || !strings.Contains(err.Error(), "permission denied") {
+			t.Fatalf("auditor %s: expected permission denied, got %v", args[0], err)
+		}
+	}
+	if _, err := invokeAs(ledger, testCreator, "Org1MSP:x", "queryAllUser"); err == nil || !strings.Contains(err.Error(), "invalid tenant") {
+		t.Fatalf("expected invalid tenant, got %v", err)
+	}
+}
+
+func TestTenant_AdminScope(t *testing.T) {
+	ledger := NewLedger(t, "")
+	alice := newCreator(t, "Org1MSP", "alice", nil)
+	payload, err := invokeAs(ledger, alice, "", "registerSelf", `{"name":"alice"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var registered UserInfo
+	_ = json.Unmarshal(payload, &registered)
+	altered, _ := json.Marshal(UserInfo{Id: registered.Id, Name: "mallory"})
+
+	// 其他租户的管理员既不能指定 Org1MSP 修改,在自己的租户中也找不到该用户
+	org2Admin := newCreator(t, "Org2MSP", "admin", map[string]string{roleAttribute: adminRole})
+	if _, err := invokeAs(ledger, org2Admin, testTenant, "alterUser", string(altered)); err == nil {
+		t.Fatal("Org2MSP admin altered a user of Org1MSP")
+	}
+	if _, err := invokeAs(ledger, org2Admin, "", "alterUser", string(altered)); err == nil {
+		t.Fatal("Org2MSP admin altered a user of Org1MSP")
+	}
+	org1Admin := newCreator(t, "Org1MSP", "admin", map[string]string{roleAttribute: adminRole})
+	if _, err := invokeAs(ledger, org1Admin, "", "alterUser", string(altered)); err != nil {
+		t.Fatalf("Org1MSP admin alterUser: %v", err)
+	}
+}
+
+func TestTenant_BookmarkOutsideTenant(t *testing.T) {
+	ledger := NewLedger(t, "")
+	org0 := newCreator(t, "Org0MSP", "carol", nil)
+	// Org0MSP 的键排在 Org1MSP 之前,借 Org0MSP 的书签不能读到 Org1MSP 的用户
+	if _, err := invokeAs(ledger, org0, "", "addUser", `{"id":"5","name":"x"}`); err != nil {
+		t.Fatal(err)
+	}
+	bookmark, _ := userKey(testTenant, "1")
+	if _, err := invokeAs(ledger, org0, "", "queryUserPage", "10", bookmark); err == nil || !strings.Contains(err.Error(), "outside the queried range") {
+		t.Fatalf("expected bookmark error, got %v", err)
+	}
+	payload, err := invokeAs(ledger, org0, "", "queryUserPage", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var page UserPage
+	_ = json.Unmarshal(payload, &page)
+	if page.Count != 1 || page.Users[0].Id != "5" || page.Bookmark != "" {
+		t.Fatalf("unexpected Org0MSP page %s", payload)
+	}
+}
+
+func TestMigrateTenants(t *testing.T) {
+	// 版本 3 的账本:用户、用户名索引、nonce 与id计数器都不区分租户
+	stub := NewStub("v3")
+	stub.MockTransactionStart("v3")
+	_ = putMeta(stub, ChaincodeMeta{Version: 3})
+	_ = stub.PutState("user:n01:5", []byte(`{"id":"5","name":"five","sex":"男"}`))
+	_ = stub.PutState("user:s:abc", []byte(`{"id":"abc","name":"abc","sex":"女"}`))
+	for objectType, attributes := range map[string][]string{legacyNameIndex: {"five", "5"}, registrationNonce: {"n-1"}, idCounterKey: {"0"}} {
+		key, _ := stub.CreateCompositeKey(objectType, attributes)
+		_ = stub.PutState(key, []byte("1"))
+	}
+	stub.MockTransactionEnd("v3")
+
+	if res := stub.MockInit("upgrade", [][]byte{[]byte("init")}); res.Status != shim.OK {
+		t.Fatalf("upgrade: %s", res.Message)
+	}
+	for key := range stub.State {
+		if strings.HasPrefix(key, "user:n") || strings.HasPrefix(key, "user:s") || strings.HasPrefix(key, "\x00"+legacyNameIndex+"\x00") {
+			t.Fatalf("unscoped key %q left after migration", key)
+		}
+	}
+	for objectType, attributes := range map[string][]string{nameIndex: {testTenant, "five", "5"}, registrationNonce: {testTenant, "n-1"}, idCounterKey: {testTenant, "0"}} {
+		key, _ := stub.CreateCompositeKey(objectType, attributes)
+		if _, ok := stub.State[key]; !ok {
+			t.Fatalf("expected %s%q after migration", objectType, attributes)
+		}
+	}
+	res := stub.MockInvoke("1", [][]byte{[]byte("queryUserByName"), []byte(`{"name":"five"}`)})
+	if res.Status != shim.OK || userIds(t, res.Payload) != "5" {
+		t.Fatalf("migrated user not found by name: %s %s", res.Payload, res.Message)
+	}
+	if res = stub.MockInvoke("2", [][]byte{[]byte("queryAllUser")}); userIds(t, res.Payload) != "5,abc" {
+		t.Fatalf("unexpected migrated users %s", res.Payload)
+	}
+}
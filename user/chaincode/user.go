@@ -0,0 +1,554 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-contract-api-go/metadata"
+	"github.com/lzb13612/Example-Chaincode/internal/iterate"
+	"github.com/lzb13612/Example-Chaincode/internal/legacy"
+)
+
+// UserContract 用户合约
+type UserContract struct {
+	contractapi.Contract
+}
+
+// UserInfo 用户对象结构体 -> 定义了用户的基础信息
+type UserInfo struct {
+	Id        string `json:"id"`                                       // 用户id
+	Name      string `json:"name"`                                     // 用户名
+	Sex       string `json:"sex"`                                      // 用户性别
+	PublicKey string `json:"publicKey,omitempty" metadata:",optional"` // 用户公钥(PEM),用于验证注册签名
+	Owner     string `json:"owner,omitempty" metadata:",optional"`     // 绑定的 Fabric 身份,仅本人或管理员可修改
+
+	Status       string `json:"status,omitempty" metadata:",optional"`       // 用户状态,为空表示 active
+	StatusReason string `json:"statusReason,omitempty" metadata:",optional"` // 最近一次状态变更的原因
+	StatusBy     string `json:"statusBy,omitempty" metadata:",optional"`     // 最近一次状态变更的执行者
+
+	OrgId string `json:"orgId,omitempty" metadata:",optional"` // 所属组织id,只能通过 MoveUser 变更已有的组织
+
+	Encryption int `json:"encryption,omitempty" metadata:",optional"` // 敏感字段的加密方案,0 表示明文,由链码维护
+}
+
+// seedUsers 全新部署时写入的初始用户
+var seedUsers = []UserInfo{
+	// 用户
+	{
+		Id:   "1",
+		Name: "lzb1",
+		Sex:  "男",
+	},
+	// 管理员
+	{
+		Id:   "2",
+		Name: "lzb2",
+		Sex:  "女",
+	},
+}
+
+// NewUserContract
+// @title		NewUserContract -> 创建用户合约
+// @description	创建合约;旧版函数名由 NewChaincode 在合约之前分派,其余未知函数返回找不到函数的错误。
+// @auth		lzb
+// @return		contract	*UserContract	"用户合约"
+func NewUserContract() *UserContract {
+	contract := new(UserContract)
+	contract.Info = metadata.InfoMetadata{
+		Title:       "User",
+		Description: "用户信息管理链码",
+		Version:     "2.0.0",
+	}
+	contract.UnknownTransaction = contract.unknownTransaction
+	return contract
+}
+
+// InitLedger
+// @title		InitLedger -> 初始化
+// @description	全新部署时在调用者所属的租户中对用户和管理员进行初始化一个账户;升级或重新实例化时不覆盖已有数据,只执行迁移步骤并记录新的版本号。
+// @description	与 Fabric 1.x 的 Init 一致,配置与首个管理员只在全新部署时随初始化写入,之后由部署组织的管理员通过 SetConfig 修改配置。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @param		config	字符串		"链码配置 JSON,只能在全新部署时提供;可包含 admin 字段登记首个管理员"
+// @return		err		错误			"初始化失败的原因"
+func (e *UserContract) InitLedger(ctx contractapi.TransactionContextInterface, config string) error {
+	stub := ctx.GetStub()
+	version, err := upgradeLedger(stub, chaincodeVersion, migrations)
+	if err != nil {
+		return fmt.Errorf("init error:%s", err)
+	}
+	if config == "" {
+		return nil
+	}
+	chaincodeConfig, admin, err := parseConfig(stub, config)
+	if err != nil {
+		return err
+	}
+	if version != 0 {
+		return errors.New("init error:config can only be set on first deployment, use setConfig")
+	}
+	if err := putConfig(stub, chaincodeConfig); err != nil {
+		return err
+	}
+	if admin == "" {
+		return nil
+	}
+	if err := bootstrapAdmin(ctx, admin); err != nil {
+		return fmt.Errorf("bootstrap admin error:%s", err)
+	}
+	return nil
+}
+
+// SetConfig
+// @title		SetConfig -> 修改链码配置
+// @description	用新的配置替换链码配置,只有部署链码的组织的管理员可以调用;首个管理员只能在全新部署时登记,之后使用 GrantRole。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @param		config	字符串		"链码配置 JSON"
+// @return		err		错误			"修改失败的原因"
+func (e *UserContract) SetConfig(ctx contractapi.TransactionContextInterface, config string) error {
+	if !isDeployerAdmin(ctx) {
+		return errors.New("set config error:permission denied")
+	}
+	chaincodeConfig, admin, err := parseConfig(ctx.GetStub(), config)
+	if err != nil {
+		return err
+	}
+	if admin != "" {
+		return errors.New("set config error:admin can only be bootstrapped on first deployment, use grantRole")
+	}
+	return putConfig(ctx.GetStub(), chaincodeConfig)
+}
+
+// parseConfig 解析并校验链码配置,admin 为配置中登记首个管理员的字段;未指定 requireOrg 时保持当前设置,关闭组织约束必须显式指定
+func parseConfig(stub shim.ChaincodeStubInterface, config string) (chaincodeConfig ChaincodeConfig, admin string, err error) {
+	current, err := getConfig(stub)
+	if err != nil {
+		return chaincodeConfig, "", err
+	}
+	chaincodeConfig.RequireOrg = current.RequireOrg
+	if err := json.Unmarshal([]byte(config), &chaincodeConfig); err != nil {
+		return chaincodeConfig, "", fmt.Errorf("unmarshal config error:%s", err)
+	}
+	if err := validateIdGenerator(chaincodeConfig); err != nil {
+		return chaincodeConfig, "", fmt.Errorf("config error:%s", err)
+	}
+	if err := validateReadPolicy(chaincodeConfig.ReadPolicy); err != nil {
+		return chaincodeConfig, "", fmt.Errorf("config error:%s", err)
+	}
+	if err := validateApproval(chaincodeConfig.Approval); err != nil {
+		return chaincodeConfig, "", fmt.Errorf("config error:%s", err)
+	}
+	// admin 不属于链码配置,只用于登记执行初始化的组织的首个管理员
+	var bootstrap struct {
+		Admin string `json:"admin"` // 证书 subject 或用户id
+	}
+	if err := json.Unmarshal([]byte(config), &bootstrap); err != nil {
+		return chaincodeConfig, "", fmt.Errorf("unmarshal config error:%s", err)
+	}
+	return chaincodeConfig, bootstrap.Admin, nil
+}
+
+// seedLedger 在交易提交者所属的租户中写入种子用户及其用户名索引,交易提交者为种子用户的添加者
+func seedLedger(stub shim.ChaincodeStubInterface) error {
+	tenant, err := stubTenant(stub)
+	if err != nil {
+		return err
+	}
+	identity, err := cid.New(stub)
+	if err != nil {
+		return fmt.Errorf("get client identity error:%s", err)
+	}
+	creator, err := identityUserId(identity)
+	if err != nil {
+		return err
+	}
+	for _, user := range seedUsers {
+		if err := putCreator(stub, tenant, user.Id, creator); err != nil {
+			return err
+		}
+		if err := putUser(stub, tenant, user); err != nil {
+			return err
+		}
+		if err := putNameIndex(stub, tenant, user.Name, user.Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putUser 生成租户内的用户键并写入用户数据
+func putUser(stub shim.ChaincodeStubInterface, tenant string, user UserInfo) error {
+	// 生成按数值排序的用户键
+	key, err := userKey(tenant, user.Id)
+	if err != nil {
+		return fmt.Errorf("create user key error:%s", err)
+	}
+	// 序列化
+	userBytes, err := json.Marshal(user)
+	if err != nil {
+		return fmt.Errorf("marshal user error:%s", err)
+	}
+	// 上传数据状态
+	if err := stub.PutState(key, userBytes); err != nil {
+		return fmt.Errorf("put user %s state error:%s", user.Id, err)
+	}
+	return nil
+}
+
+// getUser 读取租户内存储的用户,不存在时 found 为 false
+func getUser(stub shim.ChaincodeStubInterface, tenant, id string) (user UserInfo, found bool, err error) {
+	key, err := userKey(tenant, id)
+	if err != nil {
+		return user, false, fmt.Errorf("create user key error:%s", err)
+	}
+	userByte, err := stub.GetState(key)
+	if err != nil {
+		return user, false, fmt.Errorf("get user %s state error:%s", id, err)
+	}
+	if len(userByte) == 0 {
+		return user, false, nil
+	}
+	if err := json.Unmarshal(userByte, &user); err != nil {
+		return user, false, fmt.Errorf("unmarshal user error:%s", err)
+	}
+	return user, true, nil
+}
+
+// AddUser
+// @title		AddUser -> 添加用户
+// @description	添加一个用户,用户已存在时返回错误;链码配置了 idGenerator 时由链码分配id,客户端不能指定id。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @param		user	UserInfo	"用户信息"
+// @return		id		字符串		"用户id"
+// @return		err		错误			"添加失败的原因"
+func (e *UserContract) AddUser(ctx contractapi.TransactionContextInterface, user UserInfo) (string, error) {
+	tenant, err := resolveTenant(ctx, true)
+	if err != nil {
+		return "", err
+	}
+	return addUser(ctx, tenant, user, nil)
+}
+
+// AddSignedUser
+// @title		AddSignedUser -> 添加签名用户
+// @description	添加一个带有公钥的用户,注册签名由用户本人的私钥生成。
+// @auth		lzb
+// @param 		ctx				交易上下文		"包含所有链码API的库"
+// @param		user			UserInfo		"用户信息"
+// @param		registration	Registration	"注册签名"
+// @return		id				字符串			"用户id"
+// @return		err				错误				"添加失败的原因"
+func (e *UserContract) AddSignedUser(ctx contractapi.TransactionContextInterface, user UserInfo, registration Registration) (string, error) {
+	tenant, err := resolveTenant(ctx, true)
+	if err != nil {
+		return "", err
+	}
+	return addUser(ctx, tenant, user, &registration)
+}
+
+// addUser 在租户内添加用户并返回用户id,记录调用者为添加者;由链码分配id时,注册签名针对不含id的用户信息
+func addUser(ctx contractapi.TransactionContextInterface, tenant string, userInfo UserInfo, registration *Registration) (string, error) {
+	stub := ctx.GetStub()
+	// 与身份绑定的用户只能通过 registerSelf 注册
+	if userInfo.Owner != "" || strings.Contains(userInfo.Id, "::") {
+		return "", errors.New("identity bound user must be registered by registerSelf")
+	}
+	if err := checkPlaintext(&userInfo); err != nil {
+		return "", fmt.Errorf("add user error:%s", err)
+	}
+	config, err := getConfig(stub)
+	if err != nil {
+		return "", err
+	}
+	// 注册签名针对调用者提交的内容,在写入由链码维护的字段之前保留一份
+	submitted := userInfo
+	// 状态只能通过状态变更函数修改
+	userInfo.Status, userInfo.StatusReason, userInfo.StatusBy = initialStatus(config), "", ""
+
+	if err := checkOrgRef(stub, tenant, config, userInfo.OrgId); err != nil {
+		return "", err
+	}
+	if config.IdGenerator != "" {
+		if userInfo.Id != "" {
+			return "", errors.New("user id is assigned by the chaincode")
+		}
+		if err := verifyRegistration(stub, tenant, submitted, registration); err != nil {
+			return "", fmt.Errorf("verify registration error:%s", err)
+		}
+		if userInfo.Id, err = assignId(stub, tenant, config); err != nil {
+			return "", fmt.Errorf("assign user id error:%s", err)
+		}
+	}
+	_, found, err := getUser(stub, tenant, userInfo.Id)
+	if err != nil {
+		return "", err
+	}
+	if found {
+		return "", legacy.Thresholdf("user exist")
+	}
+	if config.IdGenerator == "" {
+		if userInfo.Id == "" {
+			return "", errors.New("user id is required")
+		}
+		if err := verifyRegistration(stub, tenant, submitted, registration); err != nil {
+			return "", fmt.Errorf("verify registration error:%s", err)
+		}
+	}
+	encKey, err := getEncryptionKey(stub)
+	if err != nil {
+		return "", err
+	}
+	if err := claimName(stub, tenant, encKey, userInfo); err != nil {
+		return "", err
+	}
+	if encKey != nil {
+		if err := encryptUser(encKey, &userInfo); err != nil {
+			return "", fmt.Errorf("encrypt user error:%s", err)
+		}
+	}
+	if err := setOrgIndex(stub, tenant, userInfo.Id, "", userInfo.OrgId); err != nil {
+		return "", err
+	}
+	creator, err := callerUserId(ctx)
+	if err != nil {
+		return "", err
+	}
+	if err := putCreator(stub, tenant, userInfo.Id, creator); err != nil {
+		return "", err
+	}
+	return userInfo.Id, putUser(stub, tenant, userInfo)
+}
+
+// QueryOnceUser
+// @title		QueryOnceUser -> 查询用户
+// @description	根据用户id查询本租户的用户,提供加密密钥时返回解密后的用户;按读取策略隐藏调用者不可见的字段,不可见的用户视为不存在。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @param		id		字符串		"用户id"
+// @return		user	*UserInfo	"用户信息"
+func (e *UserContract) QueryOnceUser(ctx contractapi.TransactionContextInterface, id string) (*UserInfo, error) {
+	tenant, v, err := readScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	userInfo, err := queryOnceUser(ctx.GetStub(), tenant, id)
+	if err != nil {
+		return nil, err
+	}
+	if !v.redact(tenant, userInfo) {
+		return nil, legacy.Thresholdf("user %s does not exist", id)
+	}
+	return userInfo, nil
+}
+
+// queryOnceUser 查询租户内的用户并按需解密
+func queryOnceUser(stub shim.ChaincodeStubInterface, tenant, id string) (*UserInfo, error) {
+	userInfo, found, err := getUser(stub, tenant, id)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, legacy.Thresholdf("user %s does not exist", id)
+	}
+	encKey, err := getEncryptionKey(stub)
+	if err != nil {
+		return nil, err
+	}
+	if encKey != nil {
+		if err := decryptUser(encKey, &userInfo); err != nil {
+			return nil, fmt.Errorf("decrypt user error:%s", err)
+		}
+	}
+	return &userInfo, nil
+}
+
+// QueryAllUser
+// @title		QueryAllUser -> 查询所有用户
+// @description	按用户id查询本租户的所有用户,数值id按数值从小到大排在前面,其余id按字典序排在之后;按读取策略过滤用户与字段。
+// @description	用户超过 maxQueryResults 个时返回错误,应改用 QueryUsersByIdRange 分页查询。
+// @auth		lzb
+// @param 		ctx		交易上下文		"包含所有链码API的库"
+// @return		users	[]*UserInfo		"用户列表"
+func (e *UserContract) QueryAllUser(ctx contractapi.TransactionContextInterface) ([]*UserInfo, error) {
+	tenant, v, err := readScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	startKey, endKey := tenantUserRange(tenant)
+	resultIterator, err := ctx.GetStub().GetStateByRange(startKey, endKey)
+	if err != nil {
+		return nil, fmt.Errorf("get user info by range error:%s", err)
+	}
+	userInfos, err := iterate.Collect[UserInfo](resultIterator, maxQueryResults)
+	if err != nil {
+		return nil, fmt.Errorf("query all user error:%s", err)
+	}
+	return v.redactAll(tenant, userInfos), nil
+}
+
+// AlterUser
+// @title		AlterUser -> 修改用户
+// @description	修改用户名与性别,改名时同步更新用户名索引;未属于任何组织的用户可以在修改时指定组织。用户不存在或已关闭时返回错误。链码配置要求审批时只能通过 RequestUserChange 修改。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @param		user	UserInfo	"新的用户信息"
+// @return		err		错误			"修改失败的原因"
+func (e *UserContract) AlterUser(ctx contractapi.TransactionContextInterface, user UserInfo) error {
+	tenant, err := resolveTenant(ctx, true)
+	if err != nil {
+		return err
+	}
+	if err := checkDirectChange(ctx.GetStub(), ChangeAlter); err != nil {
+		return fmt.Errorf("alter user error:%s", err)
+	}
+	return alterUser(ctx.GetStub(), tenant, user, func(old UserInfo) error {
+		return checkOwner(ctx, tenant, old)
+	})
+}
+
+// alterUser 修改租户内的用户,authorize 校验修改前的用户是否允许修改,为 nil 时不校验(审批通过后执行)
+func alterUser(stub shim.ChaincodeStubInterface, tenant string, newUserInfo UserInfo, authorize func(old UserInfo) error) error {
+	if err := checkPlaintext(&newUserInfo); err != nil {
+		return fmt.Errorf("alter user error:%s", err)
+	}
+	oldUserInfo, found, err := getUser(stub, tenant, newUserInfo.Id)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return legacy.Thresholdf("user %s does not exist", newUserInfo.Id)
+	}
+	if authorize != nil {
+		if err := authorize(oldUserInfo); err != nil {
+			return fmt.Errorf("alter user error:%s", err)
+		}
+	}
+	if err := checkNotClosed(&oldUserInfo); err != nil {
+		return fmt.Errorf("alter user error:%s", err)
+	}
+	// 未指定组织时保留原组织;已有的组织只能通过 MoveUser 调动,以便记录调动原因
+	if newUserInfo.OrgId == "" {
+		newUserInfo.OrgId = oldUserInfo.OrgId
+	} else if oldUserInfo.OrgId != "" && newUserInfo.OrgId != oldUserInfo.OrgId {
+		return errors.New("alter user error:organization can only be changed by moveUser")
+	}
+	config, err := getConfig(stub)
+	if err != nil {
+		return err
+	}
+	if err := checkOrgRef(stub, tenant, config, newUserInfo.OrgId); err != nil {
+		return fmt.Errorf("alter user error:%s", err)
+	}
+	if err := setOrgIndex(stub, tenant, oldUserInfo.Id, oldUserInfo.OrgId, newUserInfo.OrgId); err != nil {
+		return err
+	}
+	oldUserInfo.OrgId = newUserInfo.OrgId
+	encKey, err := getEncryptionKey(stub)
+	if err != nil {
+		return err
+	}
+	oldScheme := oldUserInfo.Encryption
+	if encKey != nil {
+		if err := decryptUser(encKey, &oldUserInfo); err != nil {
+			return fmt.Errorf("decrypt user error:%s", err)
+		}
+	} else if isEncrypted(oldUserInfo) {
+		return errors.New("user is encrypted, encryption key required")
+	}
+	if oldUserInfo.Name != newUserInfo.Name || oldScheme != writeScheme(encKey) {
+		// 改名或加密方案变化时在同一交易内释放旧索引,并按新记录重新占用用户名
+		if err := releaseName(stub, tenant, encKey, oldScheme, oldUserInfo); err != nil {
+			return err
+		}
+		if err := claimName(stub, tenant, encKey, newUserInfo); err != nil {
+			return err
+		}
+		oldUserInfo.Name = newUserInfo.Name
+	}
+	if oldUserInfo.Sex != newUserInfo.Sex {
+		oldUserInfo.Sex = newUserInfo.Sex
+	}
+	if encKey != nil {
+		if err := encryptUser(encKey, &oldUserInfo); err != nil {
+			return fmt.Errorf("encrypt user error:%s", err)
+		}
+	}
+	return putUser(stub, tenant, oldUserInfo)
+}
+
+// DelUser
+// @title		DelUser -> 删除用户
+// @description	删除用户及其用户名索引、组成员关系、组织索引与用户关系,用户不存在时不做处理。链码配置要求审批时只能通过 RequestUserChange 删除。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @param		id		字符串		"用户id"
+// @return		err		错误			"删除失败的原因"
+func (e *UserContract) DelUser(ctx contractapi.TransactionContextInterface, id string) error {
+	tenant, err := resolveTenant(ctx, true)
+	if err != nil {
+		return err
+	}
+	if err := checkDirectChange(ctx.GetStub(), ChangeDelete); err != nil {
+		return fmt.Errorf("del user error:%s", err)
+	}
+	return delUser(ctx.GetStub(), tenant, id, func(old UserInfo) error {
+		return checkOwner(ctx, tenant, old)
+	})
+}
+
+// delUser 删除租户内的用户,authorize 校验被删除的用户是否允许删除,为 nil 时不校验(审批通过后执行)
+func delUser(stub shim.ChaincodeStubInterface, tenant, id string, authorize func(old UserInfo) error) error {
+	key, err := userKey(tenant, id)
+	if err != nil {
+		return errors.New("create key error")
+	}
+	oldUserInfo, found, err := getUser(stub, tenant, id)
+	if err != nil {
+		return err
+	}
+	if found {
+		if authorize != nil {
+			if err := authorize(oldUserInfo); err != nil {
+				return fmt.Errorf("del user error:%s", err)
+			}
+		}
+		encKey, err := getEncryptionKey(stub)
+		if err != nil {
+			return err
+		}
+		oldScheme := oldUserInfo.Encryption
+		if encKey != nil {
+			if err := decryptUser(encKey, &oldUserInfo); err != nil {
+				return fmt.Errorf("decrypt user error:%s", err)
+			}
+		} else if isEncrypted(oldUserInfo) {
+			return errors.New("user is encrypted, encryption key required")
+		}
+		if err := releaseName(stub, tenant, encKey, oldScheme, oldUserInfo); err != nil {
+			return err
+		}
+		if err := removeUserMemberships(stub, tenant, id); err != nil {
+			return err
+		}
+		if err := removeUserRelations(stub, tenant, id); err != nil {
+			return err
+		}
+		if err := delCreator(stub, tenant, id); err != nil {
+			return err
+		}
+		if err := setOrgIndex(stub, tenant, id, oldUserInfo.OrgId, ""); err != nil {
+			return err
+		}
+	}
+	if err := stub.DelState(key); err != nil {
+		return fmt.Errorf("del user error:%s", err)
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/lzb13612/Example-Chaincode/internal/creator"
+	"github.com/lzb13612/Example-Chaincode/internal/golden"
+)
+
+var (
+	id_1   = "1"
+	name_1 = "lzb1"
+	sex_1  = "男"
+	id_2   = "2"
+	name_2 = "lzb2"
+	sex_2  = "女"
+	id1    = "3"
+	id2    = "4"
+	id3    = "5"
+	name1  = "lzb3"
+	name2  = "lzb4"
+	name3  = "lzb5"
+	sex1   = "男"
+	sex2   = "女"
+	sex3   = "男"
+)
+
+type UserInfoTest struct {
+	Id   string `json:"id"`   // 用户id
+	Name string `json:"name"` // 用户名
+	Sex  string `json:"sex"`  // 用户性别
+}
+
+var userInfoTest_1 = UserInfoTest{
+	Id:   id_1,
+	Name: name_1,
+	Sex:  sex_1,
+}
+
+var user_1, _ = json.Marshal(userInfoTest_1)
+
+var userInfoTest_2 = UserInfoTest{
+	Id:   id_2,
+	Name: name_2,
+	Sex:  sex_2,
+}
+
+var user_2, _ = json.Marshal(userInfoTest_2)
+
+var userInfoTest1 = UserInfoTest{
+	Id:   id1,
+	Name: name1,
+	Sex:  sex1,
+}
+
+var user1, _ = json.Marshal(userInfoTest1)
+
+var userInfoTest2 = UserInfoTest{
+	Id:   id2,
+	Name: name2,
+	Sex:  sex2,
+}
+
+var user2, _ = json.Marshal(userInfoTest2)
+
+var userInfoTest3 = UserInfoTest{
+	Id:   id3,
+	Name: name3,
+	Sex:  sex3,
+}
+
+var user3, _ = json.Marshal(userInfoTest3)
+
+// testTenant testCreator 所属的租户
+const testTenant = "Org1MSP"
+
+var testCreator = creator.MustNew(testTenant, "lzb", nil)
+
+// NewStub 创建未初始化的 MockStub,调用者为 testCreator
+func NewStub(name string) *shimtest.MockStub {
+	var scc, _ = NewChaincode()
+	var stub = shimtest.NewMockStub(name, scc)
+	stub.Creator = testCreator
+	return stub
+}
+
+func GetNewStub() *shimtest.MockStub {
+	var stub = NewStub("ex01")
+	stub.MockInit("init", [][]byte{[]byte("init"), []byte(noOrgConfig)})
+	return stub
+}
+
+// noOrgConfig 不涉及组织的测试使用的链码配置,关闭全新账本默认开启的 requireOrg
+const noOrgConfig = `{"requireOrg":false}`
+
+// testConfig 测试账本的链码配置:未指定 requireOrg 时按 noOrgConfig 关闭,需要组织约束的测试显式开启
+func testConfig(t testing.TB, config string) string {
+	if config == "" {
+		return noOrgConfig
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal([]byte(config), &fields); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fields["requireOrg"]; !ok {
+		fields["requireOrg"] = json.RawMessage("false")
+	}
+	configBytes, err := json.Marshal(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(configBytes)
+}
+
+// step 一次链码调用及其期望结果
+type step struct {
+	function string   // 函数名
+	args     []string // 参数
+	status   int32    // 期望的状态码
+	payload  string   // 期望的载荷,仅在调用成功时比较
+	message  string   // 期望错误信息包含的内容,仅在调用失败时比较
+}
+
+// scenario 一组按顺序执行的调用,执行后账本状态与 golden 文件比较
+type scenario struct {
+	name  string
+	steps []step
+}
+
+// ok 期望成功并返回 payload 的调用
+func ok(function, payload string, args ...string) step {
+	return step{function: function, args: args, status: shim.OK, payload: payload}
+}
+
+// fail 期望失败且错误信息包含 message 的调用
+func fail(function, message string, args ...string) step {
+	return step{function: function, args: args, status: shim.ERROR, message: message}
+}
+
+// reject 期望以旧版请求错误(shim.ERRORTHRESHOLD)失败且错误信息包含 message 的调用
+func reject(function, message string, args ...string) step {
+	return step{function: function, args: args, status: shim.ERRORTHRESHOLD, message: message}
+}
+
+// runScenarios 在新的账本上依次执行每个场景,检查每一步的结果,并将每一步的响应与最终状态和 golden 文件比较
+func runScenarios(t *testing.T, scenarios []scenario) {
+	for _, sc := range scenarios {
+		t.Run(sc.name, func(t *testing.T) {
+			stub := GetNewStub()
+			var responses strings.Builder
+			for i, st := range sc.steps {
+				args := [][]byte{[]byte(st.function)}
+				for _, arg := range st.args {
+					args = append(args, []byte(arg))
+				}
+				res := stub.MockInvoke(fmt.Sprintf("tx%d", i+1), args)
+				fmt.Fprintf(&responses, "# %s -> %d %q", st.function, res.Status, res.Message)
+				if len(res.Payload) > 0 {
+					fmt.Fprintf(&responses, " %s", res.Payload)
+				}
+				responses.WriteString("\n")
+				if res.Status != st.status {
+					t.Fatalf("step %d %s: expected status %d, got %d (%s)", i+1, st.function, st.status, res.Status, res.Message)
+				}
+				if st.status == shim.OK && string(res.Payload) != st.payload {
+					t.Fatalf("step %d %s: expected payload %s, got %s", i+1, st.function, st.payload, res.Payload)
+				}
+				if st.status != shim.OK && !strings.Contains(res.Message, st.message) {
+					t.Fatalf("step %d %s: expected message containing %q, got %q", i+1, st.function, st.message, res.Message)
+				}
+			}
+			golden.Assert(t, t.Name(), responses.String()+golden.RenderState(stub.State))
+		})
+	}
+}
+
+// 种子用户与测试用户的 JSON 表示
+var (
+	seedUserList = `[` + string(user_1) + `,` + string(user_2) + `]`
+	idOnly1      = `{"id":"1"}`
+	idOnly3      = `{"id":"3"}`
+)
+
+func TestUser_queryAllUser(t *testing.T) {
+	runScenarios(t, []scenario{
+		{"seed_users", []step{
+			ok("queryAllUser", seedUserList),
+		}},
+		{"after_add", []step{
+			ok("addUser", `{"id":"3"}`, string(user1)),
+			ok("queryAllUser", `[`+string(user_1)+`,`+string(user_2)+`,`+string(user1)+`]`),
+		}},
+		{"after_delete", []step{
+			ok("delUser", "", idOnly1),
+			ok("queryAllUser", `[`+string(user_2)+`]`),
+		}},
+		{"empty", []step{
+			ok("delUser", "", idOnly1),
+			ok("delUser", "", string(user_2)),
+			ok("queryAllUser", `[]`),
+		}},
+	})
+}
+
+func TestUser_queryOnceUser(t *testing.T) {
+	runScenarios(t, []scenario{
+		{"existing", []step{
+			ok("queryOnceUser", string(user_1), idOnly1),
+		}},
+		{"nonexistent", []step{
+			reject("queryOnceUser", "user 3 does not exist", idOnly3),
+		}},
+		{"missing_args", []step{
+			reject("queryOnceUser", "no enough args"),
+		}},
+		{"too_many_args", []step{
+			reject("queryOnceUser", "no enough args", idOnly1, idOnly3),
+		}},
+		{"malformed_json", []step{
+			fail("queryOnceUser", "unmarshal user error", `{"id":`),
+		}},
+	})
+}
+
+func TestUser_addUser(t *testing.T) {
+	runScenarios(t, []scenario{
+		{"new_user", []step{
+			ok("addUser", `{"id":"3"}`, string(user1)),
+			ok("queryOnceUser", string(user1), idOnly3),
+		}},
+		{"duplicate", []step{
+			ok("addUser", `{"id":"3"}`, string(user1)),
+			reject("addUser", "user exist", string(user1)),
+			reject("addUser", "user exist", string(user_1)),
+		}},
+		{"missing_args", []step{
+			reject("addUser", "no enough args"),
+		}},
+		{"too_many_args", []step{
+			reject("addUser", "no enough args", string(user1), "{}", "{}"),
+		}},
+		{"malformed_json", []step{
+			fail("addUser", "unmarshal error", `{"id":"3",`),
+			fail("addUser", "unmarshal error", `[]`),
+		}},
+		{"identity_bound", []step{
+			fail("addUser", "registerSelf", `{"id":"3","name":"x","sex":"男","owner":"someone"}`),
+			fail("addUser", "registerSelf", `{"id":"Org1MSP::abc","name":"x","sex":"男"}`),
+		}},
+	})
+}
+
+func TestUser_alterUser(t *testing.T) {
+	altered, _ := json.Marshal(UserInfoTest{Id: id1, Name: "test", Sex: "女"})
+	runScenarios(t, []scenario{
+		{"rename", []step{
+			ok("addUser", `{"id":"3"}`, string(user1)),
+			ok("alterUser", "", string(altered)),
+			ok("queryOnceUser", string(altered), idOnly3),
+		}},
+		{"unchanged", []step{
+			ok("alterUser", "", string(user_1)),
+			ok("queryOnceUser", string(user_1), idOnly1),
+		}},
+		{"nonexistent", []step{
+			reject("alterUser", "user 3 does not exist", string(user1)),
+			reject("queryOnceUser", "user 3 does not exist", idOnly3),
+		}},
+		{"missing_args", []step{
+			reject("alterUser", "no enough args"),
+		}},
+		{"malformed_json", []step{
+			fail("alterUser", "unmarshal user error", `{"id":"1","name":`),
+			ok("queryOnceUser", string(user_1), idOnly1),
+		}},
+	})
+}
+
+func TestUser_delUser(t *testing.T) {
+	runScenarios(t, []scenario{
+		{"existing", []step{
+			ok("delUser", "", string(user_1)),
+			reject("queryOnceUser", "user 1 does not exist", idOnly1),
+		}},
+		{"nonexistent", []step{
+			ok("delUser", "", idOnly3),
+			ok("queryAllUser", seedUserList),
+		}},
+		{"missing_args", []step{
+			reject("delUser", "no enough args"),
+		}},
+		{"malformed_json", []step{
+			fail("delUser", "unmarshal user error", `{`),
+		}},
+	})
+}
+
+func TestUser_unknownFunction(t *testing.T) {
+	runScenarios(t, []scenario{
+		{"unknown", []step{
+			fail("dropAllUsers", "not find function dropAllUsers"),
+		}},
+	})
+}
@@ -0,0 +1,213 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+)
+
+// 用户字段名,与 UserInfo 的 JSON 字段名一致
+const (
+	fieldId        = "id"
+	fieldName      = "name"
+	fieldSex       = "sex"
+	fieldPublicKey = "publicKey"
+	fieldOwner     = "owner"
+	fieldStatus    = "status" // 包括状态、变更原因与执行者
+	fieldOrg       = "orgId"
+)
+
+// allUserFields UserInfo 的全部字段
+var allUserFields = []string{fieldId, fieldName, fieldSex, fieldPublicKey, fieldOwner, fieldStatus, fieldOrg}
+
+// ReadPolicy 读取策略 -> 按调用者与用户所属租户的关系决定可见字段,字段列表为空表示该类调用者看不到任何记录;租户管理员总能看到本租户的全部字段
+type ReadPolicy struct {
+	SameTenant  []string `json:"sameTenant"`  // 同一租户的非管理员调用者
+	OtherTenant []string `json:"otherTenant"` // 其他租户的调用者(跨租户审计)
+}
+
+// defaultReadPolicy 未配置读取策略时使用:其他组织的调用者只能看到id与用户名
+var defaultReadPolicy = ReadPolicy{
+	SameTenant:  allUserFields,
+	OtherTenant: []string{fieldId, fieldName},
+}
+
+// validateReadPolicy 校验读取策略中的字段名,可见记录必须包含id
+func validateReadPolicy(policy *ReadPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	for _, fields := range [][]string{policy.SameTenant, policy.OtherTenant} {
+		if len(fields) != 0 && !containsField(fields, fieldId) {
+			return fmt.Errorf("read policy fields %v must include %s", fields, fieldId)
+		}
+		for _, field := range fields {
+			if !containsField(allUserFields, field) {
+				return fmt.Errorf("unknown user field %s in read policy", field)
+			}
+		}
+	}
+	return nil
+}
+
+// containsField 判断字段列表中是否包含字段
+func containsField(fields []string, field string) bool {
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// viewer 读取用户的调用者,每次查询创建一次,对所有结果使用相同的判断
+type viewer struct {
+	mspId  string     // 调用者 MSP ID
+	userId string     // 调用者身份派生的用户id,用于识别本人的记录
+	admin  bool       // 调用者是否为其所属租户的管理员
+	policy ReadPolicy // 生效的读取策略
+	status string     // 列表查询的状态条件,为空时不过滤
+}
+
+// newViewer
+// @title		newViewer -> 创建读取者
+// @description	读取调用者身份与链码配置中的读取策略,未配置时使用 defaultReadPolicy。
+// @auth		lzb
+// @param 		ctx		交易上下文	"包含所有链码API的库"
+// @return		v		*viewer		"读取者"
+// @return		err		错误			"读取身份或配置失败的原因"
+func newViewer(ctx contractapi.TransactionContextInterface) (*viewer, error) {
+	mspId, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("get msp id error:%s", err)
+	}
+	userId, err := callerUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+	config, err := getConfig(ctx.GetStub())
+	if err != nil {
+		return nil, err
+	}
+	policy := defaultReadPolicy
+	if config.ReadPolicy != nil {
+		policy = *config.ReadPolicy
+	}
+	return &viewer{mspId: mspId, userId: userId, admin: isTenantAdmin(ctx, mspId), policy: policy}, nil
+}
+
+// readScope 确定查询的租户并创建读取者
+func readScope(ctx contractapi.TransactionContextInterface) (string, *viewer, error) {
+	tenant, err := resolveTenant(ctx, false)
+	if err != nil {
+		return "", nil, err
+	}
+	v, err := newViewer(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	return tenant, v, nil
+}
+
+// tenantFields 调用者对租户内记录的基本可见字段,不考虑本人的记录
+func (v *viewer) tenantFields(tenant string) []string {
+	switch {
+	case v.mspId == tenant && v.admin:
+		return allUserFields
+	case v.mspId == tenant:
+		return v.policy.SameTenant
+	default:
+		return v.policy.OtherTenant
+	}
+}
+
+// full 判断调用者是否能看到租户内所有记录的全部字段且不按状态过滤,此时列表查询可以直接输出账本中的原始值
+func (v *viewer) full(tenant string) bool {
+	if v.status != "" {
+		return false
+	}
+	fields := v.tenantFields(tenant)
+	for _, field := range allUserFields {
+		if !containsField(fields, field) {
+			return false
+		}
+	}
+	return true
+}
+
+// fields 调用者对一条记录的可见字段,nil 表示记录不可见;与身份绑定的用户本人总能看到自己的全部字段
+func (v *viewer) fields(tenant string, user *UserInfo) []string {
+	if user.Owner != "" && user.Owner == v.userId {
+		return allUserFields
+	}
+	fields := v.tenantFields(tenant)
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// redact
+// @title		redact -> 按读取策略处理用户
+// @description	清空调用者不可见的字段;单条、列表、搜索与历史查询都通过它应用同一策略。按状态过滤时,看不到状态字段的调用者不能借过滤得知用户状态,所有用户都不可见。
+// @auth		lzb
+// @param		tenant	字符串		"用户所属租户"
+// @param		user	*UserInfo	"用户,原地修改"
+// @return		visible	布尔			"记录是否可见,不可见的记录不应返回"
+func (v *viewer) redact(tenant string, user *UserInfo) bool {
+	fields := v.fields(tenant, user)
+	if fields == nil {
+		return false
+	}
+	if v.status != "" && (!containsField(fields, fieldStatus) || userStatus(user) != v.status) {
+		return false
+	}
+	values := map[string][]*string{
+		fieldId:        {&user.Id},
+		fieldName:      {&user.Name},
+		fieldSex:       {&user.Sex},
+		fieldPublicKey: {&user.PublicKey},
+		fieldOwner:     {&user.Owner},
+		fieldStatus:    {&user.Status, &user.StatusReason, &user.StatusBy},
+		fieldOrg:       {&user.OrgId},
+	}
+	for field, fieldValues := range values {
+		if containsField(fields, field) {
+			continue
+		}
+		for _, value := range fieldValues {
+			*value = ""
+		}
+	}
+	return true
+}
+
+// redactAll 按读取策略处理用户列表,去掉不可见的用户
+func (v *viewer) redactAll(tenant string, users []*UserInfo) []*UserInfo {
+	visible := users[:0]
+	for _, user := range users {
+		if v.redact(tenant, user) {
+			visible = append(visible, user)
+		}
+	}
+	return visible
+}
+
+// writer 按读取策略将状态迭代器读到的用户写入编码器;调用者可见全部字段时直接拷贝账本中的原始值
+func (v *viewer) writer(tenant string, enc *userListEncoder) func(kv *queryresult.KV) error {
+	if v.full(tenant) {
+		return enc.writeKV
+	}
+	return func(kv *queryresult.KV) error {
+		var user UserInfo
+		if err := json.Unmarshal(kv.Value, &user); err != nil {
+			return fmt.Errorf("unmarshal user info error:%s", err)
+		}
+		if !v.redact(tenant, &user) {
+			return nil
+		}
+		return enc.writeUser(&user)
+	}
+}
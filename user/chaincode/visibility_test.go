@@ -0,0 +1,100 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestVisibility_DefaultPolicy(t *testing.T) {
+	ledger := NewLedger(t, "")
+	auditor := newAuditor(t, ledger)
+
+	// 同一租户看到全部字段
+	payload, _ := invokeAs(ledger, testCreator, "", "queryOnceUser", `{"id":"1"}`)
+	if string(payload) != `{"id":"1","name":"lzb1","sex":"男"}` {
+		t.Fatalf("unexpected same tenant user %s", payload)
+	}
+	// 其他组织只能看到id与用户名,单条、列表、分页与搜索结果一致
+	for _, c := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"queryOnceUser", `{"id":"1"}`}, `{"id":"1","name":"lzb1","sex":""}`},
+		{[]string{"queryAllUser"}, `[{"id":"1","name":"lzb1","sex":""},{"id":"2","name":"lzb2","sex":""}]`},
+		{[]string{"queryUserPage", "1"}, `{"users":[{"id":"1","name":"lzb1","sex":""}],"bookmark":"user:Org1MSP:n01:2","count":1}`},
+		{[]string{"queryUserByName", `{"name":"lzb2"}`}, `[{"id":"2","name":"lzb2","sex":""}]`},
+		{[]string{"QueryUsersByIdRange", "2", "", "10", ""}, `{"users":[{"id":"2","name":"lzb2","sex":""}],"bookmark":"","count":1}`},
+	} {
+		payload, err := invokeAs(ledger, auditor, testTenant, c.args[0], c.args[1:]...)
+		if err != nil {
+			t.Fatalf("%s: %v", c.args[0], err)
+		}
+		if string(payload) != c.want {
+			t.Fatalf("%s: expected %s, got %s", c.args[0], c.want, payload)
+		}
+	}
+}
+
+func TestVisibility_ConfiguredPolicy(t *testing.T) {
+	ledger := NewLedger(t, `{"readPolicy":{"sameTenant":["id","name"],"otherTenant":[]}}`)
+	alice := newCreator(t, "Org1MSP", "alice", nil)
+	admin := newCreator(t, "Org1MSP", "admin", map[string]string{roleAttribute: adminRole})
+	auditor := newAuditor(t, ledger)
+	payload, err := invokeAs(ledger, alice, "", "registerSelf", `{"name":"alice","sex":"女"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var registered UserInfo
+	_ = json.Unmarshal(payload, &registered)
+
+	// 同一租户的普通用户看不到他人的性别,但能看到自己的全部字段
+	payload, _ = invokeAs(ledger, alice, "", "queryAllUser")
+	var users []UserInfo
+	_ = json.Unmarshal(payload, &users)
+	if len(users) != 3 || users[0].Sex != "" || users[2].Sex != "女" || users[2].Owner != registered.Owner {
+		t.Fatalf("unexpected users for alice %s", payload)
+	}
+	// 租户管理员看到全部字段
+	if payload, _ = invokeAs(ledger, admin, "", "queryOnceUser", `{"id":"1"}`); string(payload) != `{"id":"1","name":"lzb1","sex":"男"}` {
+		t.Fatalf("unexpected user for admin %s", payload)
+	}
+	// 其他组织看不到任何用户
+	if _, err := invokeAs(ledger, auditor, testTenant, "queryOnceUser", `{"id":"1"}`); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("expected hidden user, got %v", err)
+	}
+	for _, args := range [][]string{{"queryAllUser"}, {"queryUserByName", `{"name":"lzb1"}`}} {
+		if payload, _ := invokeAs(ledger, auditor, testTenant, args[0], args[1:]...); string(payload) != `[]` {
+			t.Fatalf("%s: expected no users, got %s", args[0], payload)
+		}
+	}
+	if payload, _ = invokeAs(ledger, auditor, testTenant, "queryUserPage", "10"); !strings.Contains(string(payload), `"count":0`) {
+		t.Fatalf("expected empty page, got %s", payload)
+	}
+}
+
+func TestVisibility_SearchHiddenName(t *testing.T) {
+	ledger := NewLedger(t, `{"readPolicy":{"sameTenant":["id","sex"],"otherTenant":["id"]}}`)
+	// 看不到用户名时不能借搜索确认用户名
+	payload, err := invokeAs(ledger, testCreator, "", "queryUserByName", `{"name":"lzb1"}`)
+	if err != nil || string(payload) != `[]` {
+		t.Fatalf("expected no users, got %s %v", payload, err)
+	}
+	payload, _ = invokeAs(ledger, testCreator, "", "queryOnceUser", `{"id":"1"}`)
+	if string(payload) != `{"id":"1","name":"","sex":"男"}` {
+		t.Fatalf("unexpected user %s", payload)
+	}
+}
+
+func TestVisibility_InvalidPolicy(t *testing.T) {
+	ledger := NewLedger(t, "")
+	admin := newCreator(t, "Org1MSP", "admin", map[string]string{roleAttribute: adminRole})
+	for _, config := range []string{
+		`{"readPolicy":{"sameTenant":["id","password"]}}`,
+		`{"readPolicy":{"otherTenant":["name"]}}`,
+	} {
+		if _, err := invokeAs(ledger, admin, "", "setConfig", config); err == nil || !strings.Contains(err.Error(), "config error") {
+			t.Fatalf("%s: expected config error, got %v", config, err)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/lzb13612/Example-Chaincode/internal/server"
+	"github.com/lzb13612/Example-Chaincode/user/chaincode"
+)
+
+// title		main -> 启动
+// description	启动合约,设置 CHAINCODE_ID 与 CHAINCODE_SERVER_ADDRESS 时作为外部链码服务运行
+// auth			lzb
+func main() {
+	userChaincode, err := chaincode.NewChaincode()
+	if err != nil {
+		fmt.Printf("User create error:%s", err)
+		return
+	}
+	if err := server.Start(userChaincode); err != nil {
+		fmt.Printf("User start error:%s", err)
+	}
+}